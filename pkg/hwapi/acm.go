@@ -0,0 +1,370 @@
+package hwapi
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// ACM module and chipset ACM types
+const (
+	ACMTypeChipset = 2
+
+	ACMChipsetTypeBIOS  = 0
+	ACMChipsetTypeSINIT = 1
+
+	// acmHeaderVersion3 is used by ACMs signed with a 3072 bit RSA key
+	acmHeaderVersion3 = 0x30000
+
+	// acmChipsetIDRevisionMask indicates that the revision ID is a mask
+	acmChipsetIDRevisionMask = 1
+)
+
+// ACMHeader is the fixed part of the Authenticated Code Module header
+type ACMHeader struct {
+	ModuleType      uint16
+	ModuleSubType   uint16
+	HeaderLen       uint32 // in dwords
+	HeaderVersion   uint32
+	ChipsetID       uint16
+	Flags           uint16
+	ModuleVendor    uint32
+	Date            uint32 // BCD encoded yyyymmdd
+	Size            uint32 // in dwords
+	TxtSVN          uint16
+	SeSVN           uint16
+	CodeControl     uint32
+	ErrorEntryPoint uint32
+	GDTLimit        uint32
+	GDTBasePtr      uint32
+	SegSel          uint32
+	EntryPoint      uint32
+	Reserved2       [64]uint8
+	KeySize         uint32 // in dwords
+	ScratchSize     uint32 // in dwords
+}
+
+// ACMInfoTable is the ACM information table following the ACM header
+type ACMInfoTable struct {
+	UUID                [16]uint8
+	ChipsetACMType      uint8
+	Version             uint8
+	Length              uint16
+	ChipsetIDList       uint32
+	OsSinitDataVersion  uint32
+	MinMLEHeaderVersion uint32
+	Capabilities        uint32
+	ACMVersion          uint8
+	ACMRevision         [3]uint8
+	// ProcessorIDList is only present since version 4
+	ProcessorIDList uint32
+	// TPMInfoList is only present since version 5
+	TPMInfoList uint32
+}
+
+type acmInfoTableRaw struct {
+	UUID                [16]uint8
+	ChipsetACMType      uint8
+	Version             uint8
+	Length              uint16
+	ChipsetIDList       uint32
+	OsSinitDataVersion  uint32
+	MinMLEHeaderVersion uint32
+	Capabilities        uint32
+	ACMVersion          uint8
+	ACMRevision         [3]uint8
+}
+
+// ACMChipsetID is an entry of the ACM chipset ID list
+type ACMChipsetID struct {
+	Flags      uint32
+	VendorID   uint16
+	DeviceID   uint16
+	RevisionID uint16
+	Reserved   uint16
+	ExtendedID uint32
+}
+
+// ACMProcessorID is an entry of the ACM processor ID list
+type ACMProcessorID struct {
+	FMS          uint32
+	FMSMask      uint32
+	PlatformID   uint64
+	PlatformMask uint64
+}
+
+// ACMTPMInfo is the TPM capabilities list of an ACM
+type ACMTPMInfo struct {
+	Capabilities uint32
+	Algorithms   []uint16
+}
+
+// ACM is a decoded Authenticated Code Module
+type ACM struct {
+	Header    ACMHeader
+	RSAPubKey []byte // little endian modulus
+	RSAPubExp uint32
+	Signature []byte
+	InfoTable ACMInfoTable
+
+	ChipsetIDs   []ACMChipsetID
+	ProcessorIDs []ACMProcessorID
+	TPMInfo      *ACMTPMInfo
+}
+
+// IsSINIT returns true if the ACM is a SINIT module
+func (a *ACM) IsSINIT() bool {
+	return a.InfoTable.ChipsetACMType == ACMChipsetTypeSINIT
+}
+
+// DebugSigned returns true if the ACM is signed with a debug key
+func (a *ACM) DebugSigned() bool {
+	return a.Header.Flags&(1<<15) != 0
+}
+
+// DateString returns the BCD encoded date of the ACM as yyyy-mm-dd
+func (a *ACM) DateString() string {
+	return fmt.Sprintf("%04x-%02x-%02x", a.Header.Date>>16, (a.Header.Date>>8)&0xff, a.Header.Date&0xff)
+}
+
+// PublicKey returns the RSA public key the ACM is signed with
+func (a *ACM) PublicKey() *rsa.PublicKey {
+	modulus := make([]byte, len(a.RSAPubKey))
+	for i := range a.RSAPubKey {
+		modulus[len(modulus)-1-i] = a.RSAPubKey[i]
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(a.RSAPubExp),
+	}
+}
+
+// MatchesChipset returns true if the chipset identified by TXT.DIDVID is supported by the ACM
+func (a *ACM) MatchesChipset(didvid TXTDeviceID) bool {
+	for _, id := range a.ChipsetIDs {
+		if id.VendorID != didvid.VendorID || id.DeviceID != didvid.DeviceID {
+			continue
+		}
+		if id.Flags&acmChipsetIDRevisionMask != 0 {
+			if id.RevisionID&didvid.RevisionID != 0 {
+				return true
+			}
+		} else if id.RevisionID == didvid.RevisionID {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesProcessor returns true if the processor identified by its CPUID signature
+// and IA32_PLATFORM_ID is supported by the ACM. ACMs without a processor ID list
+// support every processor.
+func (a *ACM) MatchesProcessor(fms uint32, platformID uint64) bool {
+	if len(a.ProcessorIDs) == 0 {
+		return true
+	}
+	for _, id := range a.ProcessorIDs {
+		if fms&id.FMSMask == id.FMS && platformID&id.PlatformMask == id.PlatformID {
+			return true
+		}
+	}
+	return false
+}
+
+func parseACMList(buf []byte, off uint32, entry interface{}, name string) (*bytes.Reader, uint32, error) {
+	var count uint32
+
+	if uint64(off)+4 > uint64(len(buf)) {
+		return nil, 0, fmt.Errorf("ACM %s list is out of bounds", name)
+	}
+	reader := bytes.NewReader(buf[off:])
+	err := binary.Read(reader, binary.LittleEndian, &count)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint64(count)*uint64(binary.Size(entry)) > uint64(reader.Len()) {
+		return nil, 0, fmt.Errorf("ACM %s list has invalid count %d", name, count)
+	}
+	return reader, count, nil
+}
+
+// ParseACM decodes an Authenticated Code Module
+func ParseACM(buf []byte) (*ACM, error) {
+	var ret ACM
+
+	reader := bytes.NewReader(buf)
+	err := binary.Read(reader, binary.LittleEndian, &ret.Header)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ACM header: %v", err)
+	}
+	if ret.Header.ModuleType != ACMTypeChipset {
+		return nil, fmt.Errorf("ACM has unsupported module type %d", ret.Header.ModuleType)
+	}
+	if ret.Header.ModuleVendor != 0x8086 {
+		return nil, fmt.Errorf("ACM has unsupported module vendor %x", ret.Header.ModuleVendor)
+	}
+	if uint64(ret.Header.Size)*4 > uint64(len(buf)) {
+		return nil, fmt.Errorf("ACM size %x exceeds buffer size %x", uint64(ret.Header.Size)*4, len(buf))
+	}
+
+	ret.RSAPubKey = make([]byte, ret.Header.KeySize*4)
+	err = binary.Read(reader, binary.LittleEndian, ret.RSAPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ACM public key: %v", err)
+	}
+	if ret.Header.HeaderVersion < acmHeaderVersion3 {
+		err = binary.Read(reader, binary.LittleEndian, &ret.RSAPubExp)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM public exponent: %v", err)
+		}
+	} else {
+		ret.RSAPubExp = 0x10001
+	}
+	ret.Signature = make([]byte, len(ret.RSAPubKey))
+	err = binary.Read(reader, binary.LittleEndian, ret.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ACM signature: %v", err)
+	}
+
+	// The info table follows the header and the scratch area
+	infoOff := uint64(ret.Header.HeaderLen)*4 + uint64(ret.Header.ScratchSize)*4
+	if infoOff > uint64(len(buf)) {
+		return nil, fmt.Errorf("ACM info table is out of bounds")
+	}
+	reader = bytes.NewReader(buf[infoOff:])
+	var info acmInfoTableRaw
+	err = binary.Read(reader, binary.LittleEndian, &info)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ACM info table: %v", err)
+	}
+	ret.InfoTable = ACMInfoTable{
+		UUID:                info.UUID,
+		ChipsetACMType:      info.ChipsetACMType,
+		Version:             info.Version,
+		Length:              info.Length,
+		ChipsetIDList:       info.ChipsetIDList,
+		OsSinitDataVersion:  info.OsSinitDataVersion,
+		MinMLEHeaderVersion: info.MinMLEHeaderVersion,
+		Capabilities:        info.Capabilities,
+		ACMVersion:          info.ACMVersion,
+		ACMRevision:         info.ACMRevision,
+	}
+	if info.Version >= 4 {
+		err = binary.Read(reader, binary.LittleEndian, &ret.InfoTable.ProcessorIDList)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM processor ID list offset: %v", err)
+		}
+	}
+	if info.Version >= 5 {
+		err = binary.Read(reader, binary.LittleEndian, &ret.InfoTable.TPMInfoList)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM TPM info list offset: %v", err)
+		}
+	}
+
+	listReader, count, err := parseACMList(buf, ret.InfoTable.ChipsetIDList, ACMChipsetID{}, "chipset ID")
+	if err != nil {
+		return nil, err
+	}
+	ret.ChipsetIDs = make([]ACMChipsetID, count)
+	err = binary.Read(listReader, binary.LittleEndian, &ret.ChipsetIDs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ACM chipset ID list: %v", err)
+	}
+
+	if ret.InfoTable.ProcessorIDList != 0 {
+		listReader, count, err = parseACMList(buf, ret.InfoTable.ProcessorIDList, ACMProcessorID{}, "processor ID")
+		if err != nil {
+			return nil, err
+		}
+		ret.ProcessorIDs = make([]ACMProcessorID, count)
+		err = binary.Read(listReader, binary.LittleEndian, &ret.ProcessorIDs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM processor ID list: %v", err)
+		}
+	}
+
+	if ret.InfoTable.TPMInfoList != 0 {
+		var tpmInfo struct {
+			Capabilities uint32
+			Count        uint16
+		}
+		if uint64(ret.InfoTable.TPMInfoList) > uint64(len(buf)) {
+			return nil, fmt.Errorf("ACM TPM info list is out of bounds")
+		}
+		listReader = bytes.NewReader(buf[ret.InfoTable.TPMInfoList:])
+		err = binary.Read(listReader, binary.LittleEndian, &tpmInfo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM TPM info list: %v", err)
+		}
+		ret.TPMInfo = &ACMTPMInfo{
+			Capabilities: tpmInfo.Capabilities,
+			Algorithms:   make([]uint16, tpmInfo.Count),
+		}
+		err = binary.Read(listReader, binary.LittleEndian, &ret.TPMInfo.Algorithms)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACM TPM algorithms: %v", err)
+		}
+	}
+
+	return &ret, nil
+}
+
+// ReadSINITACM reads the SINIT ACM located at TXT.SINIT.BASE and decodes it
+func ReadSINITACM(h LowLevelHardwareInterfaces) (*ACM, error) {
+	var hdr ACMHeader
+
+	regs, err := ReadTXTRegisters(h)
+	if err != nil {
+		return nil, err
+	}
+	if regs.SINITBase == 0 || regs.SINITSize == 0 || regs.SINITBase == 0xffffffff {
+		return nil, fmt.Errorf("TXT SINIT region isn't configured")
+	}
+
+	buf := make([]byte, binary.Size(hdr))
+	err = h.ReadPhysBuf(int64(regs.SINITBase), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SINIT ACM header: %v", err)
+	}
+	err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(hdr.Size) * 4
+	if size < uint64(len(buf)) || size > uint64(regs.SINITSize) {
+		return nil, fmt.Errorf("SINIT ACM size %x doesn't fit into the SINIT region of size %x", size, regs.SINITSize)
+	}
+
+	buf = make([]byte, size)
+	err = h.ReadPhysBuf(int64(regs.SINITBase), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SINIT ACM: %v", err)
+	}
+
+	return ParseACM(buf)
+}
+
+// CheckACMMatchesPlatform verifies that the ACM supports the chipset and processor of the platform
+func CheckACMMatchesPlatform(h LowLevelHardwareInterfaces, acm *ACM) error {
+	regs, err := ReadTXTRegisters(h)
+	if err != nil {
+		return err
+	}
+	if !acm.MatchesChipset(regs.DIDVID) {
+		return fmt.Errorf("ACM doesn't support chipset %04x:%04x rev %x",
+			regs.DIDVID.VendorID, regs.DIDVID.DeviceID, regs.DIDVID.RevisionID)
+	}
+
+	platformID, err := IA32PlatformID(h)
+	if err != nil {
+		return err
+	}
+	if !acm.MatchesProcessor(h.CPUSignature(), platformID) {
+		return fmt.Errorf("ACM doesn't support processor %08x with platform ID %x", h.CPUSignature(), platformID)
+	}
+
+	return nil
+}
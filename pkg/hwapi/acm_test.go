@@ -0,0 +1,86 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseACM(t *testing.T) {
+	var buf bytes.Buffer
+
+	hdr := ACMHeader{
+		ModuleType:    ACMTypeChipset,
+		HeaderVersion: acmHeaderVersion3,
+		ModuleVendor:  0x8086,
+		Date:          0x20230115,
+		TxtSVN:        4,
+		KeySize:       96,
+		ScratchSize:   0,
+	}
+	hdr.HeaderLen = uint32(binary.Size(hdr)+96*4*2) / 4
+	infoOff := hdr.HeaderLen * 4
+	chipsetOff := infoOff + uint32(binary.Size(acmInfoTableRaw{})) + 8
+	procOff := chipsetOff + 4 + uint32(binary.Size(ACMChipsetID{}))
+	tpmOff := procOff + 4 + uint32(binary.Size(ACMProcessorID{}))
+	hdr.Size = (tpmOff + 8 + 4) / 4
+
+	key := make([]byte, 96*4)
+	key[0] = 0x01
+	key[len(key)-1] = 0x80
+
+	for _, f := range []interface{}{
+		hdr,
+		key,
+		make([]byte, 96*4),
+		acmInfoTableRaw{ChipsetACMType: ACMChipsetTypeSINIT, Version: 5, ChipsetIDList: chipsetOff},
+		procOff,
+		tpmOff,
+		uint32(1),
+		ACMChipsetID{Flags: acmChipsetIDRevisionMask, VendorID: 0x8086, DeviceID: 0xb002, RevisionID: 0x3},
+		uint32(1),
+		ACMProcessorID{FMS: 0x906e0, FMSMask: 0xfff3ff0, PlatformID: 0, PlatformMask: 0},
+		uint32(0x1f),
+		uint16(2),
+		[]uint16{0x4, 0xb, 0},
+	} {
+		if err := binary.Write(&buf, binary.LittleEndian, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acm, err := ParseACM(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseACM failed: %v", err)
+	}
+
+	if !acm.IsSINIT() || acm.DebugSigned() {
+		t.Errorf("Unexpected ACM type: %+v", acm.InfoTable)
+	}
+	if acm.DateString() != "2023-01-15" {
+		t.Errorf("Unexpected ACM date: %s", acm.DateString())
+	}
+	if acm.RSAPubExp != 0x10001 || acm.PublicKey().N.BitLen() != 3072 {
+		t.Errorf("Unexpected ACM public key")
+	}
+	if acm.TPMInfo == nil || len(acm.TPMInfo.Algorithms) != 2 || acm.TPMInfo.Algorithms[1] != 0xb {
+		t.Errorf("Unexpected ACM TPM info: %+v", acm.TPMInfo)
+	}
+
+	if !acm.MatchesChipset(TXTDeviceID{VendorID: 0x8086, DeviceID: 0xb002, RevisionID: 0x1}) {
+		t.Errorf("Chipset should match")
+	}
+	if acm.MatchesChipset(TXTDeviceID{VendorID: 0x8086, DeviceID: 0xb002, RevisionID: 0x4}) {
+		t.Errorf("Chipset revision shouldn't match")
+	}
+	if !acm.MatchesProcessor(0x906ea, 0x4) {
+		t.Errorf("Processor should match")
+	}
+	if acm.MatchesProcessor(0x806ea, 0x4) {
+		t.Errorf("Processor shouldn't match")
+	}
+
+	if _, err := ParseACM(buf.Bytes()[:len(buf.Bytes())-8]); err == nil {
+		t.Errorf("Truncated ACM wasn't detected")
+	}
+}
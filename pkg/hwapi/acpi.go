@@ -39,7 +39,8 @@ type ACPIRsdp struct {
 	Reserved         [3]uint8
 }
 
-type acpiHeader struct {
+// ACPIHeader as defined in ACPI Spec 6.2 "5.2.6 System Description Table Header"
+type ACPIHeader struct {
 	Signature       [4]uint8
 	Length          uint32
 	Revision        uint8
@@ -51,22 +52,178 @@ type acpiHeader struct {
 	CreatorRevision uint32
 }
 
+// ACPI generic address structure address spaces
+const (
+	ACPIAddressSpaceSystemMemory      = 0
+	ACPIAddressSpaceSystemIO          = 1
+	ACPIAddressSpacePCIConfig         = 2
+	ACPIAddressSpaceFunctionalFixedHW = 0x7f
+)
+
+// ACPIGenericAddress as defined in ACPI Spec 6.5 "5.2.3.2 Generic Address Structure (GAS)"
+type ACPIGenericAddress struct {
+	AddressSpaceID    uint8
+	RegisterBitWidth  uint8
+	RegisterBitOffset uint8
+	// AccessSize is 1 for byte up to 4 for qword access, 0 if undefined
+	AccessSize uint8
+	Address    uint64
+}
+
+// IsSet returns true if the structure points to a register
+func (g ACPIGenericAddress) IsSet() bool {
+	return g.Address != 0
+}
+
+func (g ACPIGenericAddress) String() string {
+	var space string
+	switch g.AddressSpaceID {
+	case ACPIAddressSpaceSystemMemory:
+		space = "mem"
+	case ACPIAddressSpaceSystemIO:
+		space = "io"
+	case ACPIAddressSpacePCIConfig:
+		space = "pci"
+	default:
+		space = fmt.Sprintf("space %#x", g.AddressSpaceID)
+	}
+	return fmt.Sprintf("%s %#x (%d bits at bit %d)", space, g.Address, g.RegisterBitWidth, g.RegisterBitOffset)
+}
+
+// ACPITableOption modifies how ACPI tables are returned
+type ACPITableOption int
+
+const (
+	// ACPIRejectCorrupt returns an error for tables that fail ValidateACPITable
+	ACPIRejectCorrupt ACPITableOption = iota
+)
+
+// ParseACPIHeader decodes the header at the start of an ACPI table
+func ParseACPIHeader(buf []byte) (*ACPIHeader, error) {
+	var hdr ACPIHeader
+
+	if len(buf) < binary.Size(hdr) {
+		return nil, fmt.Errorf("ACPI table too short for header: %d bytes", len(buf))
+	}
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	return &hdr, nil
+}
+
+func validACPISignature(sig []byte) bool {
+	for _, c := range sig {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' && c != '!' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateACPITable checks signature, length and checksum of an ACPI table
+// and reports all problems found. The RSDP and FACS are recognized by their
+// signature; the FACS has no checksum.
+func ValidateACPITable(buf []byte) error {
+	var errs []string
+
+	if len(buf) >= 8 && string(buf[:8]) == "RSD PTR " {
+		var rsdp ACPIRsdp
+		padded := make([]byte, binary.Size(rsdp))
+		copy(padded, buf)
+		err := binary.Read(bytes.NewReader(padded), binary.LittleEndian, &rsdp)
+		if err != nil {
+			return err
+		}
+		if rsdp.Revision == 0 {
+			rsdp.RSDPLen = uint32(binary.Size(rsdp.ACPIRsdpRev1))
+		}
+		if int(rsdp.RSDPLen) > len(buf) {
+			return fmt.Errorf("ACPI RSDP truncated: length %d, got %d bytes", rsdp.RSDPLen, len(buf))
+		}
+		return verifyRSDP(buf[:rsdp.RSDPLen], rsdp)
+	}
+
+	// The FACS has no revision, checksum or OEM fields
+	if len(buf) >= 8 && string(buf[:4]) == "FACS" {
+		length := binary.LittleEndian.Uint32(buf[4:])
+		if length < 64 {
+			errs = append(errs, fmt.Sprintf("length %d below minimum of 64", length))
+		} else if int(length) > len(buf) {
+			errs = append(errs, fmt.Sprintf("truncated: length %d, got %d bytes", length, len(buf)))
+		} else if int(length) < len(buf) {
+			errs = append(errs, fmt.Sprintf("%d bytes trailing the table", len(buf)-int(length)))
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("ACPI table FACS: %s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	hdr, err := ParseACPIHeader(buf)
+	if err != nil {
+		return err
+	}
+	sig := string(hdr.Signature[:])
+	if !validACPISignature(hdr.Signature[:]) {
+		errs = append(errs, fmt.Sprintf("invalid signature %q", sig))
+	}
+	length := int(hdr.Length)
+	switch {
+	case length < binary.Size(*hdr):
+		errs = append(errs, fmt.Sprintf("length %d below header size", length))
+		length = len(buf)
+	case length > len(buf):
+		errs = append(errs, fmt.Sprintf("truncated: length %d, got %d bytes", length, len(buf)))
+		length = len(buf)
+	case length < len(buf):
+		errs = append(errs, fmt.Sprintf("%d bytes trailing the table", len(buf)-length))
+	}
+	chksum := byte(0)
+	for _, b := range buf[:length] {
+		chksum += b
+	}
+	if chksum != 0 {
+		errs = append(errs, fmt.Sprintf("invalid checksum %#02x, table sums to %#02x", hdr.Checksum, chksum))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("ACPI table %s: %s", sig, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // ACPIRsdt as defined in ACPI Spec 6.2 "5.2.7 Root System Description Table (RSDT)"
 type acpiRsdt struct {
-	acpiHeader
+	ACPIHeader
 	// Entry           []uint32 count depend on Length field
 }
 
 // ACPIXsdt as defined in ACPI Spec 6.2 "5.2.8 Extended System Description Table (XSDT)"
 type acpiXsdt struct {
-	acpiHeader
+	ACPIHeader
 	// Entry           []uint64 count depend on Length field
 }
 
+// GetACPITableSysFS reads the ACPI table n from sysfs. Like GetACPITableDevMem
+// a signature of a table with multiple instances returns the first instance.
 func GetACPITableSysFS(h LowLevelHardwareInterfaces, n string) ([]byte, error) {
-	buf, err := os.ReadFile(fmt.Sprintf("%s/%s", acpiSysfsPath, n))
+	return readACPITableSysFS(acpiSysfsPath, n)
+}
+
+// readACPITableSysFS reads the table n from dir. The kernel names multiple
+// instances of a table SSDT1, SSDT2 and so on, so n1 is tried if n is missing.
+func readACPITableSysFS(dir string, n string) ([]byte, error) {
+	buf, err := os.ReadFile(fmt.Sprintf("%s/%s", dir, n))
+	if os.IsNotExist(err) && len(n) == 4 {
+		var err1 error
+		buf, err1 = os.ReadFile(fmt.Sprintf("%s/%s1", dir, n))
+		if err1 == nil {
+			err = nil
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("cannot access sysfs path %s: %s", acpiSysfsPath, err)
+		return nil, fmt.Errorf("cannot access sysfs path %s: %s", dir, err)
 	}
 	return buf, nil
 }
@@ -98,11 +255,11 @@ func getACPITableDevMemRSDT(address uint32, l LowLevelHardwareInterfaces) ([]uin
 		return nil, nil, fmt.Errorf("RSDT has invalid signature")
 	}
 	if rsdt.Length == 0 || rsdt.Length == 0xffffffff ||
-		(rsdt.Length-uint32(binary.Size(acpiHeader{})))%4 > 0 {
+		(rsdt.Length-uint32(binary.Size(ACPIHeader{})))%4 > 0 {
 		return nil, nil, fmt.Errorf("RSDT has invalid length")
 	}
-	buf = make([]byte, (rsdt.Length - uint32(binary.Size(acpiHeader{}))))
-	err = l.ReadPhysBuf(int64(address)+int64(binary.Size(acpiHeader{})), buf)
+	buf = make([]byte, (rsdt.Length - uint32(binary.Size(ACPIHeader{}))))
+	err = l.ReadPhysBuf(int64(address)+int64(binary.Size(ACPIHeader{})), buf)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -152,11 +309,11 @@ func getACPITableDevMemXSDT(address uint64, l LowLevelHardwareInterfaces) ([]uin
 		return nil, nil, fmt.Errorf("XSDT has invalid signature")
 	}
 	if xsdt.Length == 0 || xsdt.Length == 0xffffffff ||
-		(xsdt.Length-uint32(binary.Size(acpiHeader{})))%8 > 0 {
+		(xsdt.Length-uint32(binary.Size(ACPIHeader{})))%8 > 0 {
 		return nil, nil, fmt.Errorf("XSDT has invalid length")
 	}
-	buf = make([]byte, (xsdt.Length - uint32(binary.Size(acpiHeader{}))))
-	err = l.ReadPhysBuf(int64(address)+int64(binary.Size(acpiHeader{})), buf)
+	buf = make([]byte, (xsdt.Length - uint32(binary.Size(ACPIHeader{}))))
+	err = l.ReadPhysBuf(int64(address)+int64(binary.Size(ACPIHeader{})), buf)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -398,101 +555,315 @@ func getACPITableDevMemRSDP(l LowLevelHardwareInterfaces) ([]byte, ACPIRsdp, err
 	return nil, rsdp, fmt.Errorf("RSDP not found")
 }
 
-func GetACPITableDevMem(h LowLevelHardwareInterfaces, n string) ([]byte, error) {
-	rsdpBuf, rsdp, err := getACPITableDevMemRSDP(h)
-	if err != nil {
-		return nil, err
-	}
+// ACPITableInfo describes an ACPI table
+type ACPITableInfo struct {
+	// Name is the name used by sysfs: the signature, followed by the instance
+	// number if the signature isn't unique
+	Name        string
+	Signature   string
+	Revision    uint8
+	OEMID       string
+	OEMTableID  string
+	OEMRevision uint32
+	// Address is the physical address, 0 if unknown
+	Address uint64
+	Length  uint32
+}
 
-	if string(rsdp.Signature[:]) != "RSD PTR " {
-		return nil, fmt.Errorf("RSDP not found")
-	}
+// FADT offsets of the FACS and DSDT pointers
+const (
+	acpiFADTFirmwareCtrl  = 36
+	acpiFADTDSDT          = 40
+	acpiFADTXFirmwareCtrl = 132
+	acpiFADTXDSDT         = 140
+)
 
-	if n == "RSDP" {
-		return rsdpBuf, nil
+func newACPITableInfo(hdr *ACPIHeader, addr uint64) ACPITableInfo {
+	return ACPITableInfo{
+		Signature:   string(hdr.Signature[:]),
+		Revision:    hdr.Revision,
+		OEMID:       strings.TrimRight(string(hdr.OEMID[:]), " \x00"),
+		OEMTableID:  strings.TrimRight(string(hdr.OEMTableID[:]), " \x00"),
+		OEMRevision: hdr.OEMRevision,
+		Address:     addr,
+		Length:      hdr.Length,
 	}
+}
 
-	rsdtHeaders, rsdtBuf, err1 := getACPITableDevMemRSDT(rsdp.RSDTPtr, h)
-	if err1 == nil && n == "RSDT" {
-		return rsdtBuf, nil
+// nameACPITables assigns the sysfs names, numbering duplicated signatures from 1 in table order
+func nameACPITables(tables []ACPITableInfo) {
+	count := map[string]int{}
+	for _, t := range tables {
+		count[t.Signature]++
+	}
+	instance := map[string]int{}
+	for i := range tables {
+		sig := tables[i].Signature
+		tables[i].Name = sig
+		if count[sig] > 1 {
+			instance[sig]++
+			tables[i].Name = fmt.Sprintf("%s%d", sig, instance[sig])
+		}
 	}
+}
 
-	xsdtHeaders, xsdtBuf, err2 := getACPITableDevMemXSDT(rsdp.XSDTPtr, h)
-	if err2 == nil && n == "XSDT" {
-		return xsdtBuf, nil
+func readACPIHeaderAt(h LowLevelHardwareInterfaces, addr uint64) (*ACPIHeader, error) {
+	var header ACPIHeader
+
+	buf := make([]byte, binary.Size(header))
+	err := h.ReadPhysBuf(int64(addr), buf)
+	if err != nil {
+		return nil, err
 	}
+	err = binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &header)
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
 
-	if err1 != nil && err2 != nil {
-		return nil, fmt.Errorf("RSDT and XSDT are invalid")
+// fadtPointer returns the 64 bit pointer if present and set, the 32 bit pointer otherwise
+func fadtPointer(fadt []byte, off32, off64 int) uint64 {
+	if len(fadt) >= off64+8 {
+		if p := binary.LittleEndian.Uint64(fadt[off64:]); p != 0 {
+			return p
+		}
+	}
+	if len(fadt) >= off32+4 {
+		return uint64(binary.LittleEndian.Uint32(fadt[off32:]))
 	}
+	return 0
+}
 
-	buf := make([]byte, binary.Size(acpiHeader{}))
+// enumerateACPITablesAt lists the tables referenced by the root table entries
+// in order. The DSDT and FACS follow the FADT, as they're found through it.
+func enumerateACPITablesAt(h LowLevelHardwareInterfaces, addrs []uint64) ([]ACPITableInfo, error) {
+	var ret []ACPITableInfo
 
-	acpitables := map[uint64]string{}
-	if len(rsdtHeaders) > 0 {
-		for i := range rsdtHeaders {
-			var header acpiHeader
-			if _, ok := acpitables[uint64(rsdtHeaders[i])]; ok {
-				continue
-			}
+	for _, addr := range addrs {
+		if addr == 0 {
+			continue
+		}
+		header, err := readACPIHeaderAt(h, addr)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, newACPITableInfo(header, addr))
+		if string(header.Signature[:]) != "FACP" {
+			continue
+		}
 
-			err := h.ReadPhysBuf(int64(rsdtHeaders[i]), buf)
+		fadt := make([]byte, header.Length)
+		err = h.ReadPhysBuf(int64(addr), fadt)
+		if err != nil {
+			return nil, err
+		}
+		if dsdt := fadtPointer(fadt, acpiFADTDSDT, acpiFADTXDSDT); dsdt != 0 {
+			header, err := readACPIHeaderAt(h, dsdt)
 			if err != nil {
 				return nil, err
 			}
-			err = binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &header)
+			ret = append(ret, newACPITableInfo(header, dsdt))
+		}
+		if facs := fadtPointer(fadt, acpiFADTFirmwareCtrl, acpiFADTXFirmwareCtrl); facs != 0 {
+			// The FACS has no standard header, only signature and length
+			header, err := readACPIHeaderAt(h, facs)
 			if err != nil {
 				return nil, err
 			}
-			acpitables[uint64(rsdtHeaders[i])] = string(header.Signature[:])
+			ret = append(ret, ACPITableInfo{
+				Signature: string(header.Signature[:]),
+				Address:   facs,
+				Length:    header.Length,
+			})
 		}
 	}
+	nameACPITables(ret)
 
-	if len(xsdtHeaders) > 1 {
-		for i := range xsdtHeaders {
-			var header acpiHeader
-			if _, ok := acpitables[xsdtHeaders[i]]; ok {
-				continue
-			}
+	return ret, nil
+}
 
-			err := h.ReadPhysBuf(int64(xsdtHeaders[i]), buf)
-			if err != nil {
-				return nil, err
-			}
-			err = binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &header)
-			if err != nil {
-				return nil, err
-			}
-			acpitables[xsdtHeaders[i]] = string(header.Signature[:])
+// EnumerateACPITablesDevMem lists all ACPI tables found through the XSDT, or
+// the RSDT if there's no valid XSDT, using /dev/mem
+func EnumerateACPITablesDevMem(h LowLevelHardwareInterfaces) ([]ACPITableInfo, error) {
+	_, rsdp, err := getACPITableDevMemRSDP(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []uint64
+	xsdtHeaders, _, err := getACPITableDevMemXSDT(rsdp.XSDTPtr, h)
+	if err == nil && rsdp.Revision > 0 && rsdp.XSDTPtr != 0 {
+		addrs = xsdtHeaders
+	} else {
+		rsdtHeaders, _, err := getACPITableDevMemRSDT(rsdp.RSDTPtr, h)
+		if err != nil {
+			return nil, fmt.Errorf("RSDT and XSDT are invalid")
+		}
+		for _, a := range rsdtHeaders {
+			addrs = append(addrs, uint64(a))
 		}
 	}
 
-	for k, v := range acpitables {
-		if v == n { // FIXME: Handle duplicated entries like SSDT
-			var header acpiHeader
+	return enumerateACPITablesAt(h, addrs)
+}
 
-			err := h.ReadPhysBuf(int64(k), buf)
-			if err != nil {
-				return nil, err
-			}
-			err = binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &header)
-			if err != nil {
-				return nil, err
-			}
-			buf = make([]byte, header.Length)
-			err = h.ReadPhysBuf(int64(k), buf)
-			if err != nil {
-				return nil, err
-			}
+// EnumerateACPITablesSysFS lists all ACPI tables exported by sysfs. The
+// physical addresses aren't known.
+func EnumerateACPITablesSysFS() ([]ACPITableInfo, error) {
+	var ret []ACPITableInfo
+
+	entries, err := os.ReadDir(acpiSysfsPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access sysfs path %s: %s", acpiSysfsPath, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		buf, err := os.ReadFile(fmt.Sprintf("%s/%s", acpiSysfsPath, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACPI table %s: %v", e.Name(), err)
+		}
+
+		// The FACS has no standard header, only signature and length
+		if e.Name() == "FACS" && len(buf) >= 8 {
+			ret = append(ret, ACPITableInfo{Name: e.Name(), Signature: "FACS", Length: binary.LittleEndian.Uint32(buf[4:])})
+			continue
+		}
+		var header ACPIHeader
+		err = binary.Read(bytes.NewBuffer(buf), binary.LittleEndian, &header)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ACPI table %s: %v", e.Name(), err)
+		}
+		info := newACPITableInfo(&header, 0)
+		info.Name = e.Name()
+		ret = append(ret, info)
+	}
 
-			return buf, nil
+	return ret, nil
+}
+
+// EnumerateACPITables lists all ACPI tables, using /dev/mem to find their
+// physical addresses and sysfs as fallback
+func (h HwAPI) EnumerateACPITables() ([]ACPITableInfo, error) {
+	tables, err := EnumerateACPITablesDevMem(h)
+	if err != nil {
+		tables, err = EnumerateACPITablesSysFS()
+	}
+	return tables, err
+}
+
+// GetACPITablesSysFS returns all instances of the ACPI table with the given signature
+func GetACPITablesSysFS(sig string) ([][]byte, error) {
+	var ret [][]byte
+
+	// Unique tables have no instance number
+	buf, err := os.ReadFile(fmt.Sprintf("%s/%s", acpiSysfsPath, sig))
+	if err == nil {
+		return [][]byte{buf}, nil
+	}
+	for i := 1; ; i++ {
+		buf, err := os.ReadFile(fmt.Sprintf("%s/%s%d", acpiSysfsPath, sig, i))
+		if err != nil {
+			break
 		}
+		ret = append(ret, buf)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("ACPI table %s not found in sysfs path %s", sig, acpiSysfsPath)
 	}
-	return nil, fmt.Errorf("ACPI table not found")
+
+	return ret, nil
+}
+
+// GetACPITablesDevMem returns all instances of the ACPI table with the given signature in table order
+func GetACPITablesDevMem(h LowLevelHardwareInterfaces, sig string) ([][]byte, error) {
+	var ret [][]byte
+
+	tables, err := EnumerateACPITablesDevMem(h)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if t.Signature != sig {
+			continue
+		}
+		buf := make([]byte, t.Length)
+		err = h.ReadPhysBuf(int64(t.Address), buf)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, buf)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("ACPI table not found")
+	}
+
+	return ret, nil
+}
+
+// GetACPITableDevMem returns the ACPI table with the given sysfs name. If the
+// signature isn't unique, the first instance is returned.
+func GetACPITableDevMem(h LowLevelHardwareInterfaces, n string) ([]byte, error) {
+	rsdpBuf, rsdp, err := getACPITableDevMemRSDP(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(rsdp.Signature[:]) != "RSD PTR " {
+		return nil, fmt.Errorf("RSDP not found")
+	}
+
+	switch n {
+	case "RSDP":
+		return rsdpBuf, nil
+	case "RSDT":
+		_, rsdtBuf, err := getACPITableDevMemRSDT(rsdp.RSDTPtr, h)
+		return rsdtBuf, err
+	case "XSDT":
+		_, xsdtBuf, err := getACPITableDevMemXSDT(rsdp.XSDTPtr, h)
+		return xsdtBuf, err
+	}
+
+	tables, err := EnumerateACPITablesDevMem(h)
+	if err != nil {
+		return nil, err
+	}
+	var found *ACPITableInfo
+	for i := range tables {
+		if tables[i].Name == n {
+			found = &tables[i]
+			break
+		}
+		if found == nil && tables[i].Signature == n {
+			found = &tables[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("ACPI table not found")
+	}
+
+	buf := make([]byte, found.Length)
+	err = h.ReadPhysBuf(int64(found.Address), buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func hasACPITableOption(opts []ACPITableOption, opt ACPITableOption) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
 }
 
 // GetACPITable returns the requested ACPI table, for DSDT use argument "DSDT"
-func (h HwAPI) GetACPITable(n string) ([]byte, error) {
+func (h HwAPI) GetACPITable(n string, opts ...ACPITableOption) ([]byte, error) {
 	if n == "" || len(n) > 6 {
 		return nil, fmt.Errorf("invalid ACPI name")
 	}
@@ -502,5 +873,32 @@ func (h HwAPI) GetACPITable(n string) ([]byte, error) {
 	if err != nil {
 		tbl, err = GetACPITableDevMem(h, n)
 	}
+	if err == nil && hasACPITableOption(opts, ACPIRejectCorrupt) {
+		err = ValidateACPITable(tbl)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return tbl, err
 }
+
+// GetACPITables returns all instances of the ACPI table with the given signature, like SSDTs
+func (h HwAPI) GetACPITables(sig string, opts ...ACPITableOption) ([][]byte, error) {
+	if len(sig) != 4 {
+		return nil, fmt.Errorf("invalid ACPI signature")
+	}
+
+	tbls, err := GetACPITablesSysFS(sig)
+	if err != nil {
+		tbls, err = GetACPITablesDevMem(h, sig)
+	}
+	if err == nil && hasACPITableOption(opts, ACPIRejectCorrupt) {
+		for i, tbl := range tbls {
+			err = ValidateACPITable(tbl)
+			if err != nil {
+				return nil, fmt.Errorf("instance %d: %v", i+1, err)
+			}
+		}
+	}
+	return tbls, err
+}
@@ -0,0 +1,116 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnumerateACPITablesAt(t *testing.T) {
+	mem := newPhysMemImage()
+
+	// FADT revision 1 with 32 bit FACS and DSDT pointers
+	fadt := make([]byte, 80)
+	binary.LittleEndian.PutUint32(fadt[acpiFADTFirmwareCtrl-36:], 0x1050)
+	binary.LittleEndian.PutUint32(fadt[acpiFADTDSDT-36:], 0x2000)
+	mem.writeBuf(0x1000, acpiTestTable(t, "FACP", fadt))
+	mem.writeBuf(0x1050, []byte("FACS\x40\x00\x00\x00"))
+	mem.writeBuf(0x2000, acpiTestTable(t, "DSDT", []byte{0x10}))
+	mem.writeBuf(0x3000, acpiTestTable(t, "SSDT", []byte{0x10, 0x01}))
+	mem.writeBuf(0x4000, acpiTestTable(t, "APIC", uint64(0)))
+	mem.writeBuf(0x5000, acpiTestTable(t, "SSDT", []byte{0x10, 0x02}))
+
+	tables, err := enumerateACPITablesAt(mem, []uint64{0x1000, 0x3000, 0, 0x4000, 0x5000})
+	if err != nil {
+		t.Fatalf("enumerateACPITablesAt failed: %v", err)
+	}
+
+	var got []string
+	for _, tbl := range tables {
+		got = append(got, fmt.Sprintf("%s:%s@%x+%d", tbl.Name, tbl.OEMID, tbl.Address, tbl.Length))
+	}
+	want := []string{
+		"FACP:9ELEMS@1000+116",
+		"DSDT:9ELEMS@2000+37",
+		"FACS:@1050+64",
+		"SSDT1:9ELEMS@3000+38",
+		"APIC:9ELEMS@4000+44",
+		"SSDT2:9ELEMS@5000+38",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func TestValidateACPITable(t *testing.T) {
+	good := acpiTestTable(t, "SSDT", []byte{0x10, 0x01})
+
+	hdr, err := ParseACPIHeader(good)
+	if err != nil {
+		t.Fatalf("ParseACPIHeader failed: %v", err)
+	}
+	if string(hdr.Signature[:]) != "SSDT" || hdr.Length != 38 || string(hdr.OEMID[:]) != "9ELEMS" {
+		t.Errorf("Unexpected header %+v", hdr)
+	}
+	if _, err := ParseACPIHeader(good[:20]); err == nil {
+		t.Errorf("ParseACPIHeader accepted a short buffer")
+	}
+
+	badSum := append([]byte{}, good...)
+	badSum[36]++
+	badSig := acpiTestTable(t, "ss?t", []byte{0x10})
+	facs := make([]byte, 64)
+	copy(facs, "FACS\x40")
+	rsdp := make([]byte, 20)
+	copy(rsdp, "RSD PTR ")
+	var sum byte
+	for _, b := range rsdp {
+		sum += b
+	}
+	rsdp[8] = -sum
+
+	for _, tc := range []struct {
+		name string
+		buf  []byte
+		want string
+	}{
+		{"valid", good, ""},
+		{"checksum", badSum, "ACPI table SSDT: invalid checksum 0xdb, table sums to 0x01"},
+		{"truncated", good[:37], "ACPI table SSDT: truncated: length 38, got 37 bytes; invalid checksum 0xdb, table sums to 0xff"},
+		{"trailing", append(append([]byte{}, good...), 0, 0), "ACPI table SSDT: 2 bytes trailing the table"},
+		{"signature", badSig, `ACPI table ss?t: invalid signature "ss?t"`},
+		{"FACS", facs, ""},
+		{"FACS truncated", facs[:32], "ACPI table FACS: truncated: length 64, got 32 bytes"},
+		{"RSDP", rsdp, ""},
+	} {
+		err := ValidateACPITable(tc.buf)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestReadACPITableSysFS(t *testing.T) {
+	dir := t.TempDir()
+	for n, content := range map[string]string{"DSDT": "dsdt", "SSDT1": "ssdt1", "SSDT2": "ssdt2"} {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for n, want := range map[string]string{"DSDT": "dsdt", "SSDT": "ssdt1", "SSDT2": "ssdt2"} {
+		buf, err := readACPITableSysFS(dir, n)
+		if err != nil || string(buf) != want {
+			t.Errorf("readACPITableSysFS(%s) = %q, %v, want %q", n, buf, err, want)
+		}
+	}
+	if _, err := readACPITableSysFS(dir, "MCFG"); err == nil {
+		t.Errorf("readACPITableSysFS found a missing table")
+	}
+}
@@ -0,0 +1,343 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TPM2 table start methods
+const (
+	TPM2StartMethodACPI          = 2
+	TPM2StartMethodMemoryMapped  = 6
+	TPM2StartMethodCRB           = 7
+	TPM2StartMethodCRBWithACPI   = 8
+	TPM2StartMethodCRBWithARMSMC = 11
+	TPM2StartMethodCRBWithPluton = 13
+	TPM2StartMethodCRBWithARMFFA = 15
+)
+
+// TCPA table platform classes
+const (
+	TCPAPlatformClient = 0
+	TCPAPlatformServer = 1
+)
+
+const (
+	// tpm2LogFieldsSize is the size of the LAML and LASA fields trailing the TPM2 table
+	tpm2LogFieldsSize = 12
+	// tpmMaxLogSize limits the event log read from physical memory
+	tpmMaxLogSize = 16 << 20
+
+	// tcgPCREventHdrSize is the size of a TCG_PCR_EVENT without event data
+	tcgPCREventHdrSize = 32
+	// tcgPCREvent2HdrSize is the size of the PCR index, event type and digest count of a TCG_PCR_EVENT2
+	tcgPCREvent2HdrSize = 12
+	tcgEventNoAction    = 3
+)
+
+// tcgSpecIDEvent03 is the signature of the first event of a crypto agile log
+var tcgSpecIDEvent03 = []byte("Spec ID Event03\x00")
+
+type tpm2Header struct {
+	ACPIHeader
+	PlatformClass uint16
+	Reserved      uint16
+	ControlArea   uint64
+	StartMethod   uint32
+}
+
+// TPM2Table is the decoded ACPI TPM2 table
+type TPM2Table struct {
+	Revision uint8
+	// PlatformClass is 0 for client and 1 for server platforms
+	PlatformClass uint16
+	// ControlArea is the address of the CRB control area or the TIS registers
+	ControlArea uint64
+	StartMethod uint32
+	// StartMethodParameters holds the start method specific parameters
+	StartMethodParameters []byte
+	// LogAreaMinimumLength (LAML) and LogAreaStartAddress (LASA) are zero if
+	// the table doesn't describe an event log
+	LogAreaMinimumLength uint32
+	LogAreaStartAddress  uint64
+}
+
+// TCPATable is the decoded ACPI TCPA table of TPM 1.2 platforms
+type TCPATable struct {
+	PlatformClass        uint16
+	LogAreaMinimumLength uint64
+	LogAreaStartAddress  uint64
+
+	// Server platforms only
+	SpecRevision          uint16
+	DeviceFlags           uint8
+	InterruptFlags        uint8
+	GPE                   uint8
+	GlobalSystemInterrupt uint32
+	BaseAddress           ACPIGenericAddress
+	ConfigAddress         ACPIGenericAddress
+	PCISegment            uint8
+	PCIBus                uint8
+	PCIDevice             uint8
+	PCIFunction           uint8
+}
+
+type tcpaClient struct {
+	ACPIHeader
+	PlatformClass uint16
+	LAML          uint32
+	LASA          uint64
+}
+
+type tcpaServer struct {
+	ACPIHeader
+	PlatformClass         uint16
+	Reserved              uint16
+	LAML                  uint64
+	LASA                  uint64
+	SpecRevision          uint16
+	DeviceFlags           uint8
+	InterruptFlags        uint8
+	GPE                   uint8
+	Reserved2             [3]uint8
+	GlobalSystemInterrupt uint32
+	BaseAddress           ACPIGenericAddress
+	Reserved3             uint32
+	ConfigAddress         ACPIGenericAddress
+	PCISegment            uint8
+	PCIBus                uint8
+	PCIDevice             uint8
+	PCIFunction           uint8
+}
+
+// ParseTPM2Table decodes the ACPI TPM2 table
+func ParseTPM2Table(buf []byte) (*TPM2Table, error) {
+	var hdr tpm2Header
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TPM2 header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "TPM2" {
+		return nil, fmt.Errorf("TPM2 has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("TPM2 has invalid length %d", hdr.Length)
+	}
+
+	ret := TPM2Table{
+		Revision:      hdr.Revision,
+		PlatformClass: hdr.PlatformClass,
+		ControlArea:   hdr.ControlArea,
+		StartMethod:   hdr.StartMethod,
+	}
+	params := buf[binary.Size(hdr):hdr.Length]
+	// LAML and LASA follow the start method parameters, which are at least 4 bytes
+	if len(params) >= 4+tpm2LogFieldsSize {
+		log := params[len(params)-tpm2LogFieldsSize:]
+		params = params[:len(params)-tpm2LogFieldsSize]
+		ret.LogAreaMinimumLength = binary.LittleEndian.Uint32(log)
+		ret.LogAreaStartAddress = binary.LittleEndian.Uint64(log[4:])
+	}
+	ret.StartMethodParameters = params
+
+	return &ret, nil
+}
+
+// ParseTCPATable decodes the client or server variant of the ACPI TCPA table
+func ParseTCPATable(buf []byte) (*TCPATable, error) {
+	var client tcpaClient
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TCPA header: %v", err)
+	}
+	if string(client.Signature[:]) != "TCPA" {
+		return nil, fmt.Errorf("TCPA has invalid signature")
+	}
+	if int(client.Length) > len(buf) {
+		return nil, fmt.Errorf("TCPA has invalid length %d", client.Length)
+	}
+
+	switch client.PlatformClass {
+	case TCPAPlatformClient:
+		if int(client.Length) < binary.Size(client) {
+			return nil, fmt.Errorf("TCPA has invalid length %d", client.Length)
+		}
+		return &TCPATable{
+			PlatformClass:        client.PlatformClass,
+			LogAreaMinimumLength: uint64(client.LAML),
+			LogAreaStartAddress:  client.LASA,
+		}, nil
+	case TCPAPlatformServer:
+		var server tcpaServer
+		if int(client.Length) < binary.Size(server) {
+			return nil, fmt.Errorf("TCPA has invalid length %d", client.Length)
+		}
+		err = binary.Read(bytes.NewReader(buf), binary.LittleEndian, &server)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read TCPA: %v", err)
+		}
+		return &TCPATable{
+			PlatformClass:         server.PlatformClass,
+			LogAreaMinimumLength:  server.LAML,
+			LogAreaStartAddress:   server.LASA,
+			SpecRevision:          server.SpecRevision,
+			DeviceFlags:           server.DeviceFlags,
+			InterruptFlags:        server.InterruptFlags,
+			GPE:                   server.GPE,
+			GlobalSystemInterrupt: server.GlobalSystemInterrupt,
+			BaseAddress:           server.BaseAddress,
+			ConfigAddress:         server.ConfigAddress,
+			PCISegment:            server.PCISegment,
+			PCIBus:                server.PCIBus,
+			PCIDevice:             server.PCIDevice,
+			PCIFunction:           server.PCIFunction,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("TCPA has unknown platform class %d", client.PlatformClass)
+}
+
+// ReadTPM2Table reads the ACPI TPM2 table and decodes it
+func ReadTPM2Table(h LowLevelHardwareInterfaces) (*TPM2Table, error) {
+	buf, err := h.GetACPITable("TPM2")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTPM2Table(buf)
+}
+
+// ReadTCPATable reads the ACPI TCPA table and decodes it
+func ReadTCPATable(h LowLevelHardwareInterfaces) (*TCPATable, error) {
+	buf, err := h.GetACPITable("TCPA")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTCPATable(buf)
+}
+
+// readTPMLogArea reads the log area described by LASA and LAML
+func readTPMLogArea(h LowLevelHardwareInterfaces, lasa, laml uint64) ([]byte, error) {
+	if lasa == 0 || laml == 0 {
+		return nil, fmt.Errorf("no TPM event log area")
+	}
+	if laml > tpmMaxLogSize {
+		return nil, fmt.Errorf("TPM event log area of %d bytes is too big", laml)
+	}
+
+	buf := make([]byte, laml)
+	err := h.ReadPhysBuf(int64(lasa), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TPM event log at %x: %v", lasa, err)
+	}
+	n := tpmEventLogLength(buf)
+	if n == 0 {
+		return nil, fmt.Errorf("TPM event log at %x is empty", lasa)
+	}
+	return buf[:n], nil
+}
+
+// tpm2EventSize returns the size of the TCG_PCR_EVENT2 at the start of buf or
+// zero if it's empty or invalid
+func tpm2EventSize(buf []byte, digestSizes map[uint16]uint16, numAlgs uint32) int {
+	if len(buf) < tcgPCREvent2HdrSize {
+		return 0
+	}
+	typ := binary.LittleEndian.Uint32(buf[4:])
+	if binary.LittleEndian.Uint32(buf[8:]) != numAlgs {
+		return 0
+	}
+	off := tcgPCREvent2HdrSize
+	for i := uint32(0); i < numAlgs; i++ {
+		if off+2 > len(buf) {
+			return 0
+		}
+		size, ok := digestSizes[binary.LittleEndian.Uint16(buf[off:])]
+		if !ok {
+			return 0
+		}
+		off += 2 + int(size)
+	}
+	if off+4 > len(buf) {
+		return 0
+	}
+	size := binary.LittleEndian.Uint32(buf[off:])
+	if typ == 0 && size == 0 {
+		return 0
+	}
+	if uint64(off)+4+uint64(size) > uint64(len(buf)) {
+		return 0
+	}
+	return off + 4 + int(size)
+}
+
+// tpmEventLogLength returns the length of the events at the start of a TPM
+// event log area. Like the Linux kernel, it stops at the first empty or
+// invalid event, dropping the unused space of the log area.
+func tpmEventLogLength(buf []byte) int {
+	// TCG_PCR_EVENT of a TPM 1.2 log, or the header event of a crypto agile log
+	eventSize := func(buf []byte) int {
+		if len(buf) < tcgPCREventHdrSize {
+			return 0
+		}
+		typ := binary.LittleEndian.Uint32(buf[4:])
+		size := binary.LittleEndian.Uint32(buf[28:])
+		if typ == 0 && size == 0 || uint64(tcgPCREventHdrSize)+uint64(size) > uint64(len(buf)) {
+			return 0
+		}
+		return tcgPCREventHdrSize + int(size)
+	}
+
+	off := eventSize(buf)
+	if off == 0 {
+		return 0
+	}
+	spec := buf[tcgPCREventHdrSize:off]
+	if binary.LittleEndian.Uint32(buf[4:]) != tcgEventNoAction || !bytes.HasPrefix(spec, tcgSpecIDEvent03) {
+		for n := eventSize(buf[off:]); n > 0; n = eventSize(buf[off:]) {
+			off += n
+		}
+		return off
+	}
+
+	// the Spec ID event lists the digest sizes of the following events
+	if len(spec) < 28 {
+		return off
+	}
+	numAlgs := binary.LittleEndian.Uint32(spec[24:])
+	if uint64(numAlgs)*4 > uint64(len(spec)-28) {
+		return off
+	}
+	digestSizes := make(map[uint16]uint16)
+	for i := 0; i < int(numAlgs); i++ {
+		alg := spec[28+4*i:]
+		digestSizes[binary.LittleEndian.Uint16(alg)] = binary.LittleEndian.Uint16(alg[2:])
+	}
+	for n := tpm2EventSize(buf[off:], digestSizes, numAlgs); n > 0; n = tpm2EventSize(buf[off:], digestSizes, numAlgs) {
+		off += n
+	}
+	return off
+}
+
+// ReadTPMEventLog reads the firmware TPM event log from the log area described
+// by the TPM2 table, or by the TCPA table on TPM 1.2 platforms. The unused
+// space after the last event is dropped.
+func ReadTPMEventLog(h LowLevelHardwareInterfaces) ([]byte, error) {
+	tpm2, err := ReadTPM2Table(h)
+	if err == nil && tpm2.LogAreaStartAddress != 0 {
+		return readTPMLogArea(h, tpm2.LogAreaStartAddress, uint64(tpm2.LogAreaMinimumLength))
+	}
+
+	tcpa, tcpaErr := ReadTCPATable(h)
+	if tcpaErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("no TPM2 or TCPA table: %v", tcpaErr)
+		}
+		return nil, fmt.Errorf("TPM2 table doesn't describe an event log")
+	}
+	return readTPMLogArea(h, tcpa.LogAreaStartAddress, tcpa.LogAreaMinimumLength)
+}
@@ -0,0 +1,116 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseTPM2Table(t *testing.T) {
+	// CRB with 12 bytes of start method parameters and log area
+	buf := acpiTestTable(t, "TPM2", uint16(0), uint16(0), uint64(0xfed40040), uint32(TPM2StartMethodCRB),
+		[12]byte{}, uint32(0x10000), uint64(0x7f000000))
+	tpm2, err := ParseTPM2Table(buf)
+	if err != nil {
+		t.Fatalf("ParseTPM2Table failed: %v", err)
+	}
+	if tpm2.ControlArea != 0xfed40040 || tpm2.StartMethod != TPM2StartMethodCRB || len(tpm2.StartMethodParameters) != 12 ||
+		tpm2.LogAreaMinimumLength != 0x10000 || tpm2.LogAreaStartAddress != 0x7f000000 {
+		t.Errorf("Unexpected TPM2 table %+v", tpm2)
+	}
+
+	// TIS without log area
+	buf = acpiTestTable(t, "TPM2", uint16(0), uint16(0), uint64(0), uint32(TPM2StartMethodMemoryMapped), uint32(0))
+	tpm2, err = ParseTPM2Table(buf)
+	if err != nil {
+		t.Fatalf("ParseTPM2Table failed: %v", err)
+	}
+	if len(tpm2.StartMethodParameters) != 4 || tpm2.LogAreaStartAddress != 0 {
+		t.Errorf("Unexpected TPM2 table %+v", tpm2)
+	}
+}
+
+func TestParseTCPATable(t *testing.T) {
+	tcpa, err := ParseTCPATable(acpiTestTable(t, "TCPA", uint16(TCPAPlatformClient), uint32(0x10000), uint64(0x7f000000)))
+	if err != nil {
+		t.Fatalf("ParseTCPATable failed: %v", err)
+	}
+	if tcpa.LogAreaMinimumLength != 0x10000 || tcpa.LogAreaStartAddress != 0x7f000000 {
+		t.Errorf("Unexpected TCPA table %+v", tcpa)
+	}
+
+	buf := acpiTestTable(t, "TCPA", uint16(TCPAPlatformServer), uint16(0), uint64(0x20000), uint64(0x7e000000),
+		uint16(0x0102), [6]byte{}, uint32(0), ACPIGenericAddress{AddressSpaceID: ACPIAddressSpaceSystemMemory, Address: 0xfed40000},
+		uint32(0), ACPIGenericAddress{}, []byte{0, 3, 0, 0})
+	tcpa, err = ParseTCPATable(buf)
+	if err != nil {
+		t.Fatalf("ParseTCPATable failed: %v", err)
+	}
+	if tcpa.LogAreaMinimumLength != 0x20000 || tcpa.LogAreaStartAddress != 0x7e000000 || tcpa.SpecRevision != 0x0102 ||
+		tcpa.BaseAddress.Address != 0xfed40000 || tcpa.PCIBus != 3 {
+		t.Errorf("Unexpected TCPA table %+v", tcpa)
+	}
+
+	if _, err := ParseTCPATable(acpiTestTable(t, "TCPA", uint16(TCPAPlatformServer), [12]byte{})); err == nil {
+		t.Errorf("ParseTCPATable accepted a short server table")
+	}
+}
+
+// tpmTestEvent builds a TCG_PCR_EVENT with a SHA1 digest
+func tpmTestEvent(typ uint32, data []byte) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{0, typ})
+	buf.Write(make([]byte, 20))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// tpmTestEvent2 builds a TCG_PCR_EVENT2 with a single SHA256 digest
+func tpmTestEvent2(typ uint32, data []byte) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, []uint32{0, typ, 1})
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(0xb))
+	buf.Write(make([]byte, 32))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestReadTPMEventLog(t *testing.T) {
+	mem := newPhysMemImage()
+	if _, err := ReadTPMEventLog(mem); err == nil {
+		t.Errorf("ReadTPMEventLog succeeded without tables")
+	}
+
+	// TPM 1.2 log followed by unused space
+	log := append(tpmTestEvent(8, []byte("S-CRTM")), tpmTestEvent(4, []byte{0, 0, 0, 0})...)
+	mem.writeBuf(0x7f000000, log)
+	mem.acpi["TCPA"] = acpiTestTable(t, "TCPA", uint16(TCPAPlatformClient), uint32(0x1000), uint64(0x7f000000))
+	got, err := ReadTPMEventLog(mem)
+	if err != nil || !bytes.Equal(got, log) {
+		t.Errorf("ReadTPMEventLog = %x, %v, want %x", got, err, log)
+	}
+
+	// The TPM2 table takes precedence
+	var spec bytes.Buffer
+	spec.Write(tcgSpecIDEvent03)
+	_ = binary.Write(&spec, binary.LittleEndian, []uint32{0, 0x02000200, 1})
+	_ = binary.Write(&spec, binary.LittleEndian, []uint16{0xb, 32})
+	spec.WriteByte(0)
+	log = tpmTestEvent(tcgEventNoAction, spec.Bytes())
+	log = append(log, tpmTestEvent2(8, []byte("S-CRTM"))...)
+	log = append(log, tpmTestEvent2(4, []byte{0, 0, 0, 0})...)
+	mem.writeBuf(0x7e000000, log)
+	mem.acpi["TPM2"] = acpiTestTable(t, "TPM2", uint16(0), uint16(0), uint64(0), uint32(TPM2StartMethodCRB),
+		[12]byte{}, uint32(0x1000), uint64(0x7e000000))
+	got, err = ReadTPMEventLog(mem)
+	if err != nil || !bytes.Equal(got, log) {
+		t.Errorf("ReadTPMEventLog = %x, %v, want %x", got, err, log)
+	}
+
+	mem.writeBuf(0x7e000000, make([]byte, len(log)))
+	if _, err := ReadTPMEventLog(mem); err == nil {
+		t.Errorf("ReadTPMEventLog accepted an empty log area")
+	}
+}
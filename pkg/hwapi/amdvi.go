@@ -0,0 +1,329 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// amdviRegStatus is the offset of the IOMMU status register
+	amdviRegStatus = 0x2020
+	// amdviDTESize is the size of a device table entry
+	amdviDTESize = 32
+)
+
+// AMDVIRegisters holds the AMD IOMMU MMIO control registers
+type AMDVIRegisters struct {
+	DeviceTableBase     uint64
+	CommandBufferBase   uint64
+	EventLogBase        uint64
+	Control             uint64
+	ExclusionBase       uint64
+	ExclusionLimit      uint64
+	ExtendedFeatures    uint64
+	PPRLogBase          uint64
+	HardwareEventHigh   uint64
+	HardwareEventLow    uint64
+	HardwareEventStatus uint64
+	Reserved            uint64
+	SMIFilter           [16]uint64
+	GALogBase           uint64
+	GALogTailAddress    uint64
+	PPRLogBBase         uint64
+	EventLogBBase       uint64
+	DeviceTableSeg      [7]uint64
+}
+
+// AMDVIControl encodes the IOMMU control register
+type AMDVIControl struct {
+	IOMMUEnable            bool
+	EventLogEnable         bool
+	CommandBufferEnable    bool
+	Coherent               bool
+	GuestTranslationEnable bool
+	GuestAPICEnable        bool
+}
+
+// AMDVIStatus encodes the IOMMU status register
+type AMDVIStatus struct {
+	EventOverflow    bool
+	EventLogRunning  bool
+	CommandBufferRun bool
+	PPROverflow      bool
+	PPRLogRunning    bool
+}
+
+// AMDVIUnit is an AMD IOMMU described by the IVRS table
+type AMDVIUnit struct {
+	Base    uint64
+	Segment uint16
+	// DeviceID is the requester ID of the IOMMU itself
+	DeviceID uint16
+	// DeviceIDs are the requester IDs of the devices behind the IOMMU
+	DeviceIDs []uint16
+	Regs      AMDVIRegisters
+	// StatusRegister is the IOMMU status register, located apart from the other registers
+	StatusRegister uint64
+}
+
+// Control decodes the control register
+func (u *AMDVIUnit) Control() AMDVIControl {
+	c := u.Regs.Control
+	return AMDVIControl{
+		IOMMUEnable:            c&(1<<0) != 0,
+		EventLogEnable:         c&(1<<2) != 0,
+		Coherent:               c&(1<<10) != 0,
+		CommandBufferEnable:    c&(1<<12) != 0,
+		GuestTranslationEnable: c&(1<<16) != 0,
+		GuestAPICEnable:        c&(1<<17) != 0,
+	}
+}
+
+// Status decodes the status register
+func (u *AMDVIUnit) Status() AMDVIStatus {
+	s := u.StatusRegister
+	return AMDVIStatus{
+		EventOverflow:    s&(1<<0) != 0,
+		EventLogRunning:  s&(1<<3) != 0,
+		CommandBufferRun: s&(1<<4) != 0,
+		PPROverflow:      s&(1<<5) != 0,
+		PPRLogRunning:    s&(1<<7) != 0,
+	}
+}
+
+// ExclusionRange returns the inclusive range excluded from translation and
+// whether it's enabled. If allDevices is set, every device may access the
+// range, otherwise only devices with the EX bit set in their device table entry.
+func (u *AMDVIUnit) ExclusionRange() (first, end uint64, enabled, allDevices bool) {
+	return u.Regs.ExclusionBase & vtdAddrMask, u.Regs.ExclusionLimit&vtdAddrMask | 0xfff,
+		u.Regs.ExclusionBase&1 != 0, u.Regs.ExclusionBase&2 != 0
+}
+
+func readAMDVIRegsAt(l LowLevelHardwareInterfaces, addr uint64) (AMDVIRegisters, error) {
+	var regs AMDVIRegisters
+
+	buf := make([]byte, binary.Size(regs))
+	err := l.ReadPhysBuf(int64(addr), buf)
+	if err != nil {
+		return regs, err
+	}
+
+	reader := bytes.NewReader(buf)
+	err = binary.Read(reader, binary.LittleEndian, &regs)
+	if err != nil {
+		return regs, err
+	}
+
+	return regs, nil
+}
+
+// ReadAMDVIUnits returns all AMD IOMMUs described by the IVRS table
+func ReadAMDVIUnits(l LowLevelHardwareInterfaces) ([]AMDVIUnit, error) {
+	var ret []AMDVIUnit
+
+	ivrs, err := ReadIVRS(l)
+	if err != nil {
+		return nil, err
+	}
+	for _, ivhd := range ivrs.PreferredIVHDs() {
+		regs, err := readAMDVIRegsAt(l, ivhd.BaseAddress)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read IOMMU at %x: %v", ivhd.BaseAddress, err)
+		}
+		var status Uint64
+		err = l.ReadPhys(int64(ivhd.BaseAddress+amdviRegStatus), &status)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read IOMMU at %x: %v", ivhd.BaseAddress, err)
+		}
+		ret = append(ret, AMDVIUnit{
+			Base:           ivhd.BaseAddress,
+			Segment:        ivhd.Segment,
+			DeviceID:       ivhd.DeviceID,
+			DeviceIDs:      ivhd.DeviceIDs(),
+			Regs:           regs,
+			StatusRegister: uint64(status),
+		})
+	}
+
+	return ret, nil
+}
+
+// readAMDVIDeviceTable reads the whole device table of the IOMMU
+func readAMDVIDeviceTable(l LowLevelHardwareInterfaces, unit *AMDVIUnit) ([]byte, error) {
+	base := unit.Regs.DeviceTableBase & vtdAddrMask
+	size := ((unit.Regs.DeviceTableBase & 0x1ff) + 1) * 4096
+
+	buf := make([]byte, size)
+	err := l.ReadPhysBuf(int64(base), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read device table: %v", err)
+	}
+	return buf, nil
+}
+
+// amdviDTE is the translation relevant part of a device table entry
+type amdviDTE struct {
+	Valid            bool
+	TranslationValid bool
+	// Mode is the number of page table levels, 0 if translation is disabled
+	Mode      int
+	TableRoot uint64
+	Read      bool
+	Write     bool
+	Exclusion bool
+}
+
+func decodeAMDVIDTE(buf []byte) amdviDTE {
+	q0 := binary.LittleEndian.Uint64(buf)
+	q1 := binary.LittleEndian.Uint64(buf[8:])
+	return amdviDTE{
+		Valid:            q0&(1<<0) != 0,
+		TranslationValid: q0&(1<<1) != 0,
+		Mode:             int(q0>>9) & 7,
+		TableRoot:        q0 & vtdAddrMask,
+		Read:             q0&(1<<61) != 0,
+		Write:            q0&(1<<62) != 0,
+		Exclusion:        q1&(1<<39) != 0,
+	}
+}
+
+// iterateAMDVIDevices invokes the callback for every device behind the IOMMU
+// with an entry in the device table
+func iterateAMDVIDevices(l LowLevelHardwareInterfaces, unit *AMDVIUnit, callback func(id uint16, dte amdviDTE) error) error {
+	table, err := readAMDVIDeviceTable(l, unit)
+	if err != nil {
+		return err
+	}
+	for _, id := range unit.DeviceIDs {
+		off := int(id) * amdviDTESize
+		if off+amdviDTESize > len(table) {
+			continue
+		}
+		err = callback(id, decodeAMDVIDTE(table[off:]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LookupAMDVIAddress translates the I/O virtual address for every device behind the IOMMU
+func LookupAMDVIAddress(l LowLevelHardwareInterfaces, unit *AMDVIUnit, addr uint64) ([]IOTranslation, error) {
+	ret := []IOTranslation{}
+	exFirst, exEnd, exEnabled, exAll := unit.ExclusionRange()
+	inExclusion := exEnabled && exFirst <= addr && addr <= exEnd
+
+	err := iterateAMDVIDevices(l, unit, func(id uint16, dte amdviDTE) error {
+		t := IOTranslation{Bus: int(id >> 8), Device: int(id>>3) & 0x1f, Function: int(id) & 7}
+
+		switch {
+		case !dte.Valid || !dte.TranslationValid || (inExclusion && (exAll || dte.Exclusion)):
+			t.Address = addr
+			t.PageSize = 4096
+			t.Read = true
+			t.Write = true
+			t.PassThrough = true
+		case dte.Mode == 0:
+			if !dte.Read && !dte.Write {
+				return nil
+			}
+			t.Address = addr
+			t.PageSize = 4096
+			t.Read = dte.Read
+			t.Write = dte.Write
+			t.PassThrough = true
+		case dte.Mode <= 6:
+			if dte.Mode < 6 && addr>>uint(12+9*dte.Mode) != 0 {
+				return nil
+			}
+			x, err := walkIOPageTable(l, dte.TableRoot, dte.Mode, ioPageTableAMD, addr, nil)
+			if err != nil || x == nil {
+				return err
+			}
+			t.Address = x.Address
+			t.PageSize = x.PageSize
+			t.Read = x.Read && dte.Read
+			t.Write = x.Write && dte.Write
+		default:
+			return nil
+		}
+		ret = append(ret, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// FindAMDVIDMAMappings returns the mappings of all devices behind the AMD IOMMU
+// that reach into the physical range [first; end]
+func FindAMDVIDMAMappings(l LowLevelHardwareInterfaces, unit *AMDVIUnit, first, end uint64) ([]IOMMUMapping, error) {
+	ret := []IOMMUMapping{}
+
+	if first > end {
+		return nil, fmt.Errorf("invalid range")
+	}
+	c := newIOMappingCollector(l, first, end)
+
+	if !unit.Control().IOMMUEnable {
+		return append(ret, c.passThrough(IOMMUMapping{Unit: unit.Base, AllDevices: true})), nil
+	}
+
+	exFirst, exEnd, exEnabled, exAll := unit.ExclusionRange()
+	inExclusion := exEnabled && exFirst <= end && exEnd >= first
+
+	err := iterateAMDVIDevices(l, unit, func(id uint16, dte amdviDTE) error {
+		tmpl := IOMMUMapping{Unit: unit.Base, Bus: int(id >> 8), Device: int(id>>3) & 0x1f, Function: int(id) & 7}
+
+		switch {
+		case !dte.Valid || !dte.TranslationValid:
+			ret = append(ret, c.passThrough(tmpl))
+			return nil
+		case dte.Mode == 0:
+			if dte.Read || dte.Write {
+				m := c.passThrough(tmpl)
+				m.Read = dte.Read
+				m.Write = dte.Write
+				ret = append(ret, m)
+			}
+			return nil
+		}
+
+		if inExclusion && (exAll || dte.Exclusion) {
+			m := tmpl
+			m.Address, m.IOVA = exFirst, exFirst
+			if m.Address < first {
+				m.Address, m.IOVA = first, first
+			}
+			m.Size = exEnd - m.Address + 1
+			if exEnd > end {
+				m.Size = end - m.Address + 1
+			}
+			m.Read, m.Write, m.PassThrough = true, true, true
+			ret = append(ret, m)
+		}
+		if dte.Mode > 6 || (!dte.Read && !dte.Write) {
+			return nil
+		}
+
+		n := len(ret)
+		var err error
+		ret, err = c.appendLeaves(ret, tmpl, dte.TableRoot, dte.Mode, ioPageTableAMD)
+		if err != nil {
+			return err
+		}
+		for i := n; i < len(ret); i++ {
+			ret[i].Read = ret[i].Read && dte.Read
+			ret[i].Write = ret[i].Write && dte.Write
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
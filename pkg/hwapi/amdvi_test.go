@@ -0,0 +1,76 @@
+package hwapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAMDVIAddressTranslation(t *testing.T) {
+	mem := newPhysMemImage()
+	const pteRW = 3 << 61
+
+	// device table at 0x100000, 2 pages for requester IDs up to 0x7f
+	unit := AMDVIUnit{
+		Base:      0xfd200000,
+		DeviceIDs: []uint16{0x08, 0x10, 0x18, 0x20},
+		Regs: AMDVIRegisters{
+			DeviceTableBase: 0x100000 | 1,
+			Control:         1,
+		},
+	}
+
+	// 00:01.0: 3-level table, level 3 skips to level 1, 4 KiB page 0x5000 -> 0x40005000
+	mem.write64(0x100000+0x08*32, pteRW|0x10000|3<<9|3)
+	mem.write64(0x10000, pteRW|0x11000|1<<9|1)
+	mem.write64(0x11000+5*8, pteRW|0x40005000|1)
+
+	// 00:02.0: 2-level table, 2 MiB page 0x0 -> 0x40000000 read only
+	mem.write64(0x100000+0x10*32, 1<<61|0x20000|2<<9|3)
+	mem.write64(0x20000, pteRW|0x40000000|1)
+
+	// 00:03.0: 1-level table, 16 KiB page 0x4000 -> 0x40004000 replicated over 4 entries
+	mem.write64(0x100000+0x18*32, pteRW|0x30000|1<<9|3)
+	for i := uint64(4); i < 8; i++ {
+		mem.write64(0x30000+i*8, pteRW|0x40004000|1<<12|7<<9|1)
+	}
+
+	// 00:04.0: translation information not valid
+	mem.write64(0x100000+0x20*32, 1)
+
+	got, err := LookupAMDVIAddress(mem, &unit, 0x5678)
+	if err != nil {
+		t.Fatalf("LookupAMDVIAddress failed: %v", err)
+	}
+	want := []IOTranslation{
+		{Bus: 0, Device: 1, Function: 0, Address: 0x40005678, PageSize: 1 << 12, Read: true, Write: true},
+		{Bus: 0, Device: 2, Function: 0, Address: 0x40005678, PageSize: 1 << 21, Read: true},
+		{Bus: 0, Device: 3, Function: 0, Address: 0x40005678, PageSize: 1 << 14, Read: true, Write: true},
+		{Bus: 0, Device: 4, Function: 0, Address: 0x5678, PageSize: 1 << 12, Read: true, Write: true, PassThrough: true},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Got %+v, want %+v", got, want)
+	}
+
+	// the skipped level must be zero
+	got, err = LookupAMDVIAddress(mem, &unit, 1<<21|0x5678)
+	if err != nil {
+		t.Fatalf("LookupAMDVIAddress failed: %v", err)
+	}
+	if len(got) != 1 || !got[0].PassThrough {
+		t.Errorf("Got %+v, want only the pass-through device", got)
+	}
+
+	mappings, err := FindAMDVIDMAMappings(mem, &unit, 0x40004000, 0x40004fff)
+	if err != nil {
+		t.Fatalf("FindAMDVIDMAMappings failed: %v", err)
+	}
+	wantMappings := []IOMMUMapping{
+		{Unit: 0xfd200000, Device: 2, IOVA: 0x4000, Address: 0x40004000, Size: 0x1000, Read: true},
+		{Unit: 0xfd200000, Device: 3, IOVA: 0x4000, Address: 0x40004000, Size: 0x1000, Read: true, Write: true},
+		{Unit: 0xfd200000, Device: 4, IOVA: 0x40004000, Address: 0x40004000, Size: 0x1000, Read: true, Write: true,
+			PassThrough: true},
+	}
+	if fmt.Sprint(mappings) != fmt.Sprint(wantMappings) {
+		t.Errorf("Got %v, want %v", mappings, wantMappings)
+	}
+}
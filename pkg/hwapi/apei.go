@@ -0,0 +1,201 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// apeiMaxBootErrorRegion limits the boot error region read from physical memory
+	apeiMaxBootErrorRegion = 1 << 20
+)
+
+type bertRaw struct {
+	ACPIHeader
+	BootErrorRegionLength uint32
+	BootErrorRegion       uint64
+}
+
+// BERT is the decoded ACPI Boot Error Record Table
+type BERT struct {
+	// BootErrorRegion is the physical address of the generic error status
+	// blocks describing errors of the previous boot
+	BootErrorRegion       uint64
+	BootErrorRegionLength uint32
+}
+
+// APEIInstruction is a serialization or injection instruction entry of the ERST and EINJ tables
+type APEIInstruction struct {
+	Action      uint8
+	Instruction uint8
+	Flags       uint8
+	Reserved    uint8
+	Register    ACPIGenericAddress
+	Value       uint64
+	Mask        uint64
+}
+
+// ERST is the decoded ACPI Error Record Serialization Table
+type ERST struct {
+	Instructions []APEIInstruction
+}
+
+// EINJ is the decoded ACPI Error Injection Table
+type EINJ struct {
+	Flags        uint8
+	Instructions []APEIInstruction
+}
+
+// ParseBERT decodes the ACPI BERT table
+func ParseBERT(buf []byte) (*BERT, error) {
+	var raw bertRaw
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read BERT: %v", err)
+	}
+	if string(raw.Signature[:]) != "BERT" {
+		return nil, fmt.Errorf("BERT has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw) {
+		return nil, fmt.Errorf("BERT has invalid length %d", raw.Length)
+	}
+
+	return &BERT{BootErrorRegion: raw.BootErrorRegion, BootErrorRegionLength: raw.BootErrorRegionLength}, nil
+}
+
+// ReadBERT reads the ACPI BERT table and decodes it
+func ReadBERT(h LowLevelHardwareInterfaces) (*BERT, error) {
+	buf, err := h.GetACPITable("BERT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBERT(buf)
+}
+
+// ReadBootErrors reads the boot error region of the BERT and decodes its
+// generic error status blocks. Returns nothing if no error was recorded.
+func ReadBootErrors(h LowLevelHardwareInterfaces) ([]GenericErrorStatus, error) {
+	var ret []GenericErrorStatus
+
+	bert, err := ReadBERT(h)
+	if err != nil {
+		return nil, err
+	}
+	if bert.BootErrorRegion == 0 || bert.BootErrorRegionLength == 0 {
+		return nil, nil
+	}
+	if bert.BootErrorRegionLength > apeiMaxBootErrorRegion {
+		return nil, fmt.Errorf("boot error region of %d bytes is too big", bert.BootErrorRegionLength)
+	}
+
+	buf := make([]byte, bert.BootErrorRegionLength)
+	err = h.ReadPhysBuf(int64(bert.BootErrorRegion), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read boot error region at %x: %v", bert.BootErrorRegion, err)
+	}
+	for len(buf) >= binary.Size(genericErrorStatusRaw{}) {
+		// an empty block status terminates the region, the rest may be garbage
+		if binary.LittleEndian.Uint32(buf) == 0 {
+			break
+		}
+		status, size, err := ParseGenericErrorStatus(buf)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *status)
+		buf = buf[size:]
+	}
+
+	return ret, nil
+}
+
+// parseAPEIInstructions decodes count instruction entries at the start of buf
+func parseAPEIInstructions(buf []byte, count uint32, name string) ([]APEIInstruction, error) {
+	if uint64(count)*uint64(binary.Size(APEIInstruction{})) > uint64(len(buf)) {
+		return nil, fmt.Errorf("%s is too short for %d instruction entries", name, count)
+	}
+	ret := make([]APEIInstruction, count)
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, ret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s instruction entries: %v", name, err)
+	}
+	return ret, nil
+}
+
+// ParseERST decodes the ACPI ERST table
+func ParseERST(buf []byte) (*ERST, error) {
+	var raw struct {
+		ACPIHeader
+		HeaderSize uint32
+		Reserved   uint32
+		EntryCount uint32
+	}
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ERST: %v", err)
+	}
+	if string(raw.Signature[:]) != "ERST" {
+		return nil, fmt.Errorf("ERST has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw) {
+		return nil, fmt.Errorf("ERST has invalid length %d", raw.Length)
+	}
+	entries, err := parseAPEIInstructions(buf[binary.Size(raw):raw.Length], raw.EntryCount, "ERST")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ERST{Instructions: entries}, nil
+}
+
+// ReadERST reads the ACPI ERST table and decodes it
+func ReadERST(h LowLevelHardwareInterfaces) (*ERST, error) {
+	buf, err := h.GetACPITable("ERST")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseERST(buf)
+}
+
+// ParseEINJ decodes the ACPI EINJ table
+func ParseEINJ(buf []byte) (*EINJ, error) {
+	var raw struct {
+		ACPIHeader
+		HeaderSize uint32
+		Flags      uint8
+		Reserved   [3]uint8
+		EntryCount uint32
+	}
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read EINJ: %v", err)
+	}
+	if string(raw.Signature[:]) != "EINJ" {
+		return nil, fmt.Errorf("EINJ has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw) {
+		return nil, fmt.Errorf("EINJ has invalid length %d", raw.Length)
+	}
+	entries, err := parseAPEIInstructions(buf[binary.Size(raw):raw.Length], raw.EntryCount, "EINJ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &EINJ{Flags: raw.Flags, Instructions: entries}, nil
+}
+
+// ReadEINJ reads the ACPI EINJ table and decodes it
+func ReadEINJ(h LowLevelHardwareInterfaces) (*EINJ, error) {
+	buf, err := h.GetACPITable("EINJ")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseEINJ(buf)
+}
@@ -0,0 +1,62 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestReadBootErrors(t *testing.T) {
+	mem := newPhysMemImage()
+	mem.acpi["BERT"] = acpiTestTable(t, "BERT", uint32(0x1000), uint64(0x7b000000))
+
+	errs, err := ReadBootErrors(mem)
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("ReadBootErrors = %+v, %v on empty region", errs, err)
+	}
+
+	block := cperTestBlock(GenericErrorUncorrectable,
+		cperTestSection(t, CPERSectionPlatformMemory, 0x300, CPERMemoryError{PhysicalAddress: 0x1000}))
+	mem.writeBuf(0x7b000000, block)
+	// unused space after the last block isn't parsed
+	mem.writeBuf(0x7b000000+uint64(len(block)), []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	errs, err = ReadBootErrors(mem)
+	if err != nil {
+		t.Fatalf("ReadBootErrors failed: %v", err)
+	}
+	if len(errs) != 1 || len(errs[0].Sections) != 1 || errs[0].Sections[0].Memory.PhysicalAddress != 0x1000 {
+		t.Errorf("Unexpected boot errors %+v", errs)
+	}
+
+	mem.acpi["BERT"] = acpiTestTable(t, "BERT", uint32(apeiMaxBootErrorRegion+1), uint64(0x7b000000))
+	if _, err := ReadBootErrors(mem); err == nil {
+		t.Errorf("Oversized boot error region wasn't rejected")
+	}
+}
+
+func TestParseERSTAndEINJ(t *testing.T) {
+	inst := APEIInstruction{
+		Action:      3,
+		Instruction: 2,
+		Register:    ACPIGenericAddress{AddressSpaceID: ACPIAddressSpaceSystemMemory, RegisterBitWidth: 64, Address: 0xfed40000},
+		Value:       1,
+		Mask:        0xff,
+	}
+
+	erst, err := ParseERST(acpiTestTable(t, "ERST", uint32(48), uint32(0), uint32(2), inst, inst))
+	if err != nil {
+		t.Fatalf("ParseERST failed: %v", err)
+	}
+	if len(erst.Instructions) != 2 || erst.Instructions[1] != inst {
+		t.Errorf("Unexpected ERST %+v", erst)
+	}
+	if _, err := ParseERST(acpiTestTable(t, "ERST", uint32(48), uint32(0), uint32(3), inst)); err == nil {
+		t.Errorf("Truncated ERST wasn't detected")
+	}
+
+	einj, err := ParseEINJ(acpiTestTable(t, "EINJ", uint32(48), uint8(1), [3]uint8{}, uint32(1), inst))
+	if err != nil {
+		t.Fatalf("ParseEINJ failed: %v", err)
+	}
+	if einj.Flags != 1 || len(einj.Instructions) != 1 || einj.Instructions[0].Register.Address != 0xfed40000 {
+		t.Errorf("Unexpected EINJ %+v", einj)
+	}
+}
@@ -19,7 +19,7 @@ type LowLevelHardwareInterfaces interface {
 	IterateOverE820Ranges(target string, callback func(start uint64, end uint64) bool) (bool, error)
 
 	// iommu.go
-	LookupIOAddress(addr uint64, regs VTdRegisters) ([]uint64, error)
+	LookupIOAddress(addr uint64, regs VTdRegisters) ([]IOTranslation, error)
 
 	// msr.go
 	ReadMSR(msr int64) uint64
@@ -42,7 +42,9 @@ type LowLevelHardwareInterfaces interface {
 	ReadPCR(tpmCon *TPM, pcr uint32) ([]byte, error)
 
 	// acpi.go
-	GetACPITable(n string) ([]byte, error)
+	GetACPITable(n string, opts ...ACPITableOption) ([]byte, error)
+	GetACPITables(sig string, opts ...ACPITableOption) ([][]byte, error)
+	EnumerateACPITables() ([]ACPITableInfo, error)
 
 	// smbios.go
 	IterateOverSMBIOSTables(n uint8, callback func(s *smbios.Structure) bool) (ret bool, err error)
@@ -0,0 +1,78 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// BGRT image types
+const (
+	BGRTImageTypeBitmap = 0
+)
+
+type bgrtRaw struct {
+	ACPIHeader
+	Version      uint16
+	Status       uint8
+	ImageType    uint8
+	ImageAddress uint64
+	ImageOffsetX uint32
+	ImageOffsetY uint32
+}
+
+// BGRT is the decoded ACPI Boot Graphics Resource Table
+type BGRT struct {
+	Version   uint16
+	Status    uint8
+	ImageType uint8
+	// ImageAddress is the physical address of the boot logo
+	ImageAddress uint64
+	ImageOffsetX uint32
+	ImageOffsetY uint32
+}
+
+// Displayed returns true if the boot logo is currently shown on the screen
+func (b *BGRT) Displayed() bool {
+	return b.Status&1 != 0
+}
+
+// Orientation returns the clockwise rotation of the boot logo in degrees
+func (b *BGRT) Orientation() int {
+	return int((b.Status>>1)&3) * 90
+}
+
+// ParseBGRT decodes the ACPI BGRT table
+func ParseBGRT(buf []byte) (*BGRT, error) {
+	var raw bgrtRaw
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read BGRT: %v", err)
+	}
+	if string(raw.Signature[:]) != "BGRT" {
+		return nil, fmt.Errorf("BGRT has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw) {
+		return nil, fmt.Errorf("BGRT has invalid length %d", raw.Length)
+	}
+
+	return &BGRT{
+		Version:      raw.Version,
+		Status:       raw.Status,
+		ImageType:    raw.ImageType,
+		ImageAddress: raw.ImageAddress,
+		ImageOffsetX: raw.ImageOffsetX,
+		ImageOffsetY: raw.ImageOffsetY,
+	}, nil
+}
+
+// ReadBGRT reads the ACPI BGRT table and decodes it
+func ReadBGRT(h LowLevelHardwareInterfaces) (*BGRT, error) {
+	buf, err := h.GetACPITable("BGRT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBGRT(buf)
+}
@@ -0,0 +1,17 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseBGRT(t *testing.T) {
+	bgrt, err := ParseBGRT(acpiTestTable(t, "BGRT", uint16(1), uint8(0x3), uint8(BGRTImageTypeBitmap),
+		uint64(0x7b000000), uint32(100), uint32(200)))
+	if err != nil {
+		t.Fatalf("ParseBGRT failed: %v", err)
+	}
+	if !bgrt.Displayed() || bgrt.Orientation() != 90 || bgrt.ImageAddress != 0x7b000000 ||
+		bgrt.ImageOffsetX != 100 || bgrt.ImageOffsetY != 200 {
+		t.Errorf("Unexpected BGRT %+v", bgrt)
+	}
+}
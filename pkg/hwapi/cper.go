@@ -0,0 +1,378 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// GUID is a GUID in the mixed-endian EFI byte order
+type GUID [16]byte
+
+func (g GUID) String() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:])
+}
+
+// CPER section types
+var (
+	CPERSectionProcessorGeneric = GUID{0xad, 0xcc, 0x76, 0x98, 0xb4, 0x47, 0xdb, 0x4b, 0xb6, 0x5e, 0x16, 0xf1, 0x93, 0xc4, 0xf3, 0xdb}
+	CPERSectionIA32X64Processor = GUID{0xb0, 0xa0, 0x3e, 0xdc, 0x44, 0xa1, 0x97, 0x47, 0xb9, 0x5b, 0x53, 0xfa, 0x24, 0x2b, 0x6e, 0x1d}
+	CPERSectionPlatformMemory   = GUID{0x14, 0x11, 0xbc, 0xa5, 0x64, 0x6f, 0xde, 0x4e, 0xb8, 0x63, 0x3e, 0x83, 0xed, 0x7c, 0x83, 0xb1}
+	CPERSectionPCIe             = GUID{0x54, 0xe9, 0x95, 0xd9, 0xc1, 0xbb, 0x0f, 0x43, 0xad, 0x91, 0xb4, 0x4d, 0xcb, 0x3c, 0x6f, 0x35}
+)
+
+// CPER error severities
+const (
+	CPERSeverityRecoverable = 0
+	CPERSeverityFatal       = 1
+	CPERSeverityCorrected   = 2
+	CPERSeverityInfo        = 3
+)
+
+// Generic error status block status bits
+const (
+	GenericErrorUncorrectable         = 1 << 0
+	GenericErrorCorrectable           = 1 << 1
+	GenericErrorMultipleUncorrectable = 1 << 2
+	GenericErrorMultipleCorrectable   = 1 << 3
+)
+
+const (
+	// cperSectionTimestampRevision is the first generic error data entry revision with timestamp
+	cperSectionTimestampRevision = 0x300
+	// cperValidFRUID and cperValidFRUText are the generic error data entry validation bits
+	cperValidFRUID   = 1 << 0
+	cperValidFRUText = 1 << 1
+)
+
+var cperSeverityNames = map[uint32]string{
+	CPERSeverityRecoverable: "recoverable",
+	CPERSeverityFatal:       "fatal",
+	CPERSeverityCorrected:   "corrected",
+	CPERSeverityInfo:        "informational",
+}
+
+var cperMemoryErrorTypes = map[uint8]string{
+	0:  "unknown",
+	1:  "no error",
+	2:  "single-bit ECC",
+	3:  "multi-bit ECC",
+	4:  "single-symbol chipkill ECC",
+	5:  "multi-symbol chipkill ECC",
+	6:  "master abort",
+	7:  "target abort",
+	8:  "parity error",
+	9:  "watchdog timeout",
+	10: "invalid address",
+	11: "mirror broken",
+	12: "memory sparing",
+	13: "scrub corrected error",
+	14: "scrub uncorrected error",
+	15: "physical memory map-out event",
+}
+
+// CPERSeverityString returns the name of the error severity
+func CPERSeverityString(severity uint32) string {
+	if s, ok := cperSeverityNames[severity]; ok {
+		return s
+	}
+	return fmt.Sprintf("severity %d", severity)
+}
+
+// CPERProcessorGeneric is a processor generic error section
+type CPERProcessorGeneric struct {
+	ValidationBits uint64
+	ProcessorType  uint8
+	ProcessorISA   uint8
+	ErrorType      uint8
+	Operation      uint8
+	Flags          uint8
+	Level          uint8
+	Reserved       uint16
+	CPUVersion     uint64
+	BrandString    [128]byte
+	ProcessorID    uint64
+	TargetAddress  uint64
+	RequestorID    uint64
+	ResponderID    uint64
+	InstructionIP  uint64
+}
+
+// CPERIA32X64ErrorInfo is a processor error information structure of an IA32/X64 processor error section
+type CPERIA32X64ErrorInfo struct {
+	Type           GUID
+	ValidationBits uint64
+	CheckInfo      uint64
+	TargetID       uint64
+	RequestorID    uint64
+	ResponderID    uint64
+	InstructionIP  uint64
+}
+
+// CPERIA32X64Processor is an IA32/X64 processor error section. The processor
+// context information following the error information isn't decoded.
+type CPERIA32X64Processor struct {
+	ValidationBits uint64
+	LocalAPICID    uint64
+	CPUID          [48]byte
+	ErrorInfo      []CPERIA32X64ErrorInfo
+}
+
+// CPERMemoryError is a platform memory error section
+type CPERMemoryError struct {
+	ValidationBits      uint64
+	ErrorStatus         uint64
+	PhysicalAddress     uint64
+	PhysicalAddressMask uint64
+	Node                uint16
+	Card                uint16
+	Module              uint16
+	Bank                uint16
+	Device              uint16
+	Row                 uint16
+	Column              uint16
+	BitPosition         uint16
+	RequestorID         uint64
+	ResponderID         uint64
+	TargetID            uint64
+	ErrorType           uint8
+	Extended            uint8
+	RankNumber          uint16
+	CardHandle          uint16
+	ModuleHandle        uint16
+}
+
+// ErrorTypeString returns the name of the memory error type
+func (m *CPERMemoryError) ErrorTypeString() string {
+	if s, ok := cperMemoryErrorTypes[m.ErrorType]; ok {
+		return s
+	}
+	return fmt.Sprintf("memory error type %d", m.ErrorType)
+}
+
+// CPERPCIeError is a PCI express error section
+type CPERPCIeError struct {
+	ValidationBits      uint64
+	PortType            uint32
+	Version             uint32
+	Command             uint16
+	Status              uint16
+	Reserved            uint32
+	VendorID            uint16
+	DeviceID            uint16
+	ClassCode           [3]uint8
+	Function            uint8
+	Device              uint8
+	Segment             uint16
+	Bus                 uint8
+	SecondaryBus        uint8
+	Slot                uint16
+	Reserved2           uint8
+	SerialNumber        uint64
+	BridgeStatus        uint16
+	BridgeControl       uint16
+	CapabilityStructure [60]byte
+	AERInfo             [96]byte
+}
+
+// UncorrectableErrorStatus returns the AER uncorrectable error status register
+func (p *CPERPCIeError) UncorrectableErrorStatus() uint32 {
+	return binary.LittleEndian.Uint32(p.AERInfo[4:])
+}
+
+// CorrectableErrorStatus returns the AER correctable error status register
+func (p *CPERPCIeError) CorrectableErrorStatus() uint32 {
+	return binary.LittleEndian.Uint32(p.AERInfo[0x10:])
+}
+
+// CPERSection is a generic error data entry. The section is decoded into
+// one of the typed fields if the section type is known.
+type CPERSection struct {
+	Type     GUID
+	Severity uint32
+	Revision uint16
+	Flags    uint8
+	// FRUID and FRUText are only set if valid
+	FRUID     *GUID
+	FRUText   string
+	Timestamp uint64
+	Data      []byte
+
+	ProcessorGeneric *CPERProcessorGeneric
+	IA32X64          *CPERIA32X64Processor
+	Memory           *CPERMemoryError
+	PCIe             *CPERPCIeError
+}
+
+// GenericErrorStatus is a decoded generic error status block
+type GenericErrorStatus struct {
+	BlockStatus uint32
+	Severity    uint32
+	Sections    []CPERSection
+	RawData     []byte
+}
+
+type genericErrorStatusRaw struct {
+	BlockStatus   uint32
+	RawDataOffset uint32
+	RawDataLength uint32
+	DataLength    uint32
+	ErrorSeverity uint32
+}
+
+type genericErrorDataEntryRaw struct {
+	SectionType     GUID
+	ErrorSeverity   uint32
+	Revision        uint16
+	ValidationBits  uint8
+	Flags           uint8
+	ErrorDataLength uint32
+	FRUID           GUID
+	FRUText         [20]byte
+}
+
+func parseCPERIA32X64(data []byte) (*CPERIA32X64Processor, error) {
+	var ret CPERIA32X64Processor
+	var raw struct {
+		ValidationBits uint64
+		LocalAPICID    uint64
+		CPUID          [48]byte
+	}
+
+	err := readCPERSection(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	ret.ValidationBits = raw.ValidationBits
+	ret.LocalAPICID = raw.LocalAPICID
+	ret.CPUID = raw.CPUID
+
+	// error information structures not present in a short section are dropped
+	hdrSize := binary.Size(raw)
+	count := int((raw.ValidationBits >> 2) & 0x3f)
+	if len(data) < hdrSize {
+		count = 0
+	} else if n := (len(data) - hdrSize) / binary.Size(CPERIA32X64ErrorInfo{}); n < count {
+		count = n
+	}
+	ret.ErrorInfo = make([]CPERIA32X64ErrorInfo, count)
+	if count > 0 {
+		err = binary.Read(bytes.NewReader(data[hdrSize:]), binary.LittleEndian, ret.ErrorInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ret, nil
+}
+
+// readCPERSection decodes section data into v. Sections of older revisions
+// can be shorter than the current layout and are zero-extended.
+func readCPERSection(data []byte, v interface{}) error {
+	size := binary.Size(v)
+	if len(data) < size {
+		data = append(append([]byte{}, data...), make([]byte, size-len(data))...)
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, v)
+}
+
+// decode decodes the section data of known section types
+func (s *CPERSection) decode() error {
+	var err error
+
+	switch s.Type {
+	case CPERSectionProcessorGeneric:
+		s.ProcessorGeneric = &CPERProcessorGeneric{}
+		err = readCPERSection(s.Data, s.ProcessorGeneric)
+	case CPERSectionIA32X64Processor:
+		s.IA32X64, err = parseCPERIA32X64(s.Data)
+	case CPERSectionPlatformMemory:
+		s.Memory = &CPERMemoryError{}
+		err = readCPERSection(s.Data, s.Memory)
+	case CPERSectionPCIe:
+		s.PCIe = &CPERPCIeError{}
+		err = readCPERSection(s.Data, s.PCIe)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decode CPER section %v: %v", s.Type, err)
+	}
+	return nil
+}
+
+// ParseGenericErrorStatus decodes a generic error status block and its CPER
+// sections. Returns the number of bytes used by the block.
+func ParseGenericErrorStatus(buf []byte) (*GenericErrorStatus, int, error) {
+	var raw genericErrorStatusRaw
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read generic error status block: %v", err)
+	}
+	size := uint64(binary.Size(raw)) + uint64(raw.DataLength)
+	if size > uint64(len(buf)) {
+		return nil, 0, fmt.Errorf("generic error status block data length %d exceeds buffer", raw.DataLength)
+	}
+	if raw.RawDataLength > 0 {
+		if uint64(raw.RawDataOffset)+uint64(raw.RawDataLength) > uint64(len(buf)) {
+			return nil, 0, fmt.Errorf("generic error status block raw data exceeds buffer")
+		}
+		if end := uint64(raw.RawDataOffset) + uint64(raw.RawDataLength); end > size {
+			size = end
+		}
+	}
+	ret := GenericErrorStatus{
+		BlockStatus: raw.BlockStatus,
+		Severity:    raw.ErrorSeverity,
+	}
+	if raw.RawDataLength > 0 {
+		ret.RawData = buf[raw.RawDataOffset : raw.RawDataOffset+raw.RawDataLength]
+	}
+
+	data := buf[binary.Size(raw) : binary.Size(raw)+int(raw.DataLength)]
+	for len(data) > 0 {
+		var entry genericErrorDataEntryRaw
+
+		err = binary.Read(bytes.NewReader(data), binary.LittleEndian, &entry)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot read generic error data entry: %v", err)
+		}
+		hdrSize := binary.Size(entry)
+		s := CPERSection{
+			Type:     entry.SectionType,
+			Severity: entry.ErrorSeverity,
+			Revision: entry.Revision,
+			Flags:    entry.Flags,
+		}
+		if entry.Revision >= cperSectionTimestampRevision {
+			if len(data) < hdrSize+8 {
+				return nil, 0, fmt.Errorf("generic error data entry is truncated")
+			}
+			s.Timestamp = binary.LittleEndian.Uint64(data[hdrSize:])
+			hdrSize += 8
+		}
+		if uint64(hdrSize)+uint64(entry.ErrorDataLength) > uint64(len(data)) {
+			return nil, 0, fmt.Errorf("generic error data entry length %d exceeds block", entry.ErrorDataLength)
+		}
+		if entry.ValidationBits&cperValidFRUID != 0 {
+			id := entry.FRUID
+			s.FRUID = &id
+		}
+		if entry.ValidationBits&cperValidFRUText != 0 {
+			text := entry.FRUText[:]
+			if i := bytes.IndexByte(text, 0); i >= 0 {
+				text = text[:i]
+			}
+			s.FRUText = string(text)
+		}
+		s.Data = data[hdrSize : hdrSize+int(entry.ErrorDataLength)]
+		err = s.decode()
+		if err != nil {
+			return nil, 0, err
+		}
+		ret.Sections = append(ret.Sections, s)
+		data = data[hdrSize+int(entry.ErrorDataLength):]
+	}
+
+	return &ret, int(size), nil
+}
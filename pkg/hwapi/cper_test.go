@@ -0,0 +1,131 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// cperTestSection builds a generic error data entry of the given revision
+func cperTestSection(t *testing.T, typ GUID, revision uint16, data interface{}) []byte {
+	var body bytes.Buffer
+	if err := binary.Write(&body, binary.LittleEndian, data); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := genericErrorDataEntryRaw{
+		SectionType:     typ,
+		ErrorSeverity:   CPERSeverityFatal,
+		Revision:        revision,
+		ValidationBits:  cperValidFRUText,
+		ErrorDataLength: uint32(body.Len()),
+	}
+	copy(entry.FRUText[:], "DIMM_A1")
+
+	var ret bytes.Buffer
+	_ = binary.Write(&ret, binary.LittleEndian, entry)
+	if revision >= cperSectionTimestampRevision {
+		_ = binary.Write(&ret, binary.LittleEndian, uint64(0x20261019))
+	}
+	ret.Write(body.Bytes())
+	return ret.Bytes()
+}
+
+// cperTestBlock builds a generic error status block holding the given sections
+func cperTestBlock(status uint32, sections ...[]byte) []byte {
+	data := bytes.Join(sections, nil)
+	raw := genericErrorStatusRaw{
+		BlockStatus:   status,
+		DataLength:    uint32(len(data)),
+		ErrorSeverity: CPERSeverityFatal,
+	}
+
+	var ret bytes.Buffer
+	_ = binary.Write(&ret, binary.LittleEndian, raw)
+	ret.Write(data)
+	return ret.Bytes()
+}
+
+func TestGUIDString(t *testing.T) {
+	if s := CPERSectionPlatformMemory.String(); s != "a5bc1114-6f64-4ede-b863-3e83ed7c83b1" {
+		t.Errorf("Unexpected GUID string %s", s)
+	}
+}
+
+func TestParseGenericErrorStatus(t *testing.T) {
+	mem := CPERMemoryError{PhysicalAddress: 0x12345000, Node: 1, ErrorType: 3}
+	pcie := CPERPCIeError{VendorID: 0x8086, Bus: 2, Device: 3}
+	binary.LittleEndian.PutUint32(pcie.AERInfo[4:], 1<<18)
+	proc := struct {
+		ValidationBits uint64
+		LocalAPICID    uint64
+		CPUID          [48]byte
+		ErrorInfo      CPERIA32X64ErrorInfo
+	}{
+		ValidationBits: 1 << 2,
+		LocalAPICID:    4,
+		ErrorInfo:      CPERIA32X64ErrorInfo{CheckInfo: 0xdead},
+	}
+
+	buf := cperTestBlock(GenericErrorUncorrectable,
+		cperTestSection(t, CPERSectionPlatformMemory, 0x300, mem),
+		cperTestSection(t, CPERSectionPCIe, 0x201, pcie),
+		cperTestSection(t, CPERSectionIA32X64Processor, 0x201, proc),
+	)
+	buf = append(buf, 0xff, 0xff)
+
+	status, size, err := ParseGenericErrorStatus(buf)
+	if err != nil {
+		t.Fatalf("ParseGenericErrorStatus failed: %v", err)
+	}
+	if size != len(buf)-2 || status.BlockStatus != GenericErrorUncorrectable || len(status.Sections) != 3 {
+		t.Fatalf("Unexpected status block of size %d: %+v", size, status)
+	}
+	if CPERSeverityString(status.Severity) != "fatal" {
+		t.Errorf("Unexpected severity %d", status.Severity)
+	}
+
+	s := status.Sections[0]
+	if s.Memory == nil || s.Memory.PhysicalAddress != 0x12345000 || s.Memory.Node != 1 ||
+		s.Memory.ErrorTypeString() != "multi-bit ECC" {
+		t.Errorf("Unexpected memory section %+v", s.Memory)
+	}
+	if s.Timestamp != 0x20261019 || s.FRUText != "DIMM_A1" || s.FRUID != nil {
+		t.Errorf("Unexpected section header %+v", s)
+	}
+
+	s = status.Sections[1]
+	if s.PCIe == nil || s.PCIe.VendorID != 0x8086 || s.PCIe.Bus != 2 || s.PCIe.UncorrectableErrorStatus() != 1<<18 {
+		t.Errorf("Unexpected PCIe section %+v", s.PCIe)
+	}
+	if s.Timestamp != 0 {
+		t.Errorf("Timestamp decoded for revision %#x", s.Revision)
+	}
+
+	s = status.Sections[2]
+	if s.IA32X64 == nil || s.IA32X64.LocalAPICID != 4 || len(s.IA32X64.ErrorInfo) != 1 ||
+		s.IA32X64.ErrorInfo[0].CheckInfo != 0xdead {
+		t.Errorf("Unexpected processor section %+v", s.IA32X64)
+	}
+
+	if _, _, err := ParseGenericErrorStatus(buf[:len(buf)-20]); err == nil {
+		t.Errorf("Truncated status block wasn't detected")
+	}
+}
+
+func TestParseGenericErrorStatusShortSection(t *testing.T) {
+	// UEFI 2.1 memory error sections end after the error type at 73 bytes
+	var mem bytes.Buffer
+	_ = binary.Write(&mem, binary.LittleEndian, CPERMemoryError{PhysicalAddress: 0x12345000, ErrorType: 3, RankNumber: 7})
+
+	buf := cperTestBlock(GenericErrorUncorrectable,
+		cperTestSection(t, CPERSectionPlatformMemory, 0x201, mem.Bytes()[:73]))
+	status, _, err := ParseGenericErrorStatus(buf)
+	if err != nil {
+		t.Fatalf("ParseGenericErrorStatus failed: %v", err)
+	}
+	m := status.Sections[0].Memory
+	if m == nil || m.PhysicalAddress != 0x12345000 || m.ErrorTypeString() != "multi-bit ECC" || m.RankNumber != 0 {
+		t.Errorf("Unexpected memory section %+v", m)
+	}
+}
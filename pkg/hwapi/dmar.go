@@ -0,0 +1,398 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DMAR remapping structure types
+const (
+	DMARTypeDRHD = 0
+	DMARTypeRMRR = 1
+	DMARTypeATSR = 2
+	DMARTypeRHSA = 3
+	DMARTypeANDD = 4
+	DMARTypeSATC = 5
+)
+
+// DMAR flags
+const (
+	DMARFlagIntrRemap            = 1 << 0
+	DMARFlagX2APICOptOut         = 1 << 1
+	DMARFlagDMACtrlPlatformOptIn = 1 << 2
+)
+
+// DMAR device scope types
+const (
+	DMARScopePCIEndpoint      = 1
+	DMARScopePCISubHierarchy  = 2
+	DMARScopeIOAPIC           = 3
+	DMARScopeHPET             = 4
+	DMARScopeACPINamespaceDev = 5
+)
+
+const (
+	dmarDRHDFlagIncludePCIAll  = 1
+	dmarATSRFlagAllPorts       = 1
+	dmarSATCFlagATCRequired    = 1
+	dmarRemappingStructHdrSize = 4
+)
+
+type dmarHeader struct {
+	ACPIHeader
+	HostAddressWidth uint8
+	Flags            uint8
+	Reserved         [10]uint8
+}
+
+type dmarDeviceScopeHeader struct {
+	Type          uint8
+	Length        uint8
+	Reserved      uint16
+	EnumerationID uint8
+	StartBus      uint8
+}
+
+// DMARDeviceScopePath is a hop of a device scope path
+type DMARDeviceScopePath struct {
+	Device   uint8
+	Function uint8
+}
+
+// DMARDeviceScope is a device scope entry of a DMAR remapping structure
+type DMARDeviceScope struct {
+	Type          uint8
+	EnumerationID uint8
+	StartBus      uint8
+	Path          []DMARDeviceScopePath
+}
+
+// IsPCI returns true if the scope refers to a PCI endpoint or sub-hierarchy
+func (s DMARDeviceScope) IsPCI() bool {
+	return s.Type == DMARScopePCIEndpoint || s.Type == DMARScopePCISubHierarchy
+}
+
+// Resolve walks the scope path through the PCI bridges and returns the device at its end
+func (s DMARDeviceScope) Resolve(h LowLevelHardwareInterfaces) (PCIDevice, error) {
+	if len(s.Path) == 0 {
+		return PCIDevice{}, fmt.Errorf("device scope has an empty path")
+	}
+	bus := int(s.StartBus)
+	for i, hop := range s.Path {
+		d := PCIDevice{Bus: bus, Device: int(hop.Device), Function: int(hop.Function)}
+		if i == len(s.Path)-1 {
+			return d, nil
+		}
+		// secondary bus number of the bridge
+		secondary, err := h.PCIReadConfigSpace(d, 0x19, 1)
+		if err != nil {
+			return PCIDevice{}, err
+		}
+		bus = int(secondary[0])
+	}
+	return PCIDevice{}, fmt.Errorf("unreachable")
+}
+
+// DMARDRHD is a DMA remapping hardware unit definition
+type DMARDRHD struct {
+	Flags        uint8
+	Size         uint8
+	Segment      uint16
+	RegisterBase uint64
+	Scopes       []DMARDeviceScope
+}
+
+// IncludePCIAll returns true if the unit covers all PCI devices of its segment not covered by other units
+func (d DMARDRHD) IncludePCIAll() bool {
+	return d.Flags&dmarDRHDFlagIncludePCIAll != 0
+}
+
+// DMARRMRR is a reserved memory region reporting structure
+type DMARRMRR struct {
+	Segment uint16
+	Base    uint64
+	// Limit is the last address of the region
+	Limit  uint64
+	Scopes []DMARDeviceScope
+}
+
+// DMARATSR is a root port ATS capability reporting structure
+type DMARATSR struct {
+	Flags   uint8
+	Segment uint16
+	Scopes  []DMARDeviceScope
+}
+
+// AllPorts returns true if all root ports of the segment support ATS
+func (a DMARATSR) AllPorts() bool {
+	return a.Flags&dmarATSRFlagAllPorts != 0
+}
+
+// DMARRHSA is a remapping hardware static affinity structure
+type DMARRHSA struct {
+	Reserved        uint32
+	RegisterBase    uint64
+	ProximityDomain uint32
+}
+
+// DMARANDD is an ACPI namespace device declaration structure
+type DMARANDD struct {
+	DeviceNumber uint8
+	ObjectName   string
+}
+
+// DMARSATC is a SoC integrated address translation cache reporting structure
+type DMARSATC struct {
+	Flags   uint8
+	Segment uint16
+	Scopes  []DMARDeviceScope
+}
+
+// ATCRequired returns true if the devices must have their ATC enabled
+func (s DMARSATC) ATCRequired() bool {
+	return s.Flags&dmarSATCFlagATCRequired != 0
+}
+
+// DMAR is the decoded ACPI DMA remapping table
+type DMAR struct {
+	HostAddressWidth uint8
+	Flags            uint8
+	DRHDs            []DMARDRHD
+	RMRRs            []DMARRMRR
+	ATSRs            []DMARATSR
+	RHSAs            []DMARRHSA
+	ANDDs            []DMARANDD
+	SATCs            []DMARSATC
+}
+
+// InterruptRemapping returns true if the platform supports interrupt remapping
+func (d *DMAR) InterruptRemapping() bool {
+	return d.Flags&DMARFlagIntrRemap != 0
+}
+
+// X2APICOptOut returns true if the firmware requests the OS to not enable x2APIC mode
+func (d *DMAR) X2APICOptOut() bool {
+	return d.Flags&DMARFlagX2APICOptOut != 0
+}
+
+// DMACtrlPlatformOptIn returns true if the firmware requests DMA protection before the OS takes over
+func (d *DMAR) DMACtrlPlatformOptIn() bool {
+	return d.Flags&DMARFlagDMACtrlPlatformOptIn != 0
+}
+
+func parseDMARDeviceScopes(buf []byte) ([]DMARDeviceScope, error) {
+	var ret []DMARDeviceScope
+
+	for len(buf) > 0 {
+		var hdr dmarDeviceScopeHeader
+
+		err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read device scope: %v", err)
+		}
+		if int(hdr.Length) < binary.Size(hdr) || int(hdr.Length) > len(buf) ||
+			(int(hdr.Length)-binary.Size(hdr))%2 != 0 {
+			return nil, fmt.Errorf("device scope has invalid length %d", hdr.Length)
+		}
+		scope := DMARDeviceScope{
+			Type:          hdr.Type,
+			EnumerationID: hdr.EnumerationID,
+			StartBus:      hdr.StartBus,
+		}
+		for off := binary.Size(hdr); off < int(hdr.Length); off += 2 {
+			scope.Path = append(scope.Path, DMARDeviceScopePath{Device: buf[off], Function: buf[off+1]})
+		}
+		ret = append(ret, scope)
+		buf = buf[hdr.Length:]
+	}
+
+	return ret, nil
+}
+
+func parseDMARDRHD(buf []byte) (DMARDRHD, error) {
+	var ret DMARDRHD
+	var raw struct {
+		Flags        uint8
+		Size         uint8
+		Segment      uint16
+		RegisterBase uint64
+	}
+
+	reader := bytes.NewReader(buf)
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read DRHD: %v", err)
+	}
+	ret.Flags = raw.Flags
+	ret.Size = raw.Size
+	ret.Segment = raw.Segment
+	ret.RegisterBase = raw.RegisterBase
+	ret.Scopes, err = parseDMARDeviceScopes(buf[binary.Size(raw):])
+	if err != nil {
+		return ret, fmt.Errorf("cannot read DRHD at %x: %v", ret.RegisterBase, err)
+	}
+
+	return ret, nil
+}
+
+func parseDMARRMRR(buf []byte) (DMARRMRR, error) {
+	var ret DMARRMRR
+	var raw struct {
+		Reserved uint16
+		Segment  uint16
+		Base     uint64
+		Limit    uint64
+	}
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read RMRR: %v", err)
+	}
+	ret.Segment = raw.Segment
+	ret.Base = raw.Base
+	ret.Limit = raw.Limit
+	if ret.Limit < ret.Base {
+		return ret, fmt.Errorf("RMRR limit %x is below base %x", ret.Limit, ret.Base)
+	}
+	ret.Scopes, err = parseDMARDeviceScopes(buf[binary.Size(raw):])
+	if err != nil {
+		return ret, fmt.Errorf("cannot read RMRR at %x: %v", ret.Base, err)
+	}
+
+	return ret, nil
+}
+
+// parseDMARScopedStruct decodes the common layout of ATSR and SATC
+func parseDMARScopedStruct(buf []byte, name string) (uint8, uint16, []DMARDeviceScope, error) {
+	var raw struct {
+		Flags    uint8
+		Reserved uint8
+		Segment  uint16
+	}
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("cannot read %s: %v", name, err)
+	}
+	scopes, err := parseDMARDeviceScopes(buf[binary.Size(raw):])
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("cannot read %s: %v", name, err)
+	}
+
+	return raw.Flags, raw.Segment, scopes, nil
+}
+
+func parseDMARANDD(buf []byte) (DMARANDD, error) {
+	if len(buf) < 4 {
+		return DMARANDD{}, fmt.Errorf("ANDD is too short")
+	}
+	name := buf[4:]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	return DMARANDD{DeviceNumber: buf[3], ObjectName: string(name)}, nil
+}
+
+// ParseDMAR decodes the ACPI DMAR table
+func ParseDMAR(buf []byte) (*DMAR, error) {
+	var ret DMAR
+	var hdr dmarHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read DMAR header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "DMAR" {
+		return nil, fmt.Errorf("DMAR has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("DMAR has invalid length %d", hdr.Length)
+	}
+	ret.HostAddressWidth = hdr.HostAddressWidth
+	ret.Flags = hdr.Flags
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= dmarRemappingStructHdrSize {
+		typ := binary.LittleEndian.Uint16(buf)
+		length := binary.LittleEndian.Uint16(buf[2:])
+		if int(length) < dmarRemappingStructHdrSize || int(length) > len(buf) {
+			return nil, fmt.Errorf("DMAR remapping structure %d has invalid length %d", typ, length)
+		}
+		data := buf[dmarRemappingStructHdrSize:length]
+
+		switch typ {
+		case DMARTypeDRHD:
+			drhd, err := parseDMARDRHD(data)
+			if err != nil {
+				return nil, err
+			}
+			ret.DRHDs = append(ret.DRHDs, drhd)
+		case DMARTypeRMRR:
+			rmrr, err := parseDMARRMRR(data)
+			if err != nil {
+				return nil, err
+			}
+			ret.RMRRs = append(ret.RMRRs, rmrr)
+		case DMARTypeATSR:
+			flags, segment, scopes, err := parseDMARScopedStruct(data, "ATSR")
+			if err != nil {
+				return nil, err
+			}
+			ret.ATSRs = append(ret.ATSRs, DMARATSR{Flags: flags, Segment: segment, Scopes: scopes})
+		case DMARTypeRHSA:
+			var rhsa DMARRHSA
+			err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &rhsa)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read RHSA: %v", err)
+			}
+			ret.RHSAs = append(ret.RHSAs, rhsa)
+		case DMARTypeANDD:
+			andd, err := parseDMARANDD(data)
+			if err != nil {
+				return nil, err
+			}
+			ret.ANDDs = append(ret.ANDDs, andd)
+		case DMARTypeSATC:
+			flags, segment, scopes, err := parseDMARScopedStruct(data, "SATC")
+			if err != nil {
+				return nil, err
+			}
+			ret.SATCs = append(ret.SATCs, DMARSATC{Flags: flags, Segment: segment, Scopes: scopes})
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadDMAR reads the ACPI DMAR table and decodes it
+func ReadDMAR(h LowLevelHardwareInterfaces) (*DMAR, error) {
+	buf, err := h.GetACPITable("DMAR")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDMAR(buf)
+}
+
+// FindRMRRsInUsableRAM returns all RMRRs that overlap usable RAM in the e820
+// table. Devices in the scope of such a RMRR can access memory owned by the OS.
+func FindRMRRsInUsableRAM(h LowLevelHardwareInterfaces, dmar *DMAR) ([]DMARRMRR, error) {
+	var ret []DMARRMRR
+
+	for _, rmrr := range dmar.RMRRs {
+		overlaps, err := h.IterateOverE820Ranges("system ram", func(start uint64, end uint64) bool {
+			return rmrr.Base <= end && rmrr.Limit >= start
+		})
+		if err != nil {
+			return nil, err
+		}
+		if overlaps {
+			ret = append(ret, rmrr)
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,120 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// acpiTestTable builds an ACPI table with a valid header from the given fields
+func acpiTestTable(t *testing.T, sig string, fields ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, f := range fields {
+		if err := binary.Write(&body, binary.LittleEndian, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var hdr ACPIHeader
+	copy(hdr.Signature[:], sig)
+	hdr.Length = uint32(binary.Size(hdr) + body.Len())
+	hdr.Revision = 1
+	copy(hdr.OEMID[:], "9ELEMS")
+
+	var ret bytes.Buffer
+	_ = binary.Write(&ret, binary.LittleEndian, hdr)
+	ret.Write(body.Bytes())
+	buf := ret.Bytes()
+
+	var sum byte
+	for _, b := range buf {
+		sum += b
+	}
+	buf[9] = -sum
+
+	return buf
+}
+
+// dmarTestDRHD builds a DRHD remapping structure with a single PCI endpoint scope
+func dmarTestDRHD(flags uint8, base uint64, bus, dev, fn uint8) []byte {
+	var buf bytes.Buffer
+	for _, f := range []interface{}{
+		uint16(DMARTypeDRHD), uint16(16 + 8), flags, uint8(0), uint16(0), base,
+		uint8(DMARScopePCIEndpoint), uint8(8), uint16(0), uint8(0), bus, dev, fn,
+	} {
+		_ = binary.Write(&buf, binary.LittleEndian, f)
+	}
+	return buf.Bytes()
+}
+
+func TestParseDMARDRHD(t *testing.T) {
+	tbl := acpiTestTable(t, "DMAR",
+		uint8(38), uint8(1), [10]uint8{},
+		dmarTestDRHD(0, 0xfed90000, 0, 2, 0),
+		dmarTestDRHD(dmarDRHDFlagIncludePCIAll, 0xfed91000, 0xf0, 0x1f, 0),
+	)
+
+	dmar, err := ParseDMAR(tbl)
+	if err != nil {
+		t.Fatalf("ParseDMAR failed: %v", err)
+	}
+	if dmar.HostAddressWidth != 38 || len(dmar.DRHDs) != 2 {
+		t.Fatalf("Unexpected DMAR: %+v", dmar)
+	}
+	if dmar.DRHDs[0].RegisterBase != 0xfed90000 || dmar.DRHDs[0].IncludePCIAll() {
+		t.Errorf("Unexpected DRHD: %+v", dmar.DRHDs[0])
+	}
+	scopes := dmar.DRHDs[0].Scopes
+	if len(scopes) != 1 || !scopes[0].IsPCI() || scopes[0].Path[0].Device != 2 {
+		t.Errorf("Unexpected device scope: %+v", scopes)
+	}
+	if !dmar.DRHDs[1].IncludePCIAll() || dmar.DRHDs[1].Scopes[0].StartBus != 0xf0 {
+		t.Errorf("Unexpected DRHD: %+v", dmar.DRHDs[1])
+	}
+
+	if _, err := ParseDMAR(tbl[:len(tbl)-1]); err == nil {
+		t.Errorf("Truncated DMAR wasn't detected")
+	}
+}
+
+func TestParseDMAR(t *testing.T) {
+	tbl := acpiTestTable(t, "DMAR",
+		uint8(46), uint8(DMARFlagIntrRemap|DMARFlagDMACtrlPlatformOptIn), [10]uint8{},
+		dmarTestDRHD(dmarDRHDFlagIncludePCIAll, 0xfed91000, 0, 0x1f, 0),
+		// RMRR for the USB controller
+		uint16(DMARTypeRMRR), uint16(24+8), uint16(0), uint16(0), uint64(0x7b800000), uint64(0x7bffffff),
+		uint8(DMARScopePCIEndpoint), uint8(8), uint16(0), uint8(0), uint8(0), uint8(0x14), uint8(0),
+		// ATSR
+		uint16(DMARTypeATSR), uint16(8), uint8(dmarATSRFlagAllPorts), uint8(0), uint16(0),
+		// RHSA
+		uint16(DMARTypeRHSA), uint16(20), uint32(0), uint64(0xfed91000), uint32(1),
+		// ANDD
+		uint16(DMARTypeANDD), uint16(8+12), [3]uint8{}, uint8(1), []byte("\\_SB.PCI0\x00\x00\x00"),
+		// SATC
+		uint16(DMARTypeSATC), uint16(8), uint8(dmarSATCFlagATCRequired), uint8(0), uint16(0),
+	)
+
+	dmar, err := ParseDMAR(tbl)
+	if err != nil {
+		t.Fatalf("ParseDMAR failed: %v", err)
+	}
+	if !dmar.InterruptRemapping() || dmar.X2APICOptOut() || !dmar.DMACtrlPlatformOptIn() {
+		t.Errorf("Unexpected DMAR flags: %x", dmar.Flags)
+	}
+	if len(dmar.DRHDs) != 1 || len(dmar.RMRRs) != 1 || len(dmar.ATSRs) != 1 ||
+		len(dmar.RHSAs) != 1 || len(dmar.ANDDs) != 1 || len(dmar.SATCs) != 1 {
+		t.Fatalf("Unexpected DMAR: %+v", dmar)
+	}
+	if dmar.RMRRs[0].Base != 0x7b800000 || dmar.RMRRs[0].Limit != 0x7bffffff || dmar.RMRRs[0].Scopes[0].Path[0].Device != 0x14 {
+		t.Errorf("Unexpected RMRR: %+v", dmar.RMRRs[0])
+	}
+	if !dmar.ATSRs[0].AllPorts() || !dmar.SATCs[0].ATCRequired() {
+		t.Errorf("Unexpected ATSR or SATC: %+v %+v", dmar.ATSRs[0], dmar.SATCs[0])
+	}
+	if dmar.RHSAs[0].RegisterBase != 0xfed91000 || dmar.RHSAs[0].ProximityDomain != 1 {
+		t.Errorf("Unexpected RHSA: %+v", dmar.RHSAs[0])
+	}
+	if dmar.ANDDs[0].DeviceNumber != 1 || dmar.ANDDs[0].ObjectName != "\\_SB.PCI0" {
+		t.Errorf("Unexpected ANDD: %+v", dmar.ANDDs[0])
+	}
+}
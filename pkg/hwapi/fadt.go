@@ -0,0 +1,349 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// FADT feature flags
+const (
+	FADTFlagWBINVD                    = 1 << 0
+	FADTFlagWBINVDFlush               = 1 << 1
+	FADTFlagProcC1                    = 1 << 2
+	FADTFlagPLvl2UP                   = 1 << 3
+	FADTFlagPowerButton               = 1 << 4
+	FADTFlagSleepButton               = 1 << 5
+	FADTFlagFixRTC                    = 1 << 6
+	FADTFlagRTCS4                     = 1 << 7
+	FADTFlagTimerValExt               = 1 << 8
+	FADTFlagDockCap                   = 1 << 9
+	FADTFlagResetRegSup               = 1 << 10
+	FADTFlagSealedCase                = 1 << 11
+	FADTFlagHeadless                  = 1 << 12
+	FADTFlagCPUSWSleep                = 1 << 13
+	FADTFlagPCIExpWake                = 1 << 14
+	FADTFlagUsePlatformClock          = 1 << 15
+	FADTFlagS4RTCStatusValid          = 1 << 16
+	FADTFlagRemotePowerOnCapable      = 1 << 17
+	FADTFlagForceAPICClusterModel     = 1 << 18
+	FADTFlagForceAPICPhysicalDestMode = 1 << 19
+	FADTFlagHWReducedACPI             = 1 << 20
+	FADTFlagLowPowerS0IdleCapable     = 1 << 21
+	// FADTFlagPersistentCPUCaches is a two bit field
+	FADTFlagPersistentCPUCaches = 3 << 22
+)
+
+// FADT IA-PC boot architecture flags
+const (
+	FADTBootArchLegacyDevices     = 1 << 0
+	FADTBootArch8042              = 1 << 1
+	FADTBootArchVGANotPresent     = 1 << 2
+	FADTBootArchMSINotSupported   = 1 << 3
+	FADTBootArchPCIeASPMControls  = 1 << 4
+	FADTBootArchCMOSRTCNotPresent = 1 << 5
+)
+
+// FADT ARM boot architecture flags
+const (
+	FADTARMBootArchPSCICompliant = 1 << 0
+	FADTARMBootArchPSCIUseHVC    = 1 << 1
+)
+
+var fadtFlagNames = []string{
+	"WBINVD", "WBINVD_FLUSH", "PROC_C1", "P_LVL2_UP", "PWR_BUTTON", "SLP_BUTTON",
+	"FIX_RTC", "RTC_S4", "TMR_VAL_EXT", "DCK_CAP", "RESET_REG_SUP", "SEALED_CASE",
+	"HEADLESS", "CPU_SW_SLP", "PCI_EXP_WAK", "USE_PLATFORM_CLOCK", "S4_RTC_STS_VALID",
+	"REMOTE_POWER_ON_CAPABLE", "FORCE_APIC_CLUSTER_MODEL",
+	"FORCE_APIC_PHYSICAL_DESTINATION_MODE", "HW_REDUCED_ACPI",
+	"LOW_POWER_S0_IDLE_CAPABLE", "PERSISTENT_CPU_CACHES", "PERSISTENT_CPU_CACHES",
+}
+
+var fadtBootArchNames = []string{
+	"LEGACY_DEVICES", "8042", "VGA_NOT_PRESENT", "MSI_NOT_SUPPORTED",
+	"PCIE_ASPM_CONTROLS", "CMOS_RTC_NOT_PRESENT",
+}
+
+// fadtRaw as defined in ACPI Spec 6.5 "5.2.9 Fixed ACPI Description Table (FADT)".
+// Older revisions are shorter, missing fields read as zero.
+type fadtRaw struct {
+	ACPIHeader
+	FirmwareCtrl       uint32
+	DSDT               uint32
+	Reserved0          uint8
+	PreferredPMProfile uint8
+	SCIInt             uint16
+	SMICmd             uint32
+	ACPIEnable         uint8
+	ACPIDisable        uint8
+	S4BIOSReq          uint8
+	PStateCnt          uint8
+	PM1aEvtBlk         uint32
+	PM1bEvtBlk         uint32
+	PM1aCntBlk         uint32
+	PM1bCntBlk         uint32
+	PM2CntBlk          uint32
+	PMTmrBlk           uint32
+	GPE0Blk            uint32
+	GPE1Blk            uint32
+	PM1EvtLen          uint8
+	PM1CntLen          uint8
+	PM2CntLen          uint8
+	PMTmrLen           uint8
+	GPE0BlkLen         uint8
+	GPE1BlkLen         uint8
+	GPE1Base           uint8
+	CSTCnt             uint8
+	PLvl2Lat           uint16
+	PLvl3Lat           uint16
+	FlushSize          uint16
+	FlushStride        uint16
+	DutyOffset         uint8
+	DutyWidth          uint8
+	DayAlrm            uint8
+	MonAlrm            uint8
+	Century            uint8
+	IAPCBootArch       uint16
+	Reserved1          uint8
+	Flags              uint32
+	ResetReg           ACPIGenericAddress
+	ResetValue         uint8
+	ARMBootArch        uint16
+	MinorVersion       uint8
+	XFirmwareCtrl      uint64
+	XDSDT              uint64
+	XPM1aEvtBlk        ACPIGenericAddress
+	XPM1bEvtBlk        ACPIGenericAddress
+	XPM1aCntBlk        ACPIGenericAddress
+	XPM1bCntBlk        ACPIGenericAddress
+	XPM2CntBlk         ACPIGenericAddress
+	XPMTmrBlk          ACPIGenericAddress
+	XGPE0Blk           ACPIGenericAddress
+	XGPE1Blk           ACPIGenericAddress
+	SleepControlReg    ACPIGenericAddress
+	SleepStatusReg     ACPIGenericAddress
+	HypervisorVendorID uint64
+}
+
+// FADT is the decoded Fixed ACPI Description Table. Pointers and register
+// blocks hold the 64 bit X_ variant if set, the 32 bit variant otherwise.
+type FADT struct {
+	Header       ACPIHeader
+	MinorVersion uint8
+
+	FirmwareCtrl uint64
+	DSDT         uint64
+
+	PreferredPMProfile uint8
+	SCIInterrupt       uint16
+	SMICommand         uint32
+	ACPIEnable         uint8
+	ACPIDisable        uint8
+	S4BIOSRequest      uint8
+	PStateControl      uint8
+	CStateControl      uint8
+
+	PM1aEvent   ACPIGenericAddress
+	PM1bEvent   ACPIGenericAddress
+	PM1aControl ACPIGenericAddress
+	PM1bControl ACPIGenericAddress
+	PM2Control  ACPIGenericAddress
+	PMTimer     ACPIGenericAddress
+	GPE0        ACPIGenericAddress
+	GPE1        ACPIGenericAddress
+	GPE1Base    uint8
+
+	C2Latency   uint16
+	C3Latency   uint16
+	FlushSize   uint16
+	FlushStride uint16
+	DutyOffset  uint8
+	DutyWidth   uint8
+	DayAlarm    uint8
+	MonthAlarm  uint8
+	Century     uint8
+
+	IAPCBootArch uint16
+	ARMBootArch  uint16
+	Flags        uint32
+
+	ResetRegister ACPIGenericAddress
+	ResetValue    uint8
+
+	SleepControl ACPIGenericAddress
+	SleepStatus  ACPIGenericAddress
+
+	HypervisorVendorID uint64
+}
+
+// Version returns the FADT major and minor revision, e.g. 6.5 for ACPI 6.5.
+// The minor revision exists since ACPI 5.1.
+func (f *FADT) Version() string {
+	return fmt.Sprintf("%d.%d", f.Header.Revision, f.MinorVersion&0xf)
+}
+
+// HardwareReduced returns true if the platform implements the hardware reduced ACPI model
+func (f *FADT) HardwareReduced() bool {
+	return f.Flags&FADTFlagHWReducedACPI != 0
+}
+
+// ResetSupported returns true if the reset register may be used to reset the system
+func (f *FADT) ResetSupported() bool {
+	return f.Flags&FADTFlagResetRegSup != 0 && f.ResetRegister.IsSet()
+}
+
+// HypervisorVendor returns the hypervisor vendor identity as string, empty if not set
+func (f *FADT) HypervisorVendor() string {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], f.HypervisorVendorID)
+	return strings.TrimRight(string(buf[:]), "\x00")
+}
+
+func fadtBitNames(v uint32, names []string) []string {
+	var ret []string
+	for i, n := range names {
+		if v&(1<<uint(i)) != 0 && (len(ret) == 0 || ret[len(ret)-1] != n) {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// FlagNames returns the names of the feature flags set
+func (f *FADT) FlagNames() []string {
+	return fadtBitNames(f.Flags, fadtFlagNames)
+}
+
+// IAPCBootArchNames returns the names of the IA-PC boot architecture flags set
+func (f *FADT) IAPCBootArchNames() []string {
+	return fadtBitNames(uint32(f.IAPCBootArch), fadtBootArchNames)
+}
+
+// fadtRegister returns the X_ register block if set, otherwise converts the
+// legacy I/O port block of len bytes
+func fadtRegister(x ACPIGenericAddress, port uint32, len uint8) ACPIGenericAddress {
+	if x.IsSet() || port == 0 {
+		return x
+	}
+	return ACPIGenericAddress{
+		AddressSpaceID:   ACPIAddressSpaceSystemIO,
+		RegisterBitWidth: len * 8,
+		Address:          uint64(port),
+	}
+}
+
+// ParseFADT decodes the FADT of any revision
+func ParseFADT(buf []byte) (*FADT, error) {
+	var raw fadtRaw
+
+	hdr, err := ParseACPIHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr.Signature[:]) != "FACP" {
+		return nil, fmt.Errorf("FADT has invalid signature %q", hdr.Signature[:])
+	}
+	if int(hdr.Length) > len(buf) {
+		return nil, fmt.Errorf("FADT truncated: length %d, got %d bytes", hdr.Length, len(buf))
+	}
+
+	// shorter revisions leave the newer fields zero
+	full := make([]byte, binary.Size(raw))
+	copy(full, buf[:hdr.Length])
+	err = binary.Read(bytes.NewReader(full), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FADT{
+		Header:       raw.ACPIHeader,
+		MinorVersion: raw.MinorVersion,
+
+		FirmwareCtrl: fadtPointer(buf[:hdr.Length], acpiFADTFirmwareCtrl, acpiFADTXFirmwareCtrl),
+		DSDT:         fadtPointer(buf[:hdr.Length], acpiFADTDSDT, acpiFADTXDSDT),
+
+		PreferredPMProfile: raw.PreferredPMProfile,
+		SCIInterrupt:       raw.SCIInt,
+		SMICommand:         raw.SMICmd,
+		ACPIEnable:         raw.ACPIEnable,
+		ACPIDisable:        raw.ACPIDisable,
+		S4BIOSRequest:      raw.S4BIOSReq,
+		PStateControl:      raw.PStateCnt,
+		CStateControl:      raw.CSTCnt,
+
+		PM1aEvent:   fadtRegister(raw.XPM1aEvtBlk, raw.PM1aEvtBlk, raw.PM1EvtLen),
+		PM1bEvent:   fadtRegister(raw.XPM1bEvtBlk, raw.PM1bEvtBlk, raw.PM1EvtLen),
+		PM1aControl: fadtRegister(raw.XPM1aCntBlk, raw.PM1aCntBlk, raw.PM1CntLen),
+		PM1bControl: fadtRegister(raw.XPM1bCntBlk, raw.PM1bCntBlk, raw.PM1CntLen),
+		PM2Control:  fadtRegister(raw.XPM2CntBlk, raw.PM2CntBlk, raw.PM2CntLen),
+		PMTimer:     fadtRegister(raw.XPMTmrBlk, raw.PMTmrBlk, raw.PMTmrLen),
+		GPE0:        fadtRegister(raw.XGPE0Blk, raw.GPE0Blk, raw.GPE0BlkLen),
+		GPE1:        fadtRegister(raw.XGPE1Blk, raw.GPE1Blk, raw.GPE1BlkLen),
+		GPE1Base:    raw.GPE1Base,
+
+		C2Latency:   raw.PLvl2Lat,
+		C3Latency:   raw.PLvl3Lat,
+		FlushSize:   raw.FlushSize,
+		FlushStride: raw.FlushStride,
+		DutyOffset:  raw.DutyOffset,
+		DutyWidth:   raw.DutyWidth,
+		DayAlarm:    raw.DayAlrm,
+		MonthAlarm:  raw.MonAlrm,
+		Century:     raw.Century,
+
+		IAPCBootArch: raw.IAPCBootArch,
+		ARMBootArch:  raw.ARMBootArch,
+		Flags:        raw.Flags,
+
+		ResetRegister: raw.ResetReg,
+		ResetValue:    raw.ResetValue,
+
+		SleepControl: raw.SleepControlReg,
+		SleepStatus:  raw.SleepStatusReg,
+
+		HypervisorVendorID: raw.HypervisorVendorID,
+	}, nil
+}
+
+// ReadFADT reads the ACPI FADT and decodes it
+func ReadFADT(h LowLevelHardwareInterfaces) (*FADT, error) {
+	buf, err := h.GetACPITable("FACP")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFADT(buf)
+}
+
+// ResolveFADTTables returns the physical addresses of the DSDT and FACS
+// referenced by the FADT. The tables are located through physical memory,
+// which works if sysfs isn't available. Returns 0 for pointers not set.
+func ResolveFADTTables(h LowLevelHardwareInterfaces) (dsdt uint64, facs uint64, err error) {
+	buf, err := GetACPITableDevMem(h, "FACP")
+	if err != nil {
+		return 0, 0, err
+	}
+	fadt, err := ParseFADT(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, t := range []struct {
+		sig  string
+		addr uint64
+	}{{"DSDT", fadt.DSDT}, {"FACS", fadt.FirmwareCtrl}} {
+		if t.addr == 0 {
+			continue
+		}
+		hdr, err := readACPIHeaderAt(h, t.addr)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cannot read %s at %#x: %v", t.sig, t.addr, err)
+		}
+		if string(hdr.Signature[:]) != t.sig {
+			return 0, 0, fmt.Errorf("%s at %#x has invalid signature %q", t.sig, t.addr, hdr.Signature[:])
+		}
+	}
+
+	return fadt.DSDT, fadt.FirmwareCtrl, nil
+}
@@ -0,0 +1,84 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestParseFADT(t *testing.T) {
+	// FADT revision 6.5, 276 bytes
+	body := make([]byte, 276-36)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(body[off-36:], v) }
+	put64 := func(off int, v uint64) { binary.LittleEndian.PutUint64(body[off-36:], v) }
+	put32(acpiFADTFirmwareCtrl, 0x1000)
+	put32(acpiFADTDSDT, 0x2000)
+	put64(acpiFADTXDSDT, 0x12000)
+	put32(56, 0x1800)             // PM1a_EVT_BLK
+	put32(64, 0x1804)             // PM1a_CNT_BLK
+	put32(76, 0x1808)             // PM_TMR_BLK
+	body[88-36] = 4               // PM1_EVT_LEN
+	body[89-36] = 2               // PM1_CNT_LEN
+	body[91-36] = 4               // PM_TMR_LEN
+	body[109-36] = 0x0a           // IAPC_BOOT_ARCH: 8042, MSI not supported
+	put32(112, 1<<10|1<<20|1<<23) // flags
+	body[116-36] = ACPIAddressSpaceSystemIO
+	body[117-36] = 8
+	put64(120, 0xcf9)
+	body[128-36] = 6 // RESET_VALUE
+	body[131-36] = 5 // minor version
+	body[172-36] = ACPIAddressSpaceSystemMemory
+	body[173-36] = 16
+	put64(176, 0xfe000404)       // X_PM1a_CNT_BLK
+	put64(268, 0x4d564b4d564b4d) // "MKVMKVM"
+	buf := acpiTestTable(t, "FACP", body)
+	buf[8] = 6
+	buf[9] -= 5
+
+	fadt, err := ParseFADT(buf)
+	if err != nil {
+		t.Fatalf("ParseFADT failed: %v", err)
+	}
+	got := []string{
+		fadt.Version(),
+		fmt.Sprintf("%x %x", fadt.DSDT, fadt.FirmwareCtrl),
+		fadt.PM1aEvent.String(),
+		fadt.PM1aControl.String(),
+		fadt.PMTimer.String(),
+		fmt.Sprint(fadt.PM1bEvent.IsSet(), fadt.GPE0.IsSet()),
+		fmt.Sprint(fadt.FlagNames()),
+		fmt.Sprint(fadt.IAPCBootArchNames()),
+		fmt.Sprintf("%v %v %v", fadt.ResetSupported(), fadt.ResetRegister, fadt.ResetValue),
+		fmt.Sprintf("%v %v", fadt.HardwareReduced(), fadt.HypervisorVendor()),
+	}
+	want := []string{
+		"6.5",
+		"12000 1000",
+		"io 0x1800 (32 bits at bit 0)",
+		"mem 0xfe000404 (16 bits at bit 0)",
+		"io 0x1808 (32 bits at bit 0)",
+		"false false",
+		"[RESET_REG_SUP HW_REDUCED_ACPI PERSISTENT_CPU_CACHES]",
+		"[8042 MSI_NOT_SUPPORTED]",
+		"true io 0xcf9 (8 bits at bit 0) 6",
+		"true MKVMKVM",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Got %q, want %q", got[i], want[i])
+		}
+	}
+
+	// FADT revision 1 only has 32 bit pointers
+	fadt, err = ParseFADT(acpiTestTable(t, "FACP", body[:80]))
+	if err != nil {
+		t.Fatalf("ParseFADT failed: %v", err)
+	}
+	if fadt.DSDT != 0x2000 || fadt.PM1aControl.String() != "io 0x1804 (16 bits at bit 0)" || fadt.ResetRegister.IsSet() {
+		t.Errorf("Unexpected revision 1 FADT %+v", fadt)
+	}
+
+	if _, err := ParseFADT(acpiTestTable(t, "APIC", body)); err == nil {
+		t.Errorf("ParseFADT accepted wrong signature")
+	}
+}
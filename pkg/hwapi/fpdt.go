@@ -0,0 +1,274 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// FPDT performance record types
+const (
+	FPDTTypeFBPTPointer = 0
+	FPDTTypeS3PTPointer = 1
+)
+
+// FBPT and S3PT performance record types
+const (
+	FBPTTypeBasicBoot = 2
+	S3PTTypeResume    = 0
+	S3PTTypeSuspend   = 1
+)
+
+const (
+	fpdtRecordHdrSize = 4
+	fpdtTableHdrSize  = 8
+	// fpdtMaxTableSize limits the FBPT and S3PT read from physical memory
+	fpdtMaxTableSize = 64 << 10
+)
+
+type fpdtRecordHdr struct {
+	Type     uint16
+	Length   uint8
+	Revision uint8
+}
+
+// FPDT is the decoded ACPI Firmware Performance Data Table. Addresses are zero if not present.
+type FPDT struct {
+	FBPTAddress uint64
+	S3PTAddress uint64
+}
+
+// FBPT holds the firmware basic boot performance record. All timestamps are
+// in nanoseconds since the processor reset.
+type FBPT struct {
+	ResetEnd                uint64
+	OSLoaderLoadImageStart  uint64
+	OSLoaderStartImageStart uint64
+	ExitBootServicesEntry   uint64
+	ExitBootServicesExit    uint64
+}
+
+// S3PT holds the S3 resume and suspend performance records. Times are in nanoseconds.
+type S3PT struct {
+	// ResumeCount is the number of S3 resumes since the last full boot
+	ResumeCount   uint32
+	FullResume    uint64
+	AverageResume uint64
+	SuspendStart  uint64
+	SuspendEnd    uint64
+}
+
+// FirmwarePerformance holds the firmware performance tables referenced by the
+// FPDT. Tables not referenced are nil.
+type FirmwarePerformance struct {
+	Boot *FBPT
+	S3   *S3PT
+}
+
+// ParseFPDT decodes the ACPI FPDT table
+func ParseFPDT(buf []byte) (*FPDT, error) {
+	var ret FPDT
+	var hdr ACPIHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read FPDT header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "FPDT" {
+		return nil, fmt.Errorf("FPDT has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("FPDT has invalid length %d", hdr.Length)
+	}
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= fpdtRecordHdrSize {
+		var raw struct {
+			fpdtRecordHdr
+			Reserved uint32
+			Address  uint64
+		}
+		length := int(buf[2])
+		if length < fpdtRecordHdrSize || length > len(buf) {
+			return nil, fmt.Errorf("FPDT record has invalid length %d", length)
+		}
+
+		typ := binary.LittleEndian.Uint16(buf)
+		if typ == FPDTTypeFBPTPointer || typ == FPDTTypeS3PTPointer {
+			err = binary.Read(bytes.NewReader(buf[:length]), binary.LittleEndian, &raw)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read FPDT record %d: %v", typ, err)
+			}
+			if typ == FPDTTypeFBPTPointer {
+				ret.FBPTAddress = raw.Address
+			} else {
+				ret.S3PTAddress = raw.Address
+			}
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadFPDT reads the ACPI FPDT table and decodes it
+func ReadFPDT(h LowLevelHardwareInterfaces) (*FPDT, error) {
+	buf, err := h.GetACPITable("FPDT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFPDT(buf)
+}
+
+// fpdtRecords validates the header of a FBPT or S3PT and calls fn for each performance record
+func fpdtRecords(buf []byte, sig string, fn func(hdr fpdtRecordHdr, rec []byte) error) error {
+	if len(buf) < fpdtTableHdrSize || string(buf[:4]) != sig {
+		return fmt.Errorf("%s has invalid signature", sig)
+	}
+	length := binary.LittleEndian.Uint32(buf[4:])
+	if length < fpdtTableHdrSize || uint64(length) > uint64(len(buf)) {
+		return fmt.Errorf("%s has invalid length %d", sig, length)
+	}
+
+	buf = buf[fpdtTableHdrSize:length]
+	for len(buf) >= fpdtRecordHdrSize {
+		hdr := fpdtRecordHdr{Type: binary.LittleEndian.Uint16(buf), Length: buf[2], Revision: buf[3]}
+		if int(hdr.Length) < fpdtRecordHdrSize || int(hdr.Length) > len(buf) {
+			return fmt.Errorf("%s record %d has invalid length %d", sig, hdr.Type, hdr.Length)
+		}
+		err := fn(hdr, buf[:hdr.Length])
+		if err != nil {
+			return fmt.Errorf("cannot read %s record %d: %v", sig, hdr.Type, err)
+		}
+		buf = buf[hdr.Length:]
+	}
+	return nil
+}
+
+// ParseFBPT decodes the Firmware Basic Boot Performance Table
+func ParseFBPT(buf []byte) (*FBPT, error) {
+	var ret *FBPT
+
+	err := fpdtRecords(buf, "FBPT", func(hdr fpdtRecordHdr, rec []byte) error {
+		var raw struct {
+			fpdtRecordHdr
+			Reserved uint32
+			FBPT
+		}
+		if hdr.Type != FBPTTypeBasicBoot {
+			return nil
+		}
+		err := binary.Read(bytes.NewReader(rec), binary.LittleEndian, &raw)
+		if err != nil {
+			return err
+		}
+		ret = &raw.FBPT
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, fmt.Errorf("FBPT has no basic boot performance record")
+	}
+
+	return ret, nil
+}
+
+// ParseS3PT decodes the S3 Performance Table
+func ParseS3PT(buf []byte) (*S3PT, error) {
+	var ret S3PT
+
+	err := fpdtRecords(buf, "S3PT", func(hdr fpdtRecordHdr, rec []byte) error {
+		reader := bytes.NewReader(rec[fpdtRecordHdrSize:])
+		switch hdr.Type {
+		case S3PTTypeResume:
+			var raw struct {
+				ResumeCount   uint32
+				FullResume    uint64
+				AverageResume uint64
+			}
+			err := binary.Read(reader, binary.LittleEndian, &raw)
+			if err != nil {
+				return err
+			}
+			ret.ResumeCount = raw.ResumeCount
+			ret.FullResume = raw.FullResume
+			ret.AverageResume = raw.AverageResume
+		case S3PTTypeSuspend:
+			var raw struct {
+				SuspendStart uint64
+				SuspendEnd   uint64
+			}
+			err := binary.Read(reader, binary.LittleEndian, &raw)
+			if err != nil {
+				return err
+			}
+			ret.SuspendStart = raw.SuspendStart
+			ret.SuspendEnd = raw.SuspendEnd
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// readFPDTTable reads a FBPT or S3PT from physical memory
+func readFPDTTable(h LowLevelHardwareInterfaces, addr uint64, sig string) ([]byte, error) {
+	hdr := make([]byte, fpdtTableHdrSize)
+	err := h.ReadPhysBuf(int64(addr), hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s at %x: %v", sig, addr, err)
+	}
+	if string(hdr[:4]) != sig {
+		return nil, fmt.Errorf("%s at %x has invalid signature", sig, addr)
+	}
+	length := binary.LittleEndian.Uint32(hdr[4:])
+	if length < fpdtTableHdrSize || length > fpdtMaxTableSize {
+		return nil, fmt.Errorf("%s at %x has invalid length %d", sig, addr, length)
+	}
+
+	buf := make([]byte, length)
+	err = h.ReadPhysBuf(int64(addr), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s at %x: %v", sig, addr, err)
+	}
+	return buf, nil
+}
+
+// ReadFirmwarePerformance reads the FPDT and decodes the boot and S3
+// performance tables it points to
+func ReadFirmwarePerformance(h LowLevelHardwareInterfaces) (*FirmwarePerformance, error) {
+	var ret FirmwarePerformance
+
+	fpdt, err := ReadFPDT(h)
+	if err != nil {
+		return nil, err
+	}
+	if fpdt.FBPTAddress != 0 {
+		buf, err := readFPDTTable(h, fpdt.FBPTAddress, "FBPT")
+		if err != nil {
+			return nil, err
+		}
+		ret.Boot, err = ParseFBPT(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fpdt.S3PTAddress != 0 {
+		buf, err := readFPDTTable(h, fpdt.S3PTAddress, "S3PT")
+		if err != nil {
+			return nil, err
+		}
+		ret.S3, err = ParseS3PT(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,66 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// fpdtTestTable builds a FBPT or S3PT from the given fields
+func fpdtTestTable(sig string, fields ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, f := range fields {
+		_ = binary.Write(&body, binary.LittleEndian, f)
+	}
+
+	var ret bytes.Buffer
+	ret.WriteString(sig)
+	_ = binary.Write(&ret, binary.LittleEndian, uint32(fpdtTableHdrSize+body.Len()))
+	ret.Write(body.Bytes())
+	return ret.Bytes()
+}
+
+func TestReadFirmwarePerformance(t *testing.T) {
+	boot := FBPT{
+		ResetEnd:                1000,
+		OSLoaderLoadImageStart:  2000,
+		OSLoaderStartImageStart: 3000,
+		ExitBootServicesEntry:   4000,
+		ExitBootServicesExit:    5000,
+	}
+
+	mem := newPhysMemImage()
+	mem.acpi["FPDT"] = acpiTestTable(t, "FPDT",
+		fpdtRecordHdr{FPDTTypeFBPTPointer, 16, 1}, uint32(0), uint64(0x7d000000),
+		fpdtRecordHdr{FPDTTypeS3PTPointer, 16, 1}, uint32(0), uint64(0x7d001000),
+	)
+	mem.writeBuf(0x7d000000, fpdtTestTable("FBPT",
+		fpdtRecordHdr{0x1000, 8, 1}, uint32(0),
+		fpdtRecordHdr{FBPTTypeBasicBoot, 48, 2}, uint32(0), boot,
+	))
+	mem.writeBuf(0x7d001000, fpdtTestTable("S3PT",
+		fpdtRecordHdr{S3PTTypeResume, 24, 1}, uint32(3), uint64(150000), uint64(160000),
+		fpdtRecordHdr{S3PTTypeSuspend, 20, 1}, uint64(10), uint64(20),
+	))
+
+	perf, err := ReadFirmwarePerformance(mem)
+	if err != nil {
+		t.Fatalf("ReadFirmwarePerformance failed: %v", err)
+	}
+	if perf.Boot == nil || *perf.Boot != boot {
+		t.Errorf("Unexpected FBPT %+v", perf.Boot)
+	}
+	want := S3PT{ResumeCount: 3, FullResume: 150000, AverageResume: 160000, SuspendStart: 10, SuspendEnd: 20}
+	if perf.S3 == nil || *perf.S3 != want {
+		t.Errorf("Unexpected S3PT %+v", perf.S3)
+	}
+
+	mem.writeBuf(0x7d001000, []byte("XXXX"))
+	if _, err := ReadFirmwarePerformance(mem); err == nil {
+		t.Errorf("Invalid S3PT signature wasn't detected")
+	}
+
+	if _, err := ParseFBPT(fpdtTestTable("FBPT")); err == nil {
+		t.Errorf("FBPT without basic boot record wasn't rejected")
+	}
+}
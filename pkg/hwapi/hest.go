@@ -0,0 +1,247 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// HEST error source types
+const (
+	HESTTypeIA32MachineCheck          = 0
+	HESTTypeIA32CorrectedMachineCheck = 1
+	HESTTypeIA32NMI                   = 2
+	HESTTypePCIeRootPortAER           = 6
+	HESTTypePCIeDeviceAER             = 7
+	HESTTypePCIeBridgeAER             = 8
+	HESTTypeGHES                      = 9
+	HESTTypeGHESv2                    = 10
+	HESTTypeIA32DeferredMachineCheck  = 11
+)
+
+// HEST hardware error notification types
+const (
+	HESTNotifyPolled            = 0
+	HESTNotifyExternalInterrupt = 1
+	HESTNotifyLocalInterrupt    = 2
+	HESTNotifySCI               = 3
+	HESTNotifyNMI               = 4
+	HESTNotifyCMCI              = 5
+	HESTNotifyMCE               = 6
+	HESTNotifyGPIO              = 7
+	HESTNotifySEA               = 8
+	HESTNotifySEI               = 9
+	HESTNotifyGSIV              = 10
+	HESTNotifySoftwareDelegated = 11
+)
+
+const (
+	hestMachineCheckSize          = 40
+	hestCorrectedMachineCheckSize = 48
+	hestNMISize                   = 20
+	hestRootPortAERSize           = 48
+	hestDeviceAERSize             = 44
+	hestBridgeAERSize             = 56
+	hestGHESSize                  = 64
+	hestGHESv2Size                = 92
+	hestBankSize                  = 28
+)
+
+type hestHeader struct {
+	ACPIHeader
+	ErrorSourceCount uint32
+}
+
+// HESTNotification is a hardware error notification structure
+type HESTNotification struct {
+	Type                           uint8
+	Length                         uint8
+	ConfigurationWriteEnable       uint16
+	PollInterval                   uint32
+	Vector                         uint32
+	SwitchToPollingThresholdValue  uint32
+	SwitchToPollingThresholdWindow uint32
+	ErrorThresholdValue            uint32
+	ErrorThresholdWindow           uint32
+}
+
+// HESTMachineCheckBank is a machine check error bank structure
+type HESTMachineCheckBank struct {
+	BankNumber         uint8
+	ClearStatusOnInit  uint8
+	StatusDataFormat   uint8
+	Reserved           uint8
+	ControlRegisterMSR uint32
+	ControlInitData    uint64
+	StatusRegisterMSR  uint32
+	AddressRegisterMSR uint32
+	MiscRegisterMSR    uint32
+}
+
+// HESTErrorSource is an error source structure of the HEST. Fields not
+// present in the error source type are zero.
+type HESTErrorSource struct {
+	Type     uint16
+	SourceID uint16
+	// RelatedSourceID is only valid for GHES
+	RelatedSourceID      uint16
+	Flags                uint8
+	Enabled              bool
+	RecordsToPreallocate uint32
+	MaxSectionsPerRecord uint32
+	Notification         *HESTNotification
+	// ErrorStatusAddress points to the address of the generic error status block of a GHES
+	ErrorStatusAddress     ACPIGenericAddress
+	ErrorStatusBlockLength uint32
+	Banks                  []HESTMachineCheckBank
+	// Data holds the whole error source structure
+	Data []byte
+}
+
+// HEST is the decoded ACPI Hardware Error Source Table
+type HEST struct {
+	ErrorSources []HESTErrorSource
+}
+
+// hestErrorSourceSize returns the size of the error source structure at the start of buf
+func hestErrorSourceSize(buf []byte) (int, error) {
+	banks := func(off, size int) (int, error) {
+		if len(buf) <= off {
+			return 0, fmt.Errorf("HEST error source is truncated")
+		}
+		return size + int(buf[off])*hestBankSize, nil
+	}
+
+	switch typ := binary.LittleEndian.Uint16(buf); typ {
+	case HESTTypeIA32MachineCheck:
+		return banks(32, hestMachineCheckSize)
+	case HESTTypeIA32CorrectedMachineCheck, HESTTypeIA32DeferredMachineCheck:
+		return banks(44, hestCorrectedMachineCheckSize)
+	case HESTTypeIA32NMI:
+		return hestNMISize, nil
+	case HESTTypePCIeRootPortAER:
+		return hestRootPortAERSize, nil
+	case HESTTypePCIeDeviceAER:
+		return hestDeviceAERSize, nil
+	case HESTTypePCIeBridgeAER:
+		return hestBridgeAERSize, nil
+	case HESTTypeGHES:
+		return hestGHESSize, nil
+	case HESTTypeGHESv2:
+		return hestGHESv2Size, nil
+	default:
+		return 0, fmt.Errorf("HEST has unknown error source type %d", typ)
+	}
+}
+
+func parseHESTErrorSource(buf []byte) (HESTErrorSource, error) {
+	var raw struct {
+		Type                 uint16
+		SourceID             uint16
+		RelatedSourceID      uint16
+		Flags                uint8
+		Enabled              uint8
+		RecordsToPreallocate uint32
+		MaxSectionsPerRecord uint32
+	}
+
+	ret := HESTErrorSource{Data: append([]byte{}, buf...)}
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read HEST error source: %v", err)
+	}
+	ret.Type = raw.Type
+	ret.SourceID = raw.SourceID
+
+	// the NMI error source has no flags
+	if raw.Type == HESTTypeIA32NMI {
+		ret.Enabled = true
+		ret.RecordsToPreallocate = binary.LittleEndian.Uint32(buf[8:])
+		ret.MaxSectionsPerRecord = binary.LittleEndian.Uint32(buf[12:])
+		return ret, nil
+	}
+	ret.Flags = raw.Flags
+	ret.Enabled = raw.Enabled != 0
+	ret.RecordsToPreallocate = raw.RecordsToPreallocate
+	ret.MaxSectionsPerRecord = raw.MaxSectionsPerRecord
+
+	readNotification := func(off int) error {
+		ret.Notification = &HESTNotification{}
+		return binary.Read(bytes.NewReader(buf[off:]), binary.LittleEndian, ret.Notification)
+	}
+	readBanks := func(off int) error {
+		ret.Banks = make([]HESTMachineCheckBank, (len(buf)-off)/hestBankSize)
+		return binary.Read(bytes.NewReader(buf[off:]), binary.LittleEndian, ret.Banks)
+	}
+
+	switch raw.Type {
+	case HESTTypeIA32MachineCheck:
+		err = readBanks(hestMachineCheckSize)
+	case HESTTypeIA32CorrectedMachineCheck, HESTTypeIA32DeferredMachineCheck:
+		err = readNotification(16)
+		if err == nil {
+			err = readBanks(hestCorrectedMachineCheckSize)
+		}
+	case HESTTypeGHES, HESTTypeGHESv2:
+		ret.RelatedSourceID = raw.RelatedSourceID
+		err = binary.Read(bytes.NewReader(buf[20:]), binary.LittleEndian, &ret.ErrorStatusAddress)
+		if err == nil {
+			err = readNotification(32)
+		}
+		ret.ErrorStatusBlockLength = binary.LittleEndian.Uint32(buf[60:])
+	}
+	if err != nil {
+		return ret, fmt.Errorf("cannot read HEST error source %d: %v", raw.Type, err)
+	}
+
+	return ret, nil
+}
+
+// ParseHEST decodes the ACPI HEST table
+func ParseHEST(buf []byte) (*HEST, error) {
+	var ret HEST
+	var hdr hestHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read HEST header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "HEST" {
+		return nil, fmt.Errorf("HEST has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("HEST has invalid length %d", hdr.Length)
+	}
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for i := uint32(0); i < hdr.ErrorSourceCount; i++ {
+		if len(buf) < 2 {
+			return nil, fmt.Errorf("HEST is too short for %d error sources", hdr.ErrorSourceCount)
+		}
+		size, err := hestErrorSourceSize(buf)
+		if err != nil {
+			return nil, err
+		}
+		if size > len(buf) {
+			return nil, fmt.Errorf("HEST error source %d exceeds table", binary.LittleEndian.Uint16(buf))
+		}
+		src, err := parseHESTErrorSource(buf[:size])
+		if err != nil {
+			return nil, err
+		}
+		ret.ErrorSources = append(ret.ErrorSources, src)
+		buf = buf[size:]
+	}
+
+	return &ret, nil
+}
+
+// ReadHEST reads the ACPI HEST table and decodes it
+func ReadHEST(h LowLevelHardwareInterfaces) (*HEST, error) {
+	buf, err := h.GetACPITable("HEST")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseHEST(buf)
+}
@@ -0,0 +1,51 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseHEST(t *testing.T) {
+	notify := HESTNotification{Type: HESTNotifySCI, Length: 28, PollInterval: 1000}
+	bank := HESTMachineCheckBank{BankNumber: 1, StatusRegisterMSR: 0x405}
+	status := ACPIGenericAddress{AddressSpaceID: ACPIAddressSpaceSystemMemory, RegisterBitWidth: 64, Address: 0x7c000000}
+
+	buf := acpiTestTable(t, "HEST", uint32(4),
+		// IA-32 machine check with two banks
+		uint16(HESTTypeIA32MachineCheck), uint16(0), uint16(0), uint8(0), uint8(1), uint32(1), uint32(1),
+		uint64(0), uint64(0), uint8(2), [7]uint8{}, bank, bank,
+		// NMI
+		uint16(HESTTypeIA32NMI), uint16(1), uint32(0), uint32(1), uint32(1), uint32(0x1000),
+		// PCIe root port AER
+		uint16(HESTTypePCIeRootPortAER), uint16(2), uint16(0), uint8(0), uint8(1), uint32(1), uint32(1),
+		[32]uint8{},
+		// GHES
+		uint16(HESTTypeGHES), uint16(3), uint16(0xffff), uint8(0), uint8(1), uint32(1), uint32(2),
+		uint32(0x1000), status, notify, uint32(0x1000),
+	)
+
+	hest, err := ParseHEST(buf)
+	if err != nil {
+		t.Fatalf("ParseHEST failed: %v", err)
+	}
+	if len(hest.ErrorSources) != 4 {
+		t.Fatalf("Unexpected error sources %+v", hest.ErrorSources)
+	}
+	if mc := hest.ErrorSources[0]; len(mc.Banks) != 2 || mc.Banks[1] != bank || !mc.Enabled {
+		t.Errorf("Unexpected machine check source %+v", mc)
+	}
+	if nmi := hest.ErrorSources[1]; nmi.SourceID != 1 || !nmi.Enabled || len(nmi.Data) != 20 {
+		t.Errorf("Unexpected NMI source %+v", nmi)
+	}
+	if aer := hest.ErrorSources[2]; aer.Type != HESTTypePCIeRootPortAER || len(aer.Data) != 48 {
+		t.Errorf("Unexpected AER source %+v", aer)
+	}
+	ghes := hest.ErrorSources[3]
+	if ghes.RelatedSourceID != 0xffff || ghes.MaxSectionsPerRecord != 2 || ghes.ErrorStatusAddress != status ||
+		ghes.Notification == nil || *ghes.Notification != notify || ghes.ErrorStatusBlockLength != 0x1000 {
+		t.Errorf("Unexpected GHES %+v", ghes)
+	}
+
+	if _, err := ParseHEST(acpiTestTable(t, "HEST", uint32(1), uint16(3), uint16(0))); err == nil {
+		t.Errorf("Unknown error source type wasn't rejected")
+	}
+}
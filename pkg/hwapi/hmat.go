@@ -0,0 +1,239 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// HMAT structure types
+const (
+	HMATTypeMemoryProximityDomain = 0
+	HMATTypeLocalityLatency       = 1
+	HMATTypeMemorySideCache       = 2
+)
+
+// HMAT system locality latency and bandwidth data types
+const (
+	HMATAccessLatency   = 0
+	HMATReadLatency     = 1
+	HMATWriteLatency    = 2
+	HMATAccessBandwidth = 3
+	HMATReadBandwidth   = 4
+	HMATWriteBandwidth  = 5
+)
+
+const (
+	// HMATEntryUnreachable marks an unreachable target in the latency and bandwidth matrix
+	HMATEntryUnreachable = 0xffff
+
+	hmatStructHdrSize = 8
+	// hmatInitiatorValid is the memory proximity domain attributes flag for a valid initiator
+	hmatInitiatorValid = 1 << 0
+)
+
+type hmatHeader struct {
+	ACPIHeader
+	Reserved uint32
+}
+
+// HMATMemoryProximityDomain holds the attributes of a memory proximity domain
+type HMATMemoryProximityDomain struct {
+	Flags uint16
+	// InitiatorProximityDomain is the attached initiator, valid if InitiatorValid returns true
+	InitiatorProximityDomain uint32
+	MemoryProximityDomain    uint32
+}
+
+// InitiatorValid returns true if the initiator proximity domain is set
+func (m HMATMemoryProximityDomain) InitiatorValid() bool {
+	return m.Flags&hmatInitiatorValid != 0
+}
+
+// HMATLocality is a system locality latency and bandwidth information structure
+type HMATLocality struct {
+	// Flags bits 3:0 are the memory hierarchy: 0 for memory, 1-3 for the cache levels
+	Flags            uint8
+	DataType         uint8
+	MinTransferSize  uint8
+	EntryBaseUnit    uint64
+	InitiatorDomains []uint32
+	TargetDomains    []uint32
+	// Entries holds the value from initiator i to target j at i*len(TargetDomains)+j
+	Entries []uint16
+}
+
+// Value returns the latency in picoseconds or the bandwidth in MB/s from the
+// initiator to the target domain. Returns false if not provided or unreachable.
+func (l *HMATLocality) Value(initiator, target uint32) (uint64, bool) {
+	for i, id := range l.InitiatorDomains {
+		if id != initiator {
+			continue
+		}
+		for j, td := range l.TargetDomains {
+			if td != target {
+				continue
+			}
+			e := l.Entries[i*len(l.TargetDomains)+j]
+			if e == 0 || e == HMATEntryUnreachable {
+				return 0, false
+			}
+			return uint64(e) * l.EntryBaseUnit, true
+		}
+	}
+	return 0, false
+}
+
+// HMATMemorySideCache describes the memory side cache of a memory proximity domain
+type HMATMemorySideCache struct {
+	MemoryProximityDomain uint32
+	CacheSize             uint64
+	// CacheAttributes holds the cache levels, associativity, write policy and line size
+	CacheAttributes uint32
+	SMBIOSHandles   []uint16
+}
+
+// HMAT is the decoded ACPI Heterogeneous Memory Attribute Table
+type HMAT struct {
+	MemoryProximityDomains []HMATMemoryProximityDomain
+	Localities             []HMATLocality
+	MemorySideCaches       []HMATMemorySideCache
+}
+
+func parseHMATLocality(buf []byte) (HMATLocality, error) {
+	var ret HMATLocality
+	var raw struct {
+		Flags            uint8
+		DataType         uint8
+		MinTransferSize  uint8
+		Reserved1        uint8
+		InitiatorDomains uint32
+		TargetDomains    uint32
+		Reserved2        uint32
+		EntryBaseUnit    uint64
+	}
+
+	reader := bytes.NewReader(buf)
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read HMAT locality: %v", err)
+	}
+	n, m := uint64(raw.InitiatorDomains), uint64(raw.TargetDomains)
+	if uint64(binary.Size(raw))+(n+m)*4+n*m*2 > uint64(len(buf)) {
+		return ret, fmt.Errorf("HMAT locality is too short for %d initiators and %d targets", n, m)
+	}
+	ret.Flags = raw.Flags
+	ret.DataType = raw.DataType
+	ret.MinTransferSize = raw.MinTransferSize
+	ret.EntryBaseUnit = raw.EntryBaseUnit
+	ret.InitiatorDomains = make([]uint32, n)
+	ret.TargetDomains = make([]uint32, m)
+	ret.Entries = make([]uint16, n*m)
+	for _, v := range []interface{}{ret.InitiatorDomains, ret.TargetDomains, ret.Entries} {
+		err = binary.Read(reader, binary.LittleEndian, v)
+		if err != nil {
+			return ret, fmt.Errorf("cannot read HMAT locality: %v", err)
+		}
+	}
+
+	return ret, nil
+}
+
+func parseHMATMemorySideCache(buf []byte) (HMATMemorySideCache, error) {
+	var ret HMATMemorySideCache
+	var raw struct {
+		MemoryProximityDomain uint32
+		Reserved1             uint32
+		CacheSize             uint64
+		CacheAttributes       uint32
+		Reserved2             uint16
+		SMBIOSHandles         uint16
+	}
+
+	reader := bytes.NewReader(buf)
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read HMAT memory side cache: %v", err)
+	}
+	ret.MemoryProximityDomain = raw.MemoryProximityDomain
+	ret.CacheSize = raw.CacheSize
+	ret.CacheAttributes = raw.CacheAttributes
+	ret.SMBIOSHandles = make([]uint16, raw.SMBIOSHandles)
+	err = binary.Read(reader, binary.LittleEndian, ret.SMBIOSHandles)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read HMAT memory side cache: %v", err)
+	}
+
+	return ret, nil
+}
+
+// ParseHMAT decodes the ACPI HMAT table
+func ParseHMAT(buf []byte) (*HMAT, error) {
+	var ret HMAT
+	var hdr hmatHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read HMAT header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "HMAT" {
+		return nil, fmt.Errorf("HMAT has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("HMAT has invalid length %d", hdr.Length)
+	}
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= hmatStructHdrSize {
+		typ := binary.LittleEndian.Uint16(buf)
+		length := binary.LittleEndian.Uint32(buf[4:])
+		if length < hmatStructHdrSize || uint64(length) > uint64(len(buf)) {
+			return nil, fmt.Errorf("HMAT structure %d has invalid length %d", typ, length)
+		}
+		data := buf[hmatStructHdrSize:length]
+
+		switch typ {
+		case HMATTypeMemoryProximityDomain:
+			var raw struct {
+				Flags                    uint16
+				Reserved                 uint16
+				InitiatorProximityDomain uint32
+				MemoryProximityDomain    uint32
+			}
+			err = binary.Read(bytes.NewReader(data), binary.LittleEndian, &raw)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read HMAT memory proximity domain: %v", err)
+			}
+			ret.MemoryProximityDomains = append(ret.MemoryProximityDomains, HMATMemoryProximityDomain{
+				Flags:                    raw.Flags,
+				InitiatorProximityDomain: raw.InitiatorProximityDomain,
+				MemoryProximityDomain:    raw.MemoryProximityDomain,
+			})
+		case HMATTypeLocalityLatency:
+			l, err := parseHMATLocality(data)
+			if err != nil {
+				return nil, err
+			}
+			ret.Localities = append(ret.Localities, l)
+		case HMATTypeMemorySideCache:
+			c, err := parseHMATMemorySideCache(data)
+			if err != nil {
+				return nil, err
+			}
+			ret.MemorySideCaches = append(ret.MemorySideCaches, c)
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadHMAT reads the ACPI HMAT table and decodes it
+func ReadHMAT(h LowLevelHardwareInterfaces) (*HMAT, error) {
+	buf, err := h.GetACPITable("HMAT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseHMAT(buf)
+}
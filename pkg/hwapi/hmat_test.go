@@ -0,0 +1,42 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseHMAT(t *testing.T) {
+	buf := acpiTestTable(t, "HMAT", uint32(0),
+		// memory proximity domain 1 attached to initiator 0
+		uint16(HMATTypeMemoryProximityDomain), uint16(0), uint32(40), uint16(1), uint16(0), uint32(0), uint32(1), [20]byte{},
+		// read latency from initiators 0, 1 to targets 0, 1 in units of 100ps
+		uint16(HMATTypeLocalityLatency), uint16(0), uint32(32+16+8), uint8(0), uint8(HMATReadLatency), [2]byte{},
+		uint32(2), uint32(2), uint32(0), uint64(100), []uint32{0, 1}, []uint32{0, 1}, []uint16{10, 20, 0xffff, 0},
+		// memory side cache with one SMBIOS handle
+		uint16(HMATTypeMemorySideCache), uint16(0), uint32(34), uint32(1), uint32(0), uint64(1<<30), uint32(0x1011), uint16(0), uint16(1), uint16(0x20))
+
+	hmat, err := ParseHMAT(buf)
+	if err != nil {
+		t.Fatalf("ParseHMAT failed: %v", err)
+	}
+	if len(hmat.MemoryProximityDomains) != 1 || !hmat.MemoryProximityDomains[0].InitiatorValid() ||
+		hmat.MemoryProximityDomains[0].MemoryProximityDomain != 1 {
+		t.Errorf("Unexpected memory proximity domains %+v", hmat.MemoryProximityDomains)
+	}
+	if len(hmat.Localities) != 1 {
+		t.Fatalf("Got %d localities, want 1", len(hmat.Localities))
+	}
+	l := hmat.Localities[0]
+	if v, ok := l.Value(0, 1); !ok || v != 2000 {
+		t.Errorf("Value(0, 1) = %d, %v, want 2000", v, ok)
+	}
+	if _, ok := l.Value(1, 0); ok {
+		t.Errorf("Value(1, 0) returned an unreachable entry")
+	}
+	if _, ok := l.Value(1, 1); ok {
+		t.Errorf("Value(1, 1) returned an entry not provided")
+	}
+	if len(hmat.MemorySideCaches) != 1 || hmat.MemorySideCaches[0].CacheSize != 1<<30 ||
+		len(hmat.MemorySideCaches[0].SMBIOSHandles) != 1 {
+		t.Errorf("Unexpected memory side caches %+v", hmat.MemorySideCaches)
+	}
+}
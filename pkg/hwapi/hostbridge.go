@@ -8,6 +8,8 @@ import (
 const (
 	// TsegPCIRegSandyAndNewer is the offset withing the MCH PCI config space since SandyBridge
 	TsegPCIRegSandyAndNewer = 0xb8
+	// TsegLimitPCIRegSandyAndNewer is the offset of BGSM, which marks the end of TSEG, since SandyBridge
+	TsegLimitPCIRegSandyAndNewer = 0xb4
 	// TSEGPCIBroadwellde is the offset withing the MCH PCI config space
 	TSEGPCIBroadwellde = 0xa8
 
@@ -104,74 +106,128 @@ var (
 	}
 )
 
-//ReadHostBridgeTseg returns TSEG base and TSEG limit
-func ReadHostBridgeTseg(h LowLevelHardwareInterfaces) (uint32, uint32, error) {
-	var tsegBaseOff int
-	var tsegLimitOff int
-	var tsegBroadwellDEfix bool
-	var devicenum int
+// TSEG is the decoded TSEG range of the host bridge
+type TSEG struct {
+	// Base is the first address of TSEG
+	Base uint64
+	// Limit is the first address after TSEG
+	Limit uint64
+	// Size of TSEG in bytes
+	Size uint64
+	// Locked is not decoded on Broadwell-DE
+	Locked bool
+}
 
-	vendorid, err := h.PCIReadConfigSpace(pciHostbridge, 0, 2)
+func pciReadConfig32(h LowLevelHardwareInterfaces, d PCIDevice, off int) (uint32, error) {
+	buf, err := h.PCIReadConfigSpace(d, off, 4)
 	if err != nil {
-		return 0, 0, err
+		return 0, err
 	}
-	if binary.LittleEndian.Uint16(vendorid) != 0x8086 {
-		return 0, 0, fmt.Errorf("hostbridge is not made by Intel")
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+func isHostbridgeBroadwellDE(id uint16) bool {
+	for _, i := range HostbridgeIDsBroadwellDE {
+		if i == id {
+			return true
+		}
 	}
-	deviceid, err := h.PCIReadConfigSpace(pciHostbridge, 2, 2)
+	return false
+}
+
+// decodeTsegSandy decodes TSEGMB and BGSM. TSEG ends where the GTT stolen memory starts.
+func decodeTsegSandy(tsegmb, bgsm uint32) (*TSEG, error) {
+	ret := TSEG{
+		Base:   uint64(tsegmb & 0xfff00000),
+		Limit:  uint64(bgsm & 0xfff00000),
+		Locked: tsegmb&1 != 0,
+	}
+	if ret.Limit < ret.Base {
+		return nil, fmt.Errorf("TSEG limit %x is below TSEG base %x", ret.Limit, ret.Base)
+	}
+	ret.Size = ret.Limit - ret.Base
+
+	return &ret, nil
+}
+
+// decodeTsegBroadwellDE decodes the TSEG base and limit registers of the VT-d device
+func decodeTsegBroadwellDE(base, limit uint32) (*TSEG, error) {
+	// On BroadwellDe TSEG limit lower 19bits are don't care, thus add 1 MiB.
+	ret := TSEG{
+		Base:  uint64(base & 0xfff00000),
+		Limit: uint64(limit&0xfff00000) + 1024*1024,
+	}
+	if ret.Limit < ret.Base {
+		return nil, fmt.Errorf("TSEG limit %x is below TSEG base %x", ret.Limit, ret.Base)
+	}
+	ret.Size = ret.Limit - ret.Base
+
+	return &ret, nil
+}
+
+// ReadTSEG reads and decodes the TSEG range of the host bridge
+func ReadTSEG(h LowLevelHardwareInterfaces) (*TSEG, error) {
+	id, err := readHostbridgeDeviceID(h)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 
-	var found bool
-	for _, id := range HostbridgeIDsSandyCompatible {
-		if id == binary.LittleEndian.Uint16(deviceid) {
-			found = true
-			tsegBaseOff = TsegPCIRegSandyAndNewer
-			tsegLimitOff = TsegPCIRegSandyAndNewer + 4
-			devicenum = 0
-			break
+	if isHostbridgeSandyCompatible(id) {
+		tsegmb, err := pciReadConfig32(h, pciHostbridge, TsegPCIRegSandyAndNewer)
+		if err != nil {
+			return nil, err
 		}
-	}
-	if !found {
-		for _, id := range HostbridgeIDsBroadwellDE {
-			if id == binary.LittleEndian.Uint16(deviceid) {
-				found = true
-				tsegBroadwellDEfix = true
-				tsegBaseOff = TSEGPCIBroadwellde
-				tsegLimitOff = TSEGPCIBroadwellde + 4
-				devicenum = 5
-				break
-			}
+		bgsm, err := pciReadConfig32(h, pciHostbridge, TsegLimitPCIRegSandyAndNewer)
+		if err != nil {
+			return nil, err
 		}
+		return decodeTsegSandy(tsegmb, bgsm)
 	}
 
-	if !found {
-		return 0, 0, fmt.Errorf("hostbridge is unsupported")
+	if isHostbridgeBroadwellDE(id) {
+		vtdDev := PCIDevice{
+			Bus:      0,
+			Device:   5,
+			Function: 0,
+		}
+		base, err := pciReadConfig32(h, vtdDev, TSEGPCIBroadwellde)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := pciReadConfig32(h, vtdDev, TSEGPCIBroadwellde+4)
+		if err != nil {
+			return nil, err
+		}
+		return decodeTsegBroadwellDE(base, limit)
 	}
 
-	tsegDev := PCIDevice{
-		Bus:      0,
-		Device:   devicenum,
-		Function: 0,
-	}
+	return nil, fmt.Errorf("hostbridge is unsupported")
+}
 
-	tsegbase, err := h.PCIReadConfigSpace(tsegDev, tsegBaseOff, 4)
+// ReadHostBridgeTseg returns TSEG base and TSEG limit, the limit being the first address after TSEG
+func ReadHostBridgeTseg(h LowLevelHardwareInterfaces) (uint32, uint32, error) {
+	tseg, err := ReadTSEG(h)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	tseglimit, err := h.PCIReadConfigSpace(tsegDev, tsegLimitOff, 4)
+	return uint32(tseg.Base), uint32(tseg.Limit), nil
+}
+
+// CheckTSEGReservedInE820 verifies that TSEG is marked as reserved in the e820 table
+func CheckTSEGReservedInE820(h LowLevelHardwareInterfaces, tseg *TSEG) error {
+	if tseg.Size == 0 {
+		return fmt.Errorf("TSEG is empty")
+	}
+	reserved, err := IsReservedInE820(h, tseg.Base, tseg.Limit-1)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
-	var limit uint32
-	if tsegBroadwellDEfix {
-		// On BroadwellDe TSEG limit lower 19bits are don't care, thus add 1 MiB.
-		limit = binary.LittleEndian.Uint32(tseglimit) + 1024*1024
+	if !reserved {
+		return fmt.Errorf("TSEG [%x-%x] is not reserved in e820", tseg.Base, tseg.Limit-1)
 	}
 
-	return binary.LittleEndian.Uint32(tsegbase), limit, nil
+	return nil
 }
 
 //DMAProtectedRange encodes the DPR register
@@ -183,6 +239,71 @@ type DMAProtectedRange struct {
 	Top uint16
 }
 
+// Limit returns the first address after the DMA protected range
+func (d DMAProtectedRange) Limit() uint64 {
+	return uint64(d.Top) << 20
+}
+
+// ByteSize returns the size of the DMA protected range in bytes
+func (d DMAProtectedRange) ByteSize() uint64 {
+	return uint64(d.Size) << 20
+}
+
+// Base returns the first address of the DMA protected range
+func (d DMAProtectedRange) Base() uint64 {
+	if d.ByteSize() > d.Limit() {
+		return 0
+	}
+	return d.Limit() - d.ByteSize()
+}
+
+// Range returns the DMA protected range
+func (d DMAProtectedRange) Range() MemoryRange {
+	return MemoryRange{Name: "DPR", Base: d.Base(), Size: d.Limit() - d.Base()}
+}
+
+// CheckDPRBelowTSEG verifies that the DMA protected range ends where TSEG starts
+func CheckDPRBelowTSEG(dpr DMAProtectedRange, tseg *TSEG) error {
+	if dpr.ByteSize() == 0 {
+		return fmt.Errorf("DPR is empty")
+	}
+	if dpr.ByteSize() > dpr.Limit() {
+		return fmt.Errorf("DPR size %x exceeds DPR top %x", dpr.ByteSize(), dpr.Limit())
+	}
+	if dpr.Limit() != tseg.Base {
+		return fmt.Errorf("DPR [%x-%x] isn't directly below TSEG at %x", dpr.Base(), dpr.Limit()-1, tseg.Base)
+	}
+
+	return nil
+}
+
+// CheckDPRIsDMAProtected verifies that the DMA protected range is reserved in
+// the e820 table and shielded from DMA by the IOMMU
+func CheckDPRIsDMAProtected(h LowLevelHardwareInterfaces, dpr DMAProtectedRange) error {
+	r := dpr.Range()
+	if r.Size == 0 {
+		return fmt.Errorf("DPR is empty")
+	}
+
+	reserved, err := IsReservedInE820(h, r.Base, r.End()-1)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return fmt.Errorf("DPR [%x-%x] is not reserved in e820", r.Base, r.End()-1)
+	}
+
+	protected, err := AddressRangesIsDMAProtected(h, r.Base, r.End()-1)
+	if err != nil {
+		return err
+	}
+	if !protected {
+		return fmt.Errorf("DPR [%x-%x] is not DMA protected", r.Base, r.End()-1)
+	}
+
+	return nil
+}
+
 //ReadHostBridgeDPR reads the DPR register from PCI config space
 func ReadHostBridgeDPR(h LowLevelHardwareInterfaces) (DMAProtectedRange, error) {
 	var dprOff int
@@ -236,9 +357,319 @@ func ReadHostBridgeDPR(h LowLevelHardwareInterfaces) (DMAProtectedRange, error)
 		return ret, err
 	}
 
-	ret.Lock = binary.LittleEndian.Uint32(u32)&1 != 0
-	ret.Size = uint8((binary.LittleEndian.Uint32(u32) >> 4) & 0xff)   // 11:4
-	ret.Top = uint16((binary.LittleEndian.Uint32(u32) >> 20) & 0xfff) // 31:20
+	return decodeDPR(binary.LittleEndian.Uint32(u32)), nil
+}
+
+// Host bridge register offsets within the MCH PCI config space since SandyBridge
+const (
+	hostbridgeRegEPBAR      = 0x40
+	hostbridgeRegMCHBAR     = 0x48
+	hostbridgeRegGGC        = 0x50
+	hostbridgeRegDPR        = 0x5c
+	hostbridgeRegPCIEXBAR   = 0x60
+	hostbridgeRegDMIBAR     = 0x68
+	hostbridgeRegMESEGBase  = 0x70
+	hostbridgeRegMESEGMask  = 0x78
+	hostbridgeRegREMAPBASE  = 0x90
+	hostbridgeRegREMAPLIMIT = 0x98
+	hostbridgeRegTOM        = 0xa0
+	hostbridgeRegTOUUD      = 0xa8
+	hostbridgeRegBDSM       = 0xb0
+	hostbridgeRegBGSM       = 0xb4
+	hostbridgeRegTSEGMB     = 0xb8
+	hostbridgeRegTOLUD      = 0xbc
+
+	// hostbridgeAddrMask masks bits 41:20 of the memory map registers
+	hostbridgeAddrMask = 0x3fffff00000
+	// hostbridgeBARMask masks bits 41:12 of the BAR registers
+	hostbridgeBARMask = 0x3fffffff000
+)
+
+// HostBridgeRegister is a decoded address register of the host bridge
+type HostBridgeRegister struct {
+	Address uint64
+	Locked  bool
+}
+
+// HostBridgeBAR is a decoded MMIO BAR of the host bridge
+type HostBridgeBAR struct {
+	Base    uint64
+	Size    uint64
+	Enabled bool
+}
+
+// GraphicsControl encodes the GGC register
+type GraphicsControl struct {
+	Locked bool
+	// IGD VGA disable
+	VGADisabled bool
+	// GTTSize is the size of the GTT stolen memory in bytes
+	GTTSize uint64
+	// GMSSize is the size of the graphics data stolen memory in bytes
+	GMSSize uint64
+}
+
+// HostMemoryMap is the memory map as programmed into the host bridge
+type HostMemoryMap struct {
+	TOM        HostBridgeRegister
+	TOLUD      HostBridgeRegister
+	TOUUD      HostBridgeRegister
+	RemapBase  HostBridgeRegister
+	RemapLimit HostBridgeRegister
+	BDSM       HostBridgeRegister
+	BGSM       HostBridgeRegister
+	TSEGMB     HostBridgeRegister
+	GGC        GraphicsControl
+	DPR        DMAProtectedRange
+
+	MESEGBase    uint64
+	MESEGSize    uint64
+	MESEGEnabled bool
+	MESEGLocked  bool
+
+	PCIEXBAR HostBridgeBAR
+	MCHBAR   HostBridgeBAR
+	DMIBAR   HostBridgeBAR
+	EPBAR    HostBridgeBAR
+}
+
+// MemoryRange is a physical address range [Base; Base+Size)
+type MemoryRange struct {
+	Name string
+	Base uint64
+	Size uint64
+}
+
+// End returns the first address after the range
+func (r MemoryRange) End() uint64 {
+	return r.Base + r.Size
+}
+
+// Overlaps returns true if the range overlaps [start; end], end being inclusive
+func (r MemoryRange) Overlaps(start, end uint64) bool {
+	return r.Size > 0 && start < r.End() && end >= r.Base
+}
+
+// GraphicsStolenMemory returns the data stolen memory range of the IGD
+func (m *HostMemoryMap) GraphicsStolenMemory() MemoryRange {
+	return MemoryRange{Name: "graphics stolen memory", Base: m.BDSM.Address, Size: m.TOLUD.Address - m.BDSM.Address}
+}
+
+// GTTStolenMemory returns the GTT stolen memory range of the IGD
+func (m *HostMemoryMap) GTTStolenMemory() MemoryRange {
+	return MemoryRange{Name: "GTT stolen memory", Base: m.BGSM.Address, Size: m.BDSM.Address - m.BGSM.Address}
+}
+
+// TSEG returns the TSEG memory range
+func (m *HostMemoryMap) TSEG() MemoryRange {
+	return MemoryRange{Name: "TSEG", Base: m.TSEGMB.Address, Size: m.BGSM.Address - m.TSEGMB.Address}
+}
+
+// MEUMA returns the ME UMA memory range, its size is zero if MESEG isn't enabled
+func (m *HostMemoryMap) MEUMA() MemoryRange {
+	if !m.MESEGEnabled {
+		return MemoryRange{Name: "ME UMA"}
+	}
+	return MemoryRange{Name: "ME UMA", Base: m.MESEGBase, Size: m.MESEGSize}
+}
+
+// ReservedRanges returns all memory ranges that must not be used as RAM by the OS
+func (m *HostMemoryMap) ReservedRanges() []MemoryRange {
+	return []MemoryRange{
+		m.GraphicsStolenMemory(),
+		m.GTTStolenMemory(),
+		m.TSEG(),
+		m.MEUMA(),
+	}
+}
+
+func readHostbridgeDeviceID(h LowLevelHardwareInterfaces) (uint16, error) {
+	vendorid, err := h.PCIReadConfigSpace(pciHostbridge, 0, 2)
+	if err != nil {
+		return 0, err
+	}
+	if binary.LittleEndian.Uint16(vendorid) != 0x8086 {
+		return 0, fmt.Errorf("hostbridge is not made by Intel")
+	}
+	deviceid, err := h.PCIReadConfigSpace(pciHostbridge, 2, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(deviceid), nil
+}
+
+func isHostbridgeSandyCompatible(id uint16) bool {
+	for _, i := range HostbridgeIDsSandyCompatible {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hostbridgeHasGen8GGC returns true if the GGC register uses the Broadwell and newer layout
+func hostbridgeHasGen8GGC(id uint16) bool {
+	switch id & 0xff00 {
+	case 0x0100, 0x0a00, 0x0c00, 0x0d00:
+		return false
+	}
+	return true
+}
+
+func decodeHostBridgeRegister(v uint64) HostBridgeRegister {
+	return HostBridgeRegister{
+		Address: v & hostbridgeAddrMask,
+		Locked:  v&1 != 0,
+	}
+}
+
+func decodeHostBridgeBAR(v uint64, size uint64) HostBridgeBAR {
+	return HostBridgeBAR{
+		Base:    v & hostbridgeBARMask &^ (size - 1),
+		Size:    size,
+		Enabled: v&1 != 0,
+	}
+}
+
+func decodeGraphicsControl(v uint16, gen8 bool) GraphicsControl {
+	var ret GraphicsControl
+
+	ret.Locked = v&1 != 0
+	ret.VGADisabled = v&2 != 0
+	if gen8 {
+		// GGMS 7:6, GMS 15:8
+		if ggms := (v >> 6) & 3; ggms > 0 {
+			ret.GTTSize = (1 << ggms) * 1024 * 1024
+		}
+		gms := uint64(v >> 8)
+		if gms < 0xf0 {
+			ret.GMSSize = gms * 32 * 1024 * 1024
+		} else {
+			ret.GMSSize = (gms - 0xef) * 4 * 1024 * 1024
+		}
+	} else {
+		// GGMS 9:8, GMS 7:3
+		if ggms := (v >> 8) & 3; ggms > 0 {
+			ret.GTTSize = (1 << (ggms - 1)) * 1024 * 1024
+		}
+		ret.GMSSize = uint64((v>>3)&0x1f) * 32 * 1024 * 1024
+	}
+
+	return ret
+}
+
+func decodeDPR(v uint32) DMAProtectedRange {
+	var ret DMAProtectedRange
+
+	ret.Lock = v&1 != 0
+	ret.Size = uint8((v >> 4) & 0xff)   // 11:4
+	ret.Top = uint16((v >> 20) & 0xfff) // 31:20
+
+	return ret
+}
+
+// parseHostMemoryMap decodes the first 256 bytes of the host bridge config space
+func parseHostMemoryMap(cfg []byte, gen8 bool) (*HostMemoryMap, error) {
+	var ret HostMemoryMap
+
+	if len(cfg) < 0x100 {
+		return nil, fmt.Errorf("host bridge config space too short: %d bytes", len(cfg))
+	}
+	u16 := func(off int) uint16 { return binary.LittleEndian.Uint16(cfg[off:]) }
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(cfg[off:]) }
+	u64 := func(off int) uint64 { return binary.LittleEndian.Uint64(cfg[off:]) }
+
+	ret.TOM = decodeHostBridgeRegister(u64(hostbridgeRegTOM))
+	ret.TOLUD = decodeHostBridgeRegister(uint64(u32(hostbridgeRegTOLUD)))
+	ret.TOUUD = decodeHostBridgeRegister(u64(hostbridgeRegTOUUD))
+	ret.RemapBase = decodeHostBridgeRegister(u64(hostbridgeRegREMAPBASE))
+	ret.RemapLimit = decodeHostBridgeRegister(u64(hostbridgeRegREMAPLIMIT))
+	// REMAPLIMIT is inclusive, the lower 20 bits are implicitly set
+	ret.RemapLimit.Address |= 0xfffff
+	ret.BDSM = decodeHostBridgeRegister(uint64(u32(hostbridgeRegBDSM)))
+	ret.BGSM = decodeHostBridgeRegister(uint64(u32(hostbridgeRegBGSM)))
+	ret.TSEGMB = decodeHostBridgeRegister(uint64(u32(hostbridgeRegTSEGMB)))
+	ret.GGC = decodeGraphicsControl(u16(hostbridgeRegGGC), gen8)
+	ret.DPR = decodeDPR(u32(hostbridgeRegDPR))
+
+	mesegMask := u64(hostbridgeRegMESEGMask)
+	ret.MESEGBase = u64(hostbridgeRegMESEGBase) & hostbridgeAddrMask
+	ret.MESEGLocked = mesegMask&(1<<10) != 0
+	ret.MESEGEnabled = mesegMask&(1<<11) != 0
+	if mask := mesegMask & hostbridgeAddrMask; mask != 0 {
+		// the size is given by the lowest bit set in the mask
+		ret.MESEGSize = mask & (^mask + 1)
+	}
+
+	// PCIEXBAR length is encoded in bits 2:1
+	pciexbar := u64(hostbridgeRegPCIEXBAR)
+	var pciexSize uint64
+	switch (pciexbar >> 1) & 3 {
+	case 0:
+		pciexSize = 256 * 1024 * 1024
+	case 1:
+		pciexSize = 128 * 1024 * 1024
+	case 2:
+		pciexSize = 64 * 1024 * 1024
+	case 3:
+		pciexSize = 512 * 1024 * 1024
+	}
+	ret.PCIEXBAR = decodeHostBridgeBAR(pciexbar, pciexSize)
+	ret.MCHBAR = decodeHostBridgeBAR(u64(hostbridgeRegMCHBAR), 32*1024)
+	ret.DMIBAR = decodeHostBridgeBAR(u64(hostbridgeRegDMIBAR), 4*1024)
+	ret.EPBAR = decodeHostBridgeBAR(u64(hostbridgeRegEPBAR), 4*1024)
+
+	if ret.TSEGMB.Address > ret.BGSM.Address || ret.BGSM.Address > ret.BDSM.Address ||
+		ret.BDSM.Address > ret.TOLUD.Address {
+		return &ret, fmt.Errorf("host memory map is inconsistent: TSEGMB %x, BGSM %x, BDSM %x, TOLUD %x",
+			ret.TSEGMB.Address, ret.BGSM.Address, ret.BDSM.Address, ret.TOLUD.Address)
+	}
+
+	return &ret, nil
+}
+
+// ReadHostMemoryMap reads and decodes the memory map registers of the host bridge
+func ReadHostMemoryMap(h LowLevelHardwareInterfaces) (*HostMemoryMap, error) {
+	id, err := readHostbridgeDeviceID(h)
+	if err != nil {
+		return nil, err
+	}
+	if !isHostbridgeSandyCompatible(id) {
+		return nil, fmt.Errorf("hostbridge is unsupported")
+	}
+
+	cfg, err := h.PCIReadConfigSpace(pciHostbridge, 0, 0x100)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHostMemoryMap(cfg, hostbridgeHasGen8GGC(id))
+}
+
+// CheckHostMemoryMapE820 verifies that none of the ranges reserved by the host
+// bridge overlaps usable RAM in the e820 table
+func CheckHostMemoryMapE820(h LowLevelHardwareInterfaces, m *HostMemoryMap) error {
+	var overlap *MemoryRange
+	var ramStart, ramEnd uint64
+
+	_, err := h.IterateOverE820Ranges("system ram", func(start uint64, end uint64) bool {
+		for _, r := range m.ReservedRanges() {
+			if r.Overlaps(start, end) {
+				overlap = &r
+				ramStart = start
+				ramEnd = end
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if overlap != nil {
+		return fmt.Errorf("%s [%x-%x] overlaps usable RAM [%x-%x]",
+			overlap.Name, overlap.Base, overlap.End()-1, ramStart, ramEnd)
+	}
 
-	return ret, nil
+	return nil
 }
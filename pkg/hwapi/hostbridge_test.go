@@ -0,0 +1,128 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseHostMemoryMap(t *testing.T) {
+	cfg := make([]byte, 0x100)
+
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegPCIEXBAR:], 0xe0000001)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegMCHBAR:], 0xfed10001)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegDMIBAR:], 0xfed18001)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegEPBAR:], 0xfed19000)
+	// GMS 0x02 (64 MiB), GGMS 3 (8 MiB), locked
+	binary.LittleEndian.PutUint16(cfg[hostbridgeRegGGC:], 0x02c1)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegMESEGBase:], 0x47f000000)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegMESEGMask:], 0x7fff000c00)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegTOM:], 0x480000001)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegTOUUD:], 0x47f000001)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegREMAPBASE:], 0x47f000000)
+	binary.LittleEndian.PutUint64(cfg[hostbridgeRegREMAPLIMIT:], 0x3fff00000)
+	binary.LittleEndian.PutUint32(cfg[hostbridgeRegTSEGMB:], 0x7b000001)
+	binary.LittleEndian.PutUint32(cfg[hostbridgeRegBGSM:], 0x7b800001)
+	binary.LittleEndian.PutUint32(cfg[hostbridgeRegBDSM:], 0x7c000001)
+	binary.LittleEndian.PutUint32(cfg[hostbridgeRegTOLUD:], 0x80000001)
+
+	m, err := parseHostMemoryMap(cfg, true)
+	if err != nil {
+		t.Fatalf("parseHostMemoryMap failed: %v", err)
+	}
+
+	if m.TOLUD.Address != 0x80000000 || !m.TOLUD.Locked {
+		t.Errorf("Unexpected TOLUD: %+v", m.TOLUD)
+	}
+	if m.TOM.Address != 0x480000000 {
+		t.Errorf("Unexpected TOM: %x", m.TOM.Address)
+	}
+	if m.RemapLimit.Address != 0x3ffffffff {
+		t.Errorf("Unexpected REMAPLIMIT: %x", m.RemapLimit.Address)
+	}
+	if m.GGC.GMSSize != 64*1024*1024 || m.GGC.GTTSize != 8*1024*1024 || !m.GGC.Locked {
+		t.Errorf("Unexpected GGC: %+v", m.GGC)
+	}
+	if r := m.GraphicsStolenMemory(); r.Base != 0x7c000000 || r.Size != 64*1024*1024 {
+		t.Errorf("Unexpected graphics stolen memory: %+v", r)
+	}
+	if r := m.TSEG(); r.Base != 0x7b000000 || r.Size != 8*1024*1024 {
+		t.Errorf("Unexpected TSEG: %+v", r)
+	}
+	if r := m.MEUMA(); r.Base != 0x47f000000 || r.Size != 16*1024*1024 || !m.MESEGLocked {
+		t.Errorf("Unexpected ME UMA: %+v", r)
+	}
+	if !m.PCIEXBAR.Enabled || m.PCIEXBAR.Base != 0xe0000000 || m.PCIEXBAR.Size != 256*1024*1024 {
+		t.Errorf("Unexpected PCIEXBAR: %+v", m.PCIEXBAR)
+	}
+	if !m.MCHBAR.Enabled || m.MCHBAR.Base != 0xfed10000 {
+		t.Errorf("Unexpected MCHBAR: %+v", m.MCHBAR)
+	}
+	if m.EPBAR.Enabled {
+		t.Errorf("Unexpected EPBAR: %+v", m.EPBAR)
+	}
+
+	// BGSM above BDSM
+	binary.LittleEndian.PutUint32(cfg[hostbridgeRegBGSM:], 0x7d000001)
+	if _, err := parseHostMemoryMap(cfg, true); err == nil {
+		t.Errorf("Inconsistent memory map wasn't detected")
+	}
+}
+
+func TestDecodeTseg(t *testing.T) {
+	tseg, err := decodeTsegSandy(0x7b000001, 0x7b800001)
+	if err != nil {
+		t.Fatalf("decodeTsegSandy failed: %v", err)
+	}
+	if tseg.Base != 0x7b000000 || tseg.Limit != 0x7b800000 || tseg.Size != 0x800000 || !tseg.Locked {
+		t.Errorf("Unexpected TSEG: %+v", tseg)
+	}
+
+	tseg, err = decodeTsegBroadwellDE(0x7b000000, 0x7b7fffff)
+	if err != nil {
+		t.Fatalf("decodeTsegBroadwellDE failed: %v", err)
+	}
+	if tseg.Base != 0x7b000000 || tseg.Limit != 0x7b800000 || tseg.Size != 0x800000 {
+		t.Errorf("Unexpected TSEG: %+v", tseg)
+	}
+
+	if _, err := decodeTsegSandy(0x7b800000, 0x7b000000); err == nil {
+		t.Errorf("TSEG limit below base wasn't detected")
+	}
+}
+
+func TestDPRRange(t *testing.T) {
+	dpr := decodeDPR(0x7b000031)
+	if !dpr.Lock || dpr.Base() != 0x7ad00000 || dpr.Limit() != 0x7b000000 || dpr.ByteSize() != 3*1024*1024 {
+		t.Errorf("Unexpected DPR: %+v", dpr)
+	}
+
+	if err := CheckDPRBelowTSEG(dpr, &TSEG{Base: 0x7b000000, Limit: 0x7b800000, Size: 0x800000}); err != nil {
+		t.Errorf("CheckDPRBelowTSEG failed: %v", err)
+	}
+	if err := CheckDPRBelowTSEG(dpr, &TSEG{Base: 0x7c000000, Limit: 0x7c800000, Size: 0x800000}); err == nil {
+		t.Errorf("DPR not below TSEG wasn't detected")
+	}
+	if err := CheckDPRBelowTSEG(decodeDPR(0x7b000000), &TSEG{Base: 0x7b000000}); err == nil {
+		t.Errorf("Empty DPR wasn't detected")
+	}
+}
+
+func TestHostbridgeHasGen8GGC(t *testing.T) {
+	for _, tc := range []struct {
+		id   uint16
+		gen8 bool
+	}{
+		{0x0104, false}, // Sandy Bridge
+		{0x0154, false}, // Ivy Bridge
+		{0x0a04, false}, // Haswell ULT
+		{0x0c00, false}, // Haswell
+		{0x0d04, false}, // Haswell with eDRAM
+		{0x1604, true},  // Broadwell
+		{0x1904, true},  // Skylake
+		{0x3e0f, true},  // Coffee Lake
+	} {
+		if got := hostbridgeHasGen8GGC(tc.id); got != tc.gen8 {
+			t.Errorf("hostbridgeHasGen8GGC(%#04x) = %v, want %v", tc.id, got, tc.gen8)
+		}
+	}
+}
@@ -95,23 +95,67 @@ type VTdRegisters struct {
 	Reserved12                              uint64 // Reserved for future expansion of Virtual Command Response Register.
 }
 
-func readVTdRegs(l LowLevelHardwareInterfaces) (VTdRegisters, error) {
+// VTdUnit is a DMA remapping hardware unit
+type VTdUnit struct {
+	Base    uint64
+	Segment uint16
+	// IncludePCIAll is set if the unit covers all PCI devices not covered by other units
+	IncludePCIAll bool
+	// Scopes is empty if the unit wasn't found in the DMAR table
+	Scopes []DMARDeviceScope
+	Regs   VTdRegisters
+}
+
+// CoversDMACapableDevices returns true if PCI devices are behind the unit, in
+// contrast to units that only remap interrupts of IOAPICs and HPETs
+func (u *VTdUnit) CoversDMACapableDevices() bool {
+	if u.IncludePCIAll {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s.IsPCI() {
+			return true
+		}
+	}
+	return false
+}
+
+func readVTdRegsAt(l LowLevelHardwareInterfaces, addr uint64) (VTdRegisters, error) {
 	var regs VTdRegisters
 
+	buf := make([]byte, unsafe.Sizeof(regs))
+	err := l.ReadPhysBuf(int64(addr), buf)
+	if err != nil {
+		return regs, err
+	}
+
+	reader := bytes.NewReader(buf)
+	err = binary.Read(reader, binary.LittleEndian, &regs)
+	if err != nil {
+		return regs, err
+	}
+
+	return regs, nil
+}
+
+func readVTdAddressesSysfs() ([]uint64, error) {
+	var ret []uint64
+
 	dir, err := os.Open("/sys/class/iommu/")
 	if err != nil {
-		return regs, fmt.Errorf("no IOMMU found: %s", err)
+		return nil, fmt.Errorf("no IOMMU found: %s", err)
 	}
+	defer dir.Close()
 
 	subdirs, err := dir.Readdir(0)
 	if err != nil {
-		return regs, fmt.Errorf("no IOMMU found: %s", err)
+		return nil, fmt.Errorf("no IOMMU found: %s", err)
 	}
 
 	for _, subdir := range subdirs {
 		path := fmt.Sprintf("/sys/class/iommu/%s/intel-iommu/address", subdir.Name())
 		addrBuf, err := os.ReadFile(path)
-		if err != nil {
+		if err != nil || len(addrBuf) == 0 {
 			continue
 		}
 
@@ -119,211 +163,555 @@ func readVTdRegs(l LowLevelHardwareInterfaces) (VTdRegisters, error) {
 		if err != nil {
 			continue
 		}
+		ret = append(ret, addr)
+	}
 
-		buf := make([]byte, unsafe.Sizeof(regs))
-		err = l.ReadPhysBuf(int64(addr), buf)
-		if err != nil {
-			continue
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no IOMMU found: /sys/class/iommu/*/intel-iommu/address does not exists or is malformed")
+	}
+
+	return ret, nil
+}
+
+// ReadVTdUnits returns all DMA remapping hardware units. The units are taken
+// from the ACPI DMAR table, sysfs is used as fallback.
+func ReadVTdUnits(l LowLevelHardwareInterfaces) ([]VTdUnit, error) {
+	var ret []VTdUnit
+
+	dmar, err := ReadDMAR(l)
+	if err == nil && len(dmar.DRHDs) > 0 {
+		for _, drhd := range dmar.DRHDs {
+			regs, err := readVTdRegsAt(l, drhd.RegisterBase)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read IOMMU at %x: %v", drhd.RegisterBase, err)
+			}
+			ret = append(ret, VTdUnit{
+				Base:          drhd.RegisterBase,
+				Segment:       drhd.Segment,
+				IncludePCIAll: drhd.IncludePCIAll(),
+				Scopes:        drhd.Scopes,
+				Regs:          regs,
+			})
 		}
+		return ret, nil
+	}
 
-		reader := bytes.NewReader(buf)
-		err = binary.Read(reader, binary.LittleEndian, &regs)
+	addrs, err := readVTdAddressesSysfs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		regs, err := readVTdRegsAt(l, addr)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("cannot read IOMMU at %x: %v", addr, err)
 		}
-
-		return regs, nil
+		// Without the DMAR table the scope is unknown, assume the unit covers devices
+		ret = append(ret, VTdUnit{
+			Base:          addr,
+			IncludePCIAll: true,
+			Regs:          regs,
+		})
 	}
 
-	return regs, fmt.Errorf("no IOMMU found: /sys/class/iommu/*/intel-iommu/address does not exists or is malformed")
+	return ret, nil
+}
+
+// IOTranslation is the translation of an I/O virtual address for a single device
+type IOTranslation struct {
+	Bus      int
+	Device   int
+	Function int
+	// PASID is only valid in scalable mode
+	PASID    uint32
+	HasPASID bool
+	// Address is the host physical address the IOVA translates to
+	Address  uint64
+	PageSize uint64
+	Read     bool
+	Write    bool
+	// PassThrough is set if the DMA of the device isn't translated
+	PassThrough bool
+}
+
+// LookupIOAddress translates the I/O virtual address for every device behind the IOMMU
+func (h HwAPI) LookupIOAddress(addr uint64, regs VTdRegisters) ([]IOTranslation, error) {
+	return lookupIOAddress(h, addr, regs)
 }
 
-// LookupIOAddress returns the address of the root Tbl
-func (h HwAPI) LookupIOAddress(addr uint64, regs VTdRegisters) ([]uint64, error) {
-	rootTblAddr := regs.RootTableAddress & 0xffffffffffff000
+func lookupIOAddress(l LowLevelHardwareInterfaces, addr uint64, regs VTdRegisters) ([]IOTranslation, error) {
+	rootTblAddr := regs.RootTableAddress & vtdAddrMask
 	ttm := (regs.RootTableAddress >> 10) & 3
 
 	if ttm == 0 {
-		return lookupIOLegacy(addr, rootTblAddr, h)
+		return lookupIOLegacy(l, addr, rootTblAddr, regs.Capabilities)
 	} else if ttm == 1 {
-		return lookupIOScalable(addr, rootTblAddr)
+		return lookupIOScalable(addr, rootTblAddr, l)
 	} else {
-		return []uint64{}, fmt.Errorf("unsupported IOMMU Translation Table Mode")
+		return nil, fmt.Errorf("unsupported IOMMU Translation Table Mode")
 	}
 }
 
-func lookupIOLegacy(addr, rootTblAddr uint64, l LowLevelHardwareInterfaces) ([]uint64, error) {
-	ret := []uint64{}
-
-	for bus := int64(0); bus < 256; bus++ {
-		// read root table entries
-		var rootTblEnt Uint64
-		err := l.ReadPhys(int64(rootTblAddr)+bus*16+8, &rootTblEnt)
+// iterateLegacyContexts invokes the callback for every present legacy-mode context entry
+func iterateLegacyContexts(l LowLevelHardwareInterfaces, rootTblAddr uint64,
+	callback func(bus, devfn int, ctx [2]uint64) error) error {
+	for bus := 0; bus < 256; bus++ {
+		var rootEnt Uint64
+		err := l.ReadPhys(int64(rootTblAddr)+int64(bus)*16, &rootEnt)
 		if err != nil {
-			return []uint64{}, err
+			return err
 		}
-
-		if rootTblEnt&1 == 0 {
+		if rootEnt&1 == 0 {
 			continue
 		}
+		ctxTblAddr := uint64(rootEnt) & vtdAddrMask
 
-		// we assume 48 bit physical addresses
-		ctxTblAddr := rootTblEnt & 0xffffffffffff000
-
-		// read ctx entry
-		var ctxTblEntHi Uint64
-		var ctxTblEntLo Uint64
-
-		for devfn := int64(0); devfn < 32*8; devfn++ {
-			err = l.ReadPhys(int64(ctxTblAddr)+devfn*16+8, &ctxTblEntLo)
+		for devfn := 0; devfn < 256; devfn++ {
+			var ctx [2]uint64
+			err = readPhysQwords(l, ctxTblAddr+uint64(devfn)*16, ctx[:])
 			if err != nil {
-				return []uint64{}, err
+				return err
 			}
-
-			if ctxTblEntLo&1 == 0 {
+			if ctx[0]&1 == 0 {
 				continue
 			}
-
-			err = l.ReadPhys(int64(ctxTblAddr)+devfn*16, &ctxTblEntHi)
+			err = callback(bus, devfn, ctx)
 			if err != nil {
-				return []uint64{}, err
+				return err
 			}
+		}
+	}
 
-			tt := (ctxTblEntLo >> 2) & 3
-			aw := (ctxTblEntHi >> 2) & 7
-			pml5Addr := ctxTblEntLo & 0xffffffffffff000
+	return nil
+}
 
-			if tt == 2 {
-				ret = append(ret, addr)
-				continue
+// lookupIOLegacy translates addr using the legacy-mode context entries.
+// caps is the capability register, its SAGAW field limits the supported address widths.
+func lookupIOLegacy(l LowLevelHardwareInterfaces, addr, rootTblAddr, caps uint64) ([]IOTranslation, error) {
+	ret := []IOTranslation{}
+	mgaw := uint((caps>>16)&0x3f) + 1
+
+	// DMA requests above the maximum guest address width are blocked
+	if mgaw < 64 && addr >= uint64(1)<<mgaw {
+		return ret, nil
+	}
+
+	err := iterateLegacyContexts(l, rootTblAddr, func(bus, devfn int, ctx [2]uint64) error {
+		t := IOTranslation{Bus: bus, Device: devfn >> 3, Function: devfn & 7}
+
+		// translation type
+		switch (ctx[0] >> 2) & 3 {
+		case 0, 1:
+			levels, err := vtdContextLevels(caps, ctx[1]&7)
+			if err != nil {
+				return fmt.Errorf("%02x:%02x.%x: %v", bus, devfn>>3, devfn&7, err)
+			}
+			if addr>>uint(12+9*levels) != 0 {
+				return nil
+			}
+			x, err := walkIOPageTable(l, ctx[0]&vtdAddrMask, levels, ioPageTableSecondLevel, addr, nil)
+			if err != nil || x == nil {
+				return err
 			}
+			t.Address = x.Address
+			t.PageSize = x.PageSize
+			t.Read = x.Read
+			t.Write = x.Write
+		case 2:
+			t.Address = addr
+			t.PageSize = 4096
+			t.Read = true
+			t.Write = true
+			t.PassThrough = true
+		default:
+			return nil
+		}
+		ret = append(ret, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			var pdptAddr Uint64
-			var canRead bool
+	return ret, nil
+}
 
-			if aw == 3 || aw == 2 {
-				// Page map level 5
-				var l5ent Uint64
-				err = l.ReadPhys(int64(pml5Addr)+(int64(addr>>48)&0x1ff)*8, &l5ent)
-				if err != nil {
-					return []uint64{}, err
-				}
+const (
+	// vtdAddrMask masks bits 51:12 of table pointers and page table entries
+	vtdAddrMask = 0x000ffffffffff000
 
-				pml4Addr := l5ent & 0xffffffffffff000
-				canRead = l5ent&1 != 0
+	// PASID granular translation types of a scalable-mode PASID table entry
+	vtdPGTTFirstLevel  = 1
+	vtdPGTTSecondLevel = 2
+	vtdPGTTNested      = 3
+	vtdPGTTPassThrough = 4
+)
 
-				if !canRead {
-					continue
-				}
+// ioPageTableFormat selects the page table entry format
+type ioPageTableFormat int
 
-				if aw == 2 {
-					// Page map level 4
-					var l4ent Uint64
-					err = l.ReadPhys(int64(pml4Addr)+(int64(addr>>39)&0x1ff)*8, &l4ent)
-					if err != nil {
-						return []uint64{}, err
-					}
+const (
+	// ioPageTableSecondLevel uses bit 0 and 1 as read and write permission
+	ioPageTableSecondLevel ioPageTableFormat = iota
+	// ioPageTableFirstLevel uses the IA-32e paging format
+	ioPageTableFirstLevel
+	// ioPageTableAMD uses the AMD IOMMU format, which may skip levels
+	ioPageTableAMD
+)
 
-					pdptAddr = l4ent & 0xffffffffffff000
-					canRead = l4ent&1 != 0
+// ioTranslation is the result of a page walk
+type ioTranslation struct {
+	Address  uint64
+	PageSize uint64
+	Read     bool
+	Write    bool
+}
 
-					if !canRead {
-						continue
-					}
-				} else {
-					pdptAddr = pml4Addr
-				}
-			} else {
-				pdptAddr = pml5Addr
-			}
+// vtdAWLevels converts the address width field of context or PASID table entries into the number of levels
+func vtdAWLevels(aw uint64) (int, error) {
+	switch aw {
+	case 1:
+		return 3, nil
+	case 2:
+		return 4, nil
+	case 3:
+		return 5, nil
+	}
+	return 0, fmt.Errorf("unsupported address width %d", aw)
+}
 
-			// Page directory pointer table
-			var l3ent Uint64
-			err = l.ReadPhys(int64(pdptAddr)+(int64(addr>>30)&0x1ff)*8, &l3ent)
-			if err != nil {
-				return []uint64{}, err
-			}
+// vtdContextLevels returns the number of page table levels of a legacy context
+// entry with address width aw. The width must be supported by the SAGAW field of
+// the capability register caps.
+func vtdContextLevels(caps, aw uint64) (int, error) {
+	sagaw := (caps >> 8) & 0x1f
+	if sagaw&(1<<aw) == 0 {
+		return 0, fmt.Errorf("address width %d not supported by the IOMMU", aw)
+	}
+	return vtdAWLevels(aw)
+}
 
-			pdAddr := l3ent & 0xffffffffffff000
-			canRead = l3ent&1 != 0
+// ioPageTableEntry is a decoded page table entry
+type ioPageTableEntry struct {
+	Present bool
+	Read    bool
+	Write   bool
+	// Next is the level of the next table, 0 if the entry maps a page
+	Next     int
+	Address  uint64
+	PageSize uint64
+}
 
-			if !canRead {
-				continue
+// decodeIOPageTableEntry decodes a page table entry found at the given level
+func decodeIOPageTableEntry(format ioPageTableFormat, ent uint64, level int) ioPageTableEntry {
+	var ret ioPageTableEntry
+	leaf := level == 1
+
+	switch format {
+	case ioPageTableSecondLevel:
+		ret.Present = ent&3 != 0
+		ret.Read = ent&1 != 0
+		ret.Write = ent&2 != 0
+		// Super pages are only supported on the PDPE and PDE level
+		leaf = leaf || (level <= 3 && ent&(1<<7) != 0)
+	case ioPageTableFirstLevel:
+		ret.Present = ent&1 != 0
+		ret.Read = true
+		ret.Write = ent&2 != 0
+		leaf = leaf || (level <= 3 && ent&(1<<7) != 0)
+	case ioPageTableAMD:
+		ret.Present = ent&1 != 0
+		ret.Read = ent&(1<<61) != 0
+		ret.Write = ent&(1<<62) != 0
+		switch next := int(ent>>9) & 7; {
+		case next == 7:
+			// Larger pages are replicated over multiple entries, the page size
+			// is encoded by the number of trailing ones of the address
+			size := uint64(1) << 13
+			for a := ent >> 12; a&1 != 0 && size < uint64(1)<<uint(12+9*level); a >>= 1 {
+				size <<= 1
 			}
+			ret.Address = ent & vtdAddrMask &^ (size - 1)
+			ret.PageSize = size
+			return ret
+		case next == 0:
+			leaf = true
+		case next < level:
+			ret.Next = next
+			ret.Address = ent & vtdAddrMask
+			return ret
+		default:
+			// pointing to the same or a higher level is invalid
+			ret.Present = false
+			return ret
+		}
+	}
 
-			// Page directory
-			var l2ent Uint64
-			err = l.ReadPhys(int64(pdAddr)+(int64(addr>>21)&0x1ff)*8, &l2ent)
-			if err != nil {
-				return []uint64{}, err
+	if leaf {
+		ret.PageSize = uint64(1) << uint(12+9*(level-1))
+		ret.Address = ent & vtdAddrMask &^ (ret.PageSize - 1)
+	} else {
+		ret.Next = level - 1
+		ret.Address = ent & vtdAddrMask
+	}
+	return ret
+}
+
+// walkIOPageTable translates addr through the page table at table with the
+// given number of levels. If xlate isn't nil, it's used to translate the
+// address of every table, as needed by nested translation. Returns nil if addr
+// isn't mapped.
+func walkIOPageTable(l LowLevelHardwareInterfaces, table uint64, levels int, format ioPageTableFormat,
+	addr uint64, xlate func(uint64) (*ioTranslation, error)) (*ioTranslation, error) {
+	read, write := true, true
+
+	for level := levels; level > 0; {
+		if xlate != nil {
+			t, err := xlate(table)
+			if err != nil || t == nil {
+				return nil, err
 			}
+			table = t.Address
+		}
+
+		shift := uint(12 + 9*(level-1))
+		var ent Uint64
+		err := l.ReadPhys(int64(table+((addr>>shift)&0x1ff)*8), &ent)
+		if err != nil {
+			return nil, err
+		}
 
-			ptAddr := l2ent & 0xffffffffffff000
-			canRead = l2ent&1 != 0
+		pte := decodeIOPageTableEntry(format, uint64(ent), level)
+		if !pte.Present {
+			return nil, nil
+		}
+		read = read && pte.Read
+		write = write && pte.Write
+
+		if pte.Next == 0 {
+			return &ioTranslation{
+				Address:  pte.Address | (addr & (pte.PageSize - 1)),
+				PageSize: pte.PageSize,
+				Read:     read,
+				Write:    write,
+			}, nil
+		}
 
-			if !canRead {
-				continue
-			}
+		// the address bits of skipped levels must be zero
+		skipped := uint(9 * (level - 1 - pte.Next))
+		if (addr>>uint(12+9*pte.Next))&(1<<skipped-1) != 0 {
+			return nil, nil
+		}
+		table = pte.Address
+		level = pte.Next
+	}
 
-			// Page table
-			var l1ent Uint64
-			err = l.ReadPhys(int64(ptAddr)+(int64(addr>>12)&0x1ff)*8, &l1ent)
-			if err != nil {
-				return []uint64{}, err
-			}
+	return nil, nil
+}
+
+// translateScalablePASIDEntry translates addr using the first three qwords of a scalable-mode PASID table entry
+func translateScalablePASIDEntry(l LowLevelHardwareInterfaces, pe [3]uint64, addr uint64) (*ioTranslation, error) {
+	secondLevel := func(a uint64) (*ioTranslation, error) {
+		levels, err := vtdAWLevels((pe[0] >> 2) & 7)
+		if err != nil {
+			return nil, err
+		}
+		return walkIOPageTable(l, pe[0]&vtdAddrMask, levels, ioPageTableSecondLevel, a, nil)
+	}
+	firstLevelLevels := 4
+	if (pe[2]>>2)&3 == 1 {
+		firstLevelLevels = 5
+	}
+	firstLevelTable := pe[2] & vtdAddrMask
+
+	switch (pe[0] >> 6) & 7 {
+	case vtdPGTTFirstLevel:
+		return walkIOPageTable(l, firstLevelTable, firstLevelLevels, ioPageTableFirstLevel, addr, nil)
+	case vtdPGTTSecondLevel:
+		return secondLevel(addr)
+	case vtdPGTTNested:
+		// first-level tables and the resulting address are guest physical addresses
+		gpa, err := walkIOPageTable(l, firstLevelTable, firstLevelLevels, ioPageTableFirstLevel, addr, secondLevel)
+		if err != nil || gpa == nil {
+			return nil, err
+		}
+		hpa, err := secondLevel(gpa.Address)
+		if err != nil || hpa == nil {
+			return nil, err
+		}
+		hpa.Read = hpa.Read && gpa.Read
+		hpa.Write = hpa.Write && gpa.Write
+		if gpa.PageSize < hpa.PageSize {
+			hpa.PageSize = gpa.PageSize
+		}
+		return hpa, nil
+	case vtdPGTTPassThrough:
+		return &ioTranslation{Address: addr, PageSize: 4096, Read: true, Write: true}, nil
+	}
 
-			pageAddr := l1ent & 0xffffffffffff000
-			canRead = l1ent&1 != 0
+	return nil, fmt.Errorf("unsupported PASID granular translation type %d", (pe[0]>>6)&7)
+}
+
+func readPhysQwords(l LowLevelHardwareInterfaces, addr uint64, out []uint64) error {
+	for i := range out {
+		var q Uint64
+		err := l.ReadPhys(int64(addr)+int64(i)*8, &q)
+		if err != nil {
+			return err
+		}
+		out[i] = uint64(q)
+	}
+	return nil
+}
 
-			if !canRead {
+// iterateScalablePASIDEntries invokes the callback for every present PASID table
+// entry reachable from the scalable-mode root table
+func iterateScalablePASIDEntries(l LowLevelHardwareInterfaces, rootTblAddr uint64,
+	callback func(bus, devfn int, pasid uint32, pe [3]uint64) error) error {
+	for bus := 0; bus < 256; bus++ {
+		var rootEnt [2]uint64
+		err := readPhysQwords(l, rootTblAddr+uint64(bus)*16, rootEnt[:])
+		if err != nil {
+			return err
+		}
+
+		// lower half covers devfn 0-127, upper half devfn 128-255
+		for half, ent := range rootEnt {
+			if ent&1 == 0 {
 				continue
 			}
+			ctxTblAddr := ent & vtdAddrMask
 
-			ret = append(ret, uint64(pageAddr)+(addr&0xffff))
+			for i := 0; i < 128; i++ {
+				var ctxEnt [2]uint64
+				err = readPhysQwords(l, ctxTblAddr+uint64(i)*32, ctxEnt[:])
+				if err != nil {
+					return err
+				}
+				if ctxEnt[0]&1 == 0 {
+					continue
+				}
+
+				pasidDirAddr := ctxEnt[0] & vtdAddrMask
+				dirEntries := 1 << (((ctxEnt[0] >> 9) & 7) + 7)
+				for d := 0; d < dirEntries; d++ {
+					var dirEnt Uint64
+					err = l.ReadPhys(int64(pasidDirAddr)+int64(d)*8, &dirEnt)
+					if err != nil {
+						return err
+					}
+					if dirEnt&1 == 0 {
+						continue
+					}
+
+					pasidTblAddr := uint64(dirEnt) & vtdAddrMask
+					for p := 0; p < 64; p++ {
+						var pe [3]uint64
+						err = readPhysQwords(l, pasidTblAddr+uint64(p)*64, pe[:])
+						if err != nil {
+							return err
+						}
+						if pe[0]&1 == 0 {
+							continue
+						}
+						err = callback(bus, half*128+i, uint32(d<<6|p), pe)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
 		}
 	}
 
-	return ret, nil
+	return nil
 }
 
-func lookupIOScalable(addr, rootTblAddr uint64) ([]uint64, error) {
-	return []uint64{}, fmt.Errorf("scalable IOMMU not implemented")
-	// read root table entry
+func lookupIOScalable(addr, rootTblAddr uint64, l LowLevelHardwareInterfaces) ([]IOTranslation, error) {
+	ret := []IOTranslation{}
 
-	// read ctx entry
-	// get page table depth
+	err := iterateScalablePASIDEntries(l, rootTblAddr, func(bus, devfn int, pasid uint32, pe [3]uint64) error {
+		x, err := translateScalablePASIDEntry(l, pe, addr)
+		if err != nil {
+			return fmt.Errorf("%02x:%02x.%x PASID %x: %v", bus, devfn>>3, devfn&7, pasid, err)
+		}
+		if x != nil {
+			ret = append(ret, IOTranslation{
+				Bus:         bus,
+				Device:      devfn >> 3,
+				Function:    devfn & 7,
+				PASID:       pasid,
+				HasPASID:    true,
+				Address:     x.Address,
+				PageSize:    x.PageSize,
+				Read:        x.Read,
+				Write:       x.Write,
+				PassThrough: (pe[0]>>6)&7 == vtdPGTTPassThrough,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// read req-w/o-PASID directory pointer (5 level)
-	// make sure 2-pass translation isnt on
+	return ret, nil
 }
 
-// AddressRangesIsDMAProtected returns true if the address is DMA protected by the IOMMU
+// AddressRangesIsDMAProtected returns true if the address is DMA protected by
+// every IOMMU that covers DMA capable devices
 func AddressRangesIsDMAProtected(l LowLevelHardwareInterfaces, first, end uint64) (bool, error) {
-	regs, err := readVTdRegs(l)
+	units, err := ReadVTdUnits(l)
 	if err != nil {
-		return false, err
+		amdUnits, amdErr := ReadAMDVIUnits(l)
+		if amdErr != nil || len(amdUnits) == 0 {
+			return false, err
+		}
+		return addressRangeIsDMAProtectedByAMDVI(l, amdUnits, first, end)
 	}
 
-	loDMAprotection := regs.Capabilities&(1<<5) != 0
-	hiDMAprotection := regs.Capabilities&(1<<6) != 0
-	enableDMAprotection := regs.Capabilities&1 != 0
-	enable2DMAprotection := regs.Capabilities&(1<<31) != 0
+	var checked int
+	for i := range units {
+		if !units[i].CoversDMACapableDevices() {
+			continue
+		}
+		checked++
+		protected, err := addressRangeIsDMAProtectedByUnit(l, &units[i], first, end)
+		if err != nil {
+			return false, fmt.Errorf("IOMMU at %x: %v", units[i].Base, err)
+		}
+		if !protected {
+			return false, nil
+		}
+	}
+	if checked == 0 {
+		return false, fmt.Errorf("no IOMMU covers DMA capable devices")
+	}
 
-	if enableDMAprotection && enable2DMAprotection && loDMAprotection && uint64(regs.ProtectedLowMemoryBase) <= first && uint64(regs.ProtectedLowMemoryLimit) >= end {
-		return true, err
+	return true, nil
+}
+
+func addressRangeIsDMAProtectedByUnit(l LowLevelHardwareInterfaces, unit *VTdUnit, first, end uint64) (bool, error) {
+	pmr := readPMRConfig(unit)
+	if pmr.Covers(first, end) {
+		return true, nil
 	}
 
-	if enableDMAprotection && enable2DMAprotection && hiDMAprotection && regs.ProtectedHighMemoryBase <= first && regs.ProtectedHighMemoryLimit >= end {
-		return true, err
+	mappings, err := FindDMAMappings(l, unit, first, end)
+	if err != nil {
+		return false, err
 	}
 
-	for addr := first & 0xffffffffffff0000; addr < end; addr += 4096 {
-		vas, err := l.LookupIOAddress(addr, regs)
+	return len(mappings) == 0, nil
+}
+
+// addressRangeIsDMAProtectedByAMDVI returns true if no device behind any of the AMD IOMMUs reaches the range
+func addressRangeIsDMAProtectedByAMDVI(l LowLevelHardwareInterfaces, units []AMDVIUnit, first, end uint64) (bool, error) {
+	for i := range units {
+		mappings, err := FindAMDVIDMAMappings(l, &units[i], first, end)
 		if err != nil {
-			return false, err
+			return false, fmt.Errorf("IOMMU at %x: %v", units[i].Base, err)
 		}
-
-		if len(vas) < 1 {
+		if len(mappings) > 0 {
 			return false, nil
 		}
 	}
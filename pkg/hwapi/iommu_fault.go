@@ -0,0 +1,213 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// vtdRegFaultStatus is the offset of the fault status register
+	vtdRegFaultStatus = 0x34
+	// vtdFaultStatusRW1C are the bits of the fault status register cleared by writing 1
+	vtdFaultStatusRW1C = 0x7d
+)
+
+// vtdFaultReasons describes the non-recoverable fault reasons of legacy mode
+var vtdFaultReasons = map[uint8]string{
+	0x1: "root entry not present",
+	0x2: "context entry not present",
+	0x3: "invalid context entry",
+	0x4: "address beyond the address width",
+	0x5: "write to a read-only page",
+	0x6: "read from a write-only page",
+	0x7: "invalid page table pointer",
+	0x8: "invalid root table pointer",
+	0x9: "invalid context table pointer",
+	0xa: "reserved bits set in root entry",
+	0xb: "reserved bits set in context entry",
+	0xc: "reserved bits set in page table entry",
+	0xd: "translation type blocked",
+}
+
+// IOMMUFault is a fault recorded by an IOMMU
+type IOMMUFault struct {
+	// Index is the number of the fault recording register or advanced fault log entry
+	Index int
+	// SourceID is the requester ID (bus, device, function) of the faulting request
+	SourceID uint16
+	Reason   uint8
+	// Address is the page address of the faulting request
+	Address  uint64
+	PASID    uint32
+	HasPASID bool
+	// Read is set for read requests, cleared for write requests
+	Read       bool
+	Execute    bool
+	Privileged bool
+	// AddressType is the AT field of the faulting request
+	AddressType uint8
+}
+
+// Source returns the bus, device and function of the faulting request
+func (f IOMMUFault) Source() (bus, device, function int) {
+	return int(f.SourceID >> 8), int(f.SourceID>>3) & 0x1f, int(f.SourceID) & 7
+}
+
+func (f IOMMUFault) String() string {
+	bus, dev, fn := f.Source()
+	access := "write"
+	if f.Read {
+		access = "read"
+	}
+	reason, ok := vtdFaultReasons[f.Reason]
+	if !ok {
+		reason = fmt.Sprintf("fault reason %#x", f.Reason)
+	}
+	if f.HasPASID {
+		return fmt.Sprintf("%02x:%02x.%x PASID %x: %s of %x: %s", bus, dev, fn, f.PASID, access, f.Address, reason)
+	}
+	return fmt.Sprintf("%02x:%02x.%x: %s of %x: %s", bus, dev, fn, access, f.Address, reason)
+}
+
+// IOMMUFaultStatus holds the fault status and pending faults of an IOMMU
+type IOMMUFaultStatus struct {
+	// Unit is the register base of the IOMMU
+	Unit                        uint64
+	PrimaryFaultOverflow        bool
+	PrimaryPendingFault         bool
+	AdvancedFaultOverflow       bool
+	AdvancedPendingFault        bool
+	InvalidationQueueError      bool
+	InvalidationCompletionError bool
+	InvalidationTimeoutError    bool
+	// FaultRecordIndex is the index of the first pending fault recording register
+	FaultRecordIndex int
+	// Faults are the pending primary faults
+	Faults []IOMMUFault
+	// AdvancedFaults are the entries of the advanced fault log
+	AdvancedFaults []IOMMUFault
+
+	faultRecordOffset uint64
+}
+
+// parseIOMMUFaultRecord decodes a 128 bit fault record. Returns false if the fault bit is clear.
+func parseIOMMUFaultRecord(index int, lo, hi uint64) (IOMMUFault, bool) {
+	return IOMMUFault{
+		Index:       index,
+		SourceID:    uint16(hi),               // 79:64
+		Privileged:  hi&(1<<29) != 0,          // 93
+		Execute:     hi&(1<<30) != 0,          // 94
+		HasPASID:    hi&(1<<31) != 0,          // 95
+		Reason:      uint8(hi >> 32),          // 103:96
+		PASID:       uint32(hi>>40) & 0xfffff, // 123:104
+		AddressType: uint8(hi>>60) & 3,        // 125:124
+		Read:        hi&(1<<62) != 0,          // 126
+		Address:     lo &^ 0xfff,              // 63:12
+	}, hi&(1<<63) != 0 // 127
+}
+
+func readIOMMUFaultStatus(l LowLevelHardwareInterfaces, unit *VTdUnit) (*IOMMUFaultStatus, error) {
+	caps := unit.Regs.Capabilities
+	fsts := unit.Regs.FaultStatus
+	ret := IOMMUFaultStatus{
+		Unit:                        unit.Base,
+		PrimaryFaultOverflow:        fsts&(1<<0) != 0,
+		PrimaryPendingFault:         fsts&(1<<1) != 0,
+		AdvancedFaultOverflow:       fsts&(1<<2) != 0,
+		AdvancedPendingFault:        fsts&(1<<3) != 0,
+		InvalidationQueueError:      fsts&(1<<4) != 0,
+		InvalidationCompletionError: fsts&(1<<5) != 0,
+		InvalidationTimeoutError:    fsts&(1<<6) != 0,
+		FaultRecordIndex:            int(fsts>>8) & 0xff,
+		faultRecordOffset:           ((caps >> 24) & 0x3ff) * 16,
+	}
+
+	// number of fault recording registers
+	nfr := int((caps>>40)&0xff) + 1
+	for i := 0; i < nfr; i++ {
+		var frr [2]uint64
+		err := readPhysQwords(l, unit.Base+ret.faultRecordOffset+uint64(i)*16, frr[:])
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := parseIOMMUFaultRecord(i, frr[0], frr[1]); ok {
+			ret.Faults = append(ret.Faults, f)
+		}
+	}
+
+	// advanced fault logging
+	logAddr := unit.Regs.AdvancedFaultLog &^ 0xfff
+	if caps&(1<<3) != 0 && logAddr != 0 {
+		logSize := (1 << ((unit.Regs.AdvancedFaultLog >> 9) & 7)) * 4096
+		buf := make([]byte, logSize)
+		err := l.ReadPhysBuf(int64(logAddr), buf)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read advanced fault log: %v", err)
+		}
+		for i := 0; i < len(buf)/16; i++ {
+			lo := binary.LittleEndian.Uint64(buf[i*16:])
+			hi := binary.LittleEndian.Uint64(buf[i*16+8:])
+			if f, ok := parseIOMMUFaultRecord(i, lo, hi); ok {
+				ret.AdvancedFaults = append(ret.AdvancedFaults, f)
+			}
+		}
+	}
+
+	return &ret, nil
+}
+
+// ReadIOMMUFaults returns the fault status and pending faults of every IOMMU
+func ReadIOMMUFaults(h LowLevelHardwareInterfaces) ([]IOMMUFaultStatus, error) {
+	var ret []IOMMUFaultStatus
+
+	units, err := ReadVTdUnits(h)
+	if err != nil {
+		return nil, err
+	}
+	for i := range units {
+		status, err := readIOMMUFaultStatus(h, &units[i])
+		if err != nil {
+			return nil, fmt.Errorf("IOMMU at %x: %v", units[i].Base, err)
+		}
+		ret = append(ret, *status)
+	}
+
+	return ret, nil
+}
+
+// ClearIOMMUFaults clears the primary faults and fault status bits previously
+// returned by ReadIOMMUFaults. Faults recorded in the meantime stay pending.
+// The advanced fault log is left untouched.
+func ClearIOMMUFaults(h LowLevelHardwareInterfaces, status []IOMMUFaultStatus) error {
+	for _, s := range status {
+		for _, f := range s.Faults {
+			// F is bit 127 of the fault record and write 1 to clear
+			addr := s.Unit + s.faultRecordOffset + uint64(f.Index)*16 + 12
+			v := Uint32(1 << 31)
+			err := h.WritePhys(int64(addr), &v)
+			if err != nil {
+				return fmt.Errorf("IOMMU at %x: cannot clear fault %d: %v", s.Unit, f.Index, err)
+			}
+		}
+
+		var fsts uint32
+		for i, set := range []bool{s.PrimaryFaultOverflow, false, s.AdvancedFaultOverflow,
+			s.AdvancedPendingFault, s.InvalidationQueueError, s.InvalidationCompletionError,
+			s.InvalidationTimeoutError} {
+			if set {
+				fsts |= 1 << uint(i)
+			}
+		}
+		fsts &= vtdFaultStatusRW1C
+		if fsts == 0 {
+			continue
+		}
+		v := Uint32(fsts)
+		err := h.WritePhys(int64(s.Unit+vtdRegFaultStatus), &v)
+		if err != nil {
+			return fmt.Errorf("IOMMU at %x: cannot clear fault status: %v", s.Unit, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestReadIOMMUFaultStatus(t *testing.T) {
+	mem := newPhysMemImage()
+	const base = 0xfed90000
+
+	// fault recording register 2: write by 03:1c.4 to 0x12345000, address beyond AW
+	mem.write64(base+0x200+2*16, 0x12345000)
+	mem.write64(base+0x200+2*16+8, 1<<63|4<<32|0x03e4)
+	// fault recording register 3: read by 00:02.0 with PASID 5, fault bit clear
+	mem.write64(base+0x200+3*16, 0x1000)
+	mem.write64(base+0x200+3*16+8, 1<<62|5<<40|1<<31|6<<32|0x0010)
+
+	unit := VTdUnit{
+		Base: base,
+		Regs: VTdRegisters{
+			// 4 fault recording registers at offset 0x200
+			Capabilities: 3<<40 | 0x20<<24,
+			FaultStatus:  2<<8 | 1<<1 | 1<<0,
+		},
+	}
+	status, err := readIOMMUFaultStatus(mem, &unit)
+	if err != nil {
+		t.Fatalf("readIOMMUFaultStatus failed: %v", err)
+	}
+	if !status.PrimaryPendingFault || !status.PrimaryFaultOverflow || status.FaultRecordIndex != 2 {
+		t.Errorf("Got fault status %+v", status)
+	}
+	if len(status.Faults) != 1 {
+		t.Fatalf("Got faults %v, want a single one", status.Faults)
+	}
+	f := status.Faults[0]
+	bus, dev, fn := f.Source()
+	if f.Index != 2 || bus != 3 || dev != 0x1c || fn != 4 || f.Read || f.Address != 0x12345000 || f.Reason != 4 {
+		t.Errorf("Got fault %+v", f)
+	}
+	if f.String() != "03:1c.4: write of 12345000: address beyond the address width" {
+		t.Errorf("Got %q", f.String())
+	}
+
+	// clearing writes 1 to the fault bit and the overflow bit
+	mem.write64(base+0x200+2*16+8, 0)
+	err = ClearIOMMUFaults(mem, []IOMMUFaultStatus{*status})
+	if err != nil {
+		t.Fatalf("ClearIOMMUFaults failed: %v", err)
+	}
+	var v Uint64
+	_ = mem.ReadPhys(base+0x200+2*16+8, &v)
+	if v != 1<<63 {
+		t.Errorf("Got fault record %x, want the fault bit written", uint64(v))
+	}
+	var fsts Uint32
+	_ = mem.ReadPhys(base+vtdRegFaultStatus, &fsts)
+	if fsts != 1 {
+		t.Errorf("Got fault status write %x, want 1", uint32(fsts))
+	}
+}
@@ -0,0 +1,163 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IRTE source validation types
+const (
+	// IRTESourceValidationNone doesn't verify the requester of interrupt requests
+	IRTESourceValidationNone = 0
+	// IRTESourceValidationRequesterID verifies the requester ID using SID and SQ
+	IRTESourceValidationRequesterID = 1
+	// IRTESourceValidationBus verifies that the requester's bus is in the range encoded in SID
+	IRTESourceValidationBus = 2
+)
+
+// IRTE is an interrupt remapping table entry
+type IRTE struct {
+	Index   int
+	Present bool
+	// FaultProcessingDisable suppresses recording of faults caused by the entry
+	FaultProcessingDisable bool
+	// Posted is set if the entry uses the posted format
+	Posted bool
+	Vector uint8
+
+	// Remappable format only
+	DestinationMode bool
+	RedirectionHint bool
+	TriggerMode     bool
+	DeliveryMode    uint8
+	// Destination is the APIC ID in x2APIC mode, bits 15:8 hold the APIC ID in xAPIC mode
+	Destination uint32
+
+	// Posted format only
+	Urgent                  bool
+	PostedDescriptorAddress uint64
+
+	SourceID         uint16
+	SourceQualifier  uint8
+	SourceValidation uint8
+}
+
+// APICID returns the destination APIC ID of a remappable entry
+func (e IRTE) APICID(x2apic bool) uint32 {
+	if x2apic {
+		return e.Destination
+	}
+	return (e.Destination >> 8) & 0xff
+}
+
+// parseIRTE decodes a 128 bit interrupt remapping table entry
+func parseIRTE(index int, lo, hi uint64) IRTE {
+	ret := IRTE{
+		Index:                  index,
+		Present:                lo&(1<<0) != 0,
+		FaultProcessingDisable: lo&(1<<1) != 0,
+		Posted:                 lo&(1<<15) != 0,
+		Vector:                 uint8(lo >> 16),
+		SourceID:               uint16(hi),        // 79:64
+		SourceQualifier:        uint8(hi>>16) & 3, // 81:80
+		SourceValidation:       uint8(hi>>18) & 3, // 83:82
+	}
+
+	if ret.Posted {
+		ret.Urgent = lo&(1<<14) != 0
+		// 127:96 and 63:38
+		ret.PostedDescriptorAddress = (hi>>32)<<32 | ((lo>>38)<<6)&0xffffffff
+	} else {
+		ret.DestinationMode = lo&(1<<2) != 0
+		ret.RedirectionHint = lo&(1<<3) != 0
+		ret.TriggerMode = lo&(1<<4) != 0
+		ret.DeliveryMode = uint8(lo>>5) & 7
+		ret.Destination = uint32(lo >> 32)
+	}
+
+	return ret
+}
+
+// InterruptRemappingTable is the decoded interrupt remapping table of an IOMMU
+type InterruptRemappingTable struct {
+	Address uint64
+	// X2APIC is set if the extended interrupt mode is enabled
+	X2APIC bool
+	Size   int
+	// Entries holds the present entries
+	Entries []IRTE
+}
+
+// ReadInterruptRemappingTable reads the interrupt remapping table of the IOMMU
+func ReadInterruptRemappingTable(l LowLevelHardwareInterfaces, unit *VTdUnit) (*InterruptRemappingTable, error) {
+	irta := unit.Regs.InterruptRemappingTableAddress
+	ret := InterruptRemappingTable{
+		Address: irta & vtdAddrMask,
+		X2APIC:  irta&(1<<11) != 0,
+		Size:    1 << ((irta & 0xf) + 1),
+	}
+	if ret.Address == 0 {
+		return nil, fmt.Errorf("IOMMU at %x has no interrupt remapping table", unit.Base)
+	}
+
+	buf := make([]byte, ret.Size*16)
+	err := l.ReadPhysBuf(int64(ret.Address), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read interrupt remapping table: %v", err)
+	}
+	for i := 0; i < ret.Size; i++ {
+		irte := parseIRTE(i, binary.LittleEndian.Uint64(buf[i*16:]), binary.LittleEndian.Uint64(buf[i*16+8:]))
+		if irte.Present {
+			ret.Entries = append(ret.Entries, irte)
+		}
+	}
+
+	return &ret, nil
+}
+
+// checkUnitInterruptRemapping returns an error if interrupt remapping isn't enforced by the IOMMU
+func checkUnitInterruptRemapping(unit *VTdUnit) error {
+	if unit.Regs.ExtendedCapabilities&(1<<3) == 0 {
+		return fmt.Errorf("IOMMU at %x doesn't support interrupt remapping", unit.Base)
+	}
+	if unit.Regs.GlobalStatus&(1<<25) == 0 {
+		return fmt.Errorf("IOMMU at %x has interrupt remapping disabled", unit.Base)
+	}
+	// compatibility format interrupts bypass interrupt remapping
+	if unit.Regs.GlobalStatus&(1<<23) != 0 {
+		return fmt.Errorf("IOMMU at %x passes through compatibility format interrupts", unit.Base)
+	}
+	return nil
+}
+
+// CheckInterruptRemapping returns an error if interrupt remapping isn't
+// enabled on every IOMMU covering DMA capable devices or if the firmware opts
+// out of x2APIC mode
+func CheckInterruptRemapping(h LowLevelHardwareInterfaces) error {
+	dmar, err := ReadDMAR(h)
+	if err != nil {
+		return err
+	}
+	if !dmar.InterruptRemapping() {
+		return fmt.Errorf("DMAR doesn't report interrupt remapping support")
+	}
+	if dmar.X2APICOptOut() {
+		return fmt.Errorf("DMAR requests x2APIC opt-out")
+	}
+
+	units, err := ReadVTdUnits(h)
+	if err != nil {
+		return err
+	}
+	for i := range units {
+		if !units[i].CoversDMACapableDevices() {
+			continue
+		}
+		err = checkUnitInterruptRemapping(&units[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestReadInterruptRemappingTable(t *testing.T) {
+	mem := newPhysMemImage()
+
+	// entry 0: remappable, vector 0x31 to APIC ID 2 in xAPIC mode, verified against 00:1f.0
+	mem.write64(0x8000, 0x0200<<32|0x31<<16|1)
+	mem.write64(0x8008, IRTESourceValidationRequesterID<<18|0x00f8)
+	// entry 1: not present
+	mem.write64(0x8010, 0x41<<16)
+	// entry 2: posted, vector 0x51, descriptor at 0x1_1234_5640, not verified
+	mem.write64(0x8020, 0x12345678>>6<<38|0x51<<16|1<<15|1<<14|1)
+	mem.write64(0x8028, 0x1<<32)
+
+	unit := VTdUnit{
+		Base: 0xfed90000,
+		Regs: VTdRegisters{InterruptRemappingTableAddress: 0x8000 | 1},
+	}
+	irt, err := ReadInterruptRemappingTable(mem, &unit)
+	if err != nil {
+		t.Fatalf("ReadInterruptRemappingTable failed: %v", err)
+	}
+	if irt.Size != 4 || irt.X2APIC || len(irt.Entries) != 2 {
+		t.Fatalf("Got %+v", irt)
+	}
+
+	e := irt.Entries[0]
+	if e.Index != 0 || e.Posted || e.Vector != 0x31 || e.APICID(irt.X2APIC) != 2 ||
+		e.SourceValidation != IRTESourceValidationRequesterID || e.SourceID != 0xf8 {
+		t.Errorf("Got remappable entry %+v", e)
+	}
+	e = irt.Entries[1]
+	if e.Index != 2 || !e.Posted || !e.Urgent || e.Vector != 0x51 ||
+		e.PostedDescriptorAddress != 0x112345640 || e.SourceValidation != IRTESourceValidationNone {
+		t.Errorf("Got posted entry %+v", e)
+	}
+}
+
+func TestCheckUnitInterruptRemapping(t *testing.T) {
+	unit := VTdUnit{Regs: VTdRegisters{ExtendedCapabilities: 1 << 3, GlobalStatus: 1 << 25}}
+	if err := checkUnitInterruptRemapping(&unit); err != nil {
+		t.Errorf("checkUnitInterruptRemapping failed: %v", err)
+	}
+	unit.Regs.GlobalStatus |= 1 << 23
+	if err := checkUnitInterruptRemapping(&unit); err == nil {
+		t.Errorf("checkUnitInterruptRemapping accepted compatibility format interrupts")
+	}
+	unit.Regs.GlobalStatus = 0
+	if err := checkUnitInterruptRemapping(&unit); err == nil {
+		t.Errorf("checkUnitInterruptRemapping accepted disabled interrupt remapping")
+	}
+}
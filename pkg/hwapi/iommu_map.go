@@ -0,0 +1,295 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IOMMUMapping describes how a device reaches into host physical memory
+type IOMMUMapping struct {
+	// Unit is the register base of the IOMMU
+	Unit     uint64
+	Bus      int
+	Device   int
+	Function int
+	// PASID is only valid in scalable mode
+	PASID    uint32
+	HasPASID bool
+	// IOVA is the I/O virtual address the device uses to access Address
+	IOVA uint64
+	// Address is the first host physical address of the mapping
+	Address uint64
+	Size    uint64
+	Read    bool
+	Write   bool
+	// PassThrough is set if the DMA of the device isn't translated
+	PassThrough bool
+	// AllDevices is set if DMA remapping is disabled on the unit, thus every
+	// device behind it reaches the range
+	AllDevices bool
+}
+
+func (m IOMMUMapping) String() string {
+	var perm string
+	if m.Read {
+		perm += "r"
+	} else {
+		perm += "-"
+	}
+	if m.Write {
+		perm += "w"
+	} else {
+		perm += "-"
+	}
+
+	switch {
+	case m.AllDevices:
+		return fmt.Sprintf("all devices behind IOMMU %x: [%x-%x] %s, DMA remapping disabled",
+			m.Unit, m.Address, m.Address+m.Size-1, perm)
+	case m.PassThrough && m.HasPASID:
+		return fmt.Sprintf("%02x:%02x.%x PASID %x: [%x-%x] %s, pass-through",
+			m.Bus, m.Device, m.Function, m.PASID, m.Address, m.Address+m.Size-1, perm)
+	case m.PassThrough:
+		return fmt.Sprintf("%02x:%02x.%x: [%x-%x] %s, pass-through",
+			m.Bus, m.Device, m.Function, m.Address, m.Address+m.Size-1, perm)
+	case m.HasPASID:
+		return fmt.Sprintf("%02x:%02x.%x PASID %x: [%x-%x] %s, IOVA %x",
+			m.Bus, m.Device, m.Function, m.PASID, m.Address, m.Address+m.Size-1, perm, m.IOVA)
+	}
+	return fmt.Sprintf("%02x:%02x.%x: [%x-%x] %s, IOVA %x",
+		m.Bus, m.Device, m.Function, m.Address, m.Address+m.Size-1, perm, m.IOVA)
+}
+
+// ioLeaf is a leaf of a page table clipped to the range of interest
+type ioLeaf struct {
+	IOVA    uint64
+	Address uint64
+	Size    uint64
+	Read    bool
+	Write   bool
+}
+
+// ioMappingCollector collects all page table leaves reaching into [first; end].
+// Devices commonly share page tables, so the leaves are cached by table.
+type ioMappingCollector struct {
+	l     LowLevelHardwareInterfaces
+	first uint64
+	end   uint64
+	cache map[uint64][]ioLeaf
+}
+
+func newIOMappingCollector(l LowLevelHardwareInterfaces, first, end uint64) *ioMappingCollector {
+	return &ioMappingCollector{
+		l:     l,
+		first: first,
+		end:   end,
+		cache: map[uint64][]ioLeaf{},
+	}
+}
+
+func (c *ioMappingCollector) leaves(table uint64, levels int, format ioPageTableFormat) ([]ioLeaf, error) {
+	// tables are page aligned, use the lower bits to tell formats and depths apart
+	key := table | uint64(levels) | uint64(format)<<3
+	if ret, ok := c.cache[key]; ok {
+		return ret, nil
+	}
+
+	ret := []ioLeaf{}
+	err := c.walk(table, levels, 0, true, true, format, &ret)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[key] = ret
+
+	return ret, nil
+}
+
+func (c *ioMappingCollector) walk(table uint64, level int, iova uint64, read, write bool,
+	format ioPageTableFormat, out *[]ioLeaf) error {
+	buf := make([]byte, 4096)
+	err := c.l.ReadPhysBuf(int64(table), buf)
+	if err != nil {
+		return err
+	}
+
+	shift := uint(12 + 9*(level-1))
+	for i := 0; i < 512; i++ {
+		ent := binary.LittleEndian.Uint64(buf[i*8:])
+
+		pte := decodeIOPageTableEntry(format, ent, level)
+		if !pte.Present {
+			continue
+		}
+		r := read && pte.Read
+		w := write && pte.Write
+
+		entIOVA := iova | uint64(i)<<shift
+		if pte.Next != 0 {
+			err = c.walk(pte.Address, pte.Next, entIOVA, r, w, format, out)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		size, addr := pte.PageSize, pte.Address
+		if size > uint64(1)<<shift {
+			// only report the first of replicated entries
+			if entIOVA&(size-1) != 0 {
+				continue
+			}
+		}
+		if addr > c.end || addr+size-1 < c.first {
+			continue
+		}
+		lo, hi := addr, addr+size-1
+		if lo < c.first {
+			lo = c.first
+		}
+		if hi > c.end {
+			hi = c.end
+		}
+		*out = append(*out, ioLeaf{
+			IOVA:    entIOVA + (lo - addr),
+			Address: lo,
+			Size:    hi - lo + 1,
+			Read:    r,
+			Write:   w,
+		})
+	}
+
+	return nil
+}
+
+func (c *ioMappingCollector) appendLeaves(ret []IOMMUMapping, tmpl IOMMUMapping, table uint64, levels int,
+	format ioPageTableFormat) ([]IOMMUMapping, error) {
+	leaves, err := c.leaves(table, levels, format)
+	if err != nil {
+		return nil, err
+	}
+	for _, leaf := range leaves {
+		m := tmpl
+		m.IOVA = leaf.IOVA
+		m.Address = leaf.Address
+		m.Size = leaf.Size
+		m.Read = leaf.Read
+		m.Write = leaf.Write
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+func (c *ioMappingCollector) passThrough(tmpl IOMMUMapping) IOMMUMapping {
+	tmpl.IOVA = c.first
+	tmpl.Address = c.first
+	tmpl.Size = c.end - c.first + 1
+	tmpl.Read = true
+	tmpl.Write = true
+	tmpl.PassThrough = true
+	return tmpl
+}
+
+// FindDMAMappings returns the mappings of all devices behind the IOMMU that
+// reach into the physical range [first; end]. Nested translations are
+// reported by their second-level mappings, as the first level is controlled by the guest.
+func FindDMAMappings(l LowLevelHardwareInterfaces, unit *VTdUnit, first, end uint64) ([]IOMMUMapping, error) {
+	ret := []IOMMUMapping{}
+
+	if first > end {
+		return nil, fmt.Errorf("invalid range")
+	}
+	c := newIOMappingCollector(l, first, end)
+
+	// translation enable status
+	if unit.Regs.GlobalStatus&(1<<31) == 0 {
+		return append(ret, c.passThrough(IOMMUMapping{Unit: unit.Base, AllDevices: true})), nil
+	}
+
+	rootTblAddr := unit.Regs.RootTableAddress & vtdAddrMask
+	var err error
+	switch (unit.Regs.RootTableAddress >> 10) & 3 {
+	case 0:
+		err = iterateLegacyContexts(l, rootTblAddr, func(bus, devfn int, ctx [2]uint64) error {
+			tmpl := IOMMUMapping{Unit: unit.Base, Bus: bus, Device: devfn >> 3, Function: devfn & 7}
+			// translation type
+			switch (ctx[0] >> 2) & 3 {
+			case 0, 1:
+				levels, err := vtdContextLevels(unit.Regs.Capabilities, ctx[1]&7)
+				if err != nil {
+					return fmt.Errorf("%02x:%02x.%x: %v", bus, devfn>>3, devfn&7, err)
+				}
+				ret, err = c.appendLeaves(ret, tmpl, ctx[0]&vtdAddrMask, levels, ioPageTableSecondLevel)
+				return err
+			case 2:
+				ret = append(ret, c.passThrough(tmpl))
+			}
+			return nil
+		})
+	case 1:
+		err = iterateScalablePASIDEntries(l, rootTblAddr, func(bus, devfn int, pasid uint32, pe [3]uint64) error {
+			tmpl := IOMMUMapping{Unit: unit.Base, Bus: bus, Device: devfn >> 3, Function: devfn & 7,
+				PASID: pasid, HasPASID: true}
+
+			switch (pe[0] >> 6) & 7 {
+			case vtdPGTTFirstLevel:
+				levels := 4
+				if (pe[2]>>2)&3 == 1 {
+					levels = 5
+				}
+				ret, err = c.appendLeaves(ret, tmpl, pe[2]&vtdAddrMask, levels, ioPageTableFirstLevel)
+				return err
+			case vtdPGTTSecondLevel, vtdPGTTNested:
+				levels, err := vtdAWLevels((pe[0] >> 2) & 7)
+				if err != nil {
+					return fmt.Errorf("%02x:%02x.%x PASID %x: %v", bus, devfn>>3, devfn&7, pasid, err)
+				}
+				ret, err = c.appendLeaves(ret, tmpl, pe[0]&vtdAddrMask, levels, ioPageTableSecondLevel)
+				return err
+			case vtdPGTTPassThrough:
+				ret = append(ret, c.passThrough(tmpl))
+			}
+			return nil
+		})
+	default:
+		return nil, fmt.Errorf("unsupported IOMMU Translation Table Mode")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// FindAllDMAMappings returns the mappings of all devices behind any VT-d or
+// AMD IOMMU that reach into the physical range [first; end]
+func FindAllDMAMappings(l LowLevelHardwareInterfaces, first, end uint64) ([]IOMMUMapping, error) {
+	var ret []IOMMUMapping
+
+	units, err := ReadVTdUnits(l)
+	if err != nil {
+		amdUnits, amdErr := ReadAMDVIUnits(l)
+		if amdErr != nil || len(amdUnits) == 0 {
+			return nil, err
+		}
+		for i := range amdUnits {
+			mappings, err := FindAMDVIDMAMappings(l, &amdUnits[i], first, end)
+			if err != nil {
+				return nil, fmt.Errorf("IOMMU at %x: %v", amdUnits[i].Base, err)
+			}
+			ret = append(ret, mappings...)
+		}
+		return ret, nil
+	}
+	for i := range units {
+		if !units[i].CoversDMACapableDevices() {
+			continue
+		}
+		mappings, err := FindDMAMappings(l, &units[i], first, end)
+		if err != nil {
+			return nil, fmt.Errorf("IOMMU at %x: %v", units[i].Base, err)
+		}
+		ret = append(ret, mappings...)
+	}
+
+	return ret, nil
+}
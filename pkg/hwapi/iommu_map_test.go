@@ -0,0 +1,77 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestFindDMAMappingsLegacy(t *testing.T) {
+	mem := newPhysMemImage()
+
+	// bus 0 and bus 2 have context tables
+	mem.write64(0x1000, 0x2000|1)
+	mem.write64(0x1000+2*16, 0x3000|1)
+
+	// 00:02.0: 3-level table, 2 MiB page 0x0 -> 0x80000000 read only
+	mem.write64(0x2000+0x10*16, 0x10000|1)
+	mem.write64(0x2000+0x10*16+8, 1)
+	mem.write64(0x10000, 0x11000|3)
+	mem.write64(0x11000, 0x80000000|1<<7|1)
+
+	// 00:1f.3: shares the table with 00:02.0
+	mem.write64(0x2000+0xfb*16, 0x10000|1)
+	mem.write64(0x2000+0xfb*16+8, 1)
+
+	// 02:00.0: pass-through
+	mem.write64(0x3000, 2<<2|1)
+	mem.write64(0x3000+8, 1)
+
+	// 02:00.1: 4 KiB page outside of the range
+	mem.write64(0x3000+16, 0x20000|1)
+	mem.write64(0x3000+16+8, 1)
+	mem.write64(0x20000, 0x21000|3)
+	mem.write64(0x21000, 0x22000|3)
+	mem.write64(0x22000, 0x1000|3)
+
+	unit := VTdUnit{
+		Base: 0xfed90000,
+		Regs: VTdRegisters{GlobalStatus: 1 << 31, RootTableAddress: 0x1000, Capabilities: 2 << 8},
+	}
+	got, err := FindDMAMappings(mem, &unit, 0x80100000, 0x802fffff)
+	if err != nil {
+		t.Fatalf("FindDMAMappings failed: %v", err)
+	}
+
+	want := []IOMMUMapping{
+		{Unit: 0xfed90000, Bus: 0, Device: 2, Function: 0,
+			IOVA: 0x100000, Address: 0x80100000, Size: 0x100000, Read: true},
+		{Unit: 0xfed90000, Bus: 0, Device: 0x1f, Function: 3,
+			IOVA: 0x100000, Address: 0x80100000, Size: 0x100000, Read: true},
+		{Unit: 0xfed90000, Bus: 2, Device: 0, Function: 0,
+			IOVA: 0x80100000, Address: 0x80100000, Size: 0x200000, Read: true, Write: true, PassThrough: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Mapping %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// 3-level tables not in SAGAW
+	unit.Regs.Capabilities = 4 << 8
+	if _, err := FindDMAMappings(mem, &unit, 0x80100000, 0x802fffff); err == nil {
+		t.Errorf("Address width not supported by the IOMMU wasn't detected")
+	}
+}
+
+func TestFindDMAMappingsDisabled(t *testing.T) {
+	unit := VTdUnit{Base: 0xfed91000}
+	got, err := FindDMAMappings(newPhysMemImage(), &unit, 0x1000, 0x1fff)
+	if err != nil {
+		t.Fatalf("FindDMAMappings failed: %v", err)
+	}
+	if len(got) != 1 || !got[0].AllDevices || got[0].Size != 0x1000 {
+		t.Errorf("Got %v, want a single mapping covering all devices", got)
+	}
+}
@@ -0,0 +1,295 @@
+package hwapi
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Protected memory region register offsets
+	vtdRegPMEN     = 0x64
+	vtdRegPLMBASE  = 0x68
+	vtdRegPLMLIMIT = 0x6c
+	vtdRegPHMBASE  = 0x70
+	vtdRegPHMLIMIT = 0x78
+
+	// vtdPMENEnable is the EPM bit of the PMEN register
+	vtdPMENEnable = 1 << 31
+	// vtdPMENStatus is the PRS bit of the PMEN register
+	vtdPMENStatus = 1 << 0
+
+	// vtdPMRMinAlignment is the smallest alignment of the PMR registers
+	vtdPMRMinAlignment = 1 << 21
+	// vtdPMRStatusTimeout is how long to wait for the PMEN status to change
+	vtdPMRStatusTimeout = 100 * time.Millisecond
+	// vtdPMRStatusInterval is the delay between PMEN reads
+	vtdPMRStatusInterval = 10 * time.Microsecond
+)
+
+// PMRConfig holds the protected memory region configuration of an IOMMU
+type PMRConfig struct {
+	// Unit is the register base of the IOMMU
+	Unit uint64
+	// LowSupported and HighSupported are set if the IOMMU implements the regions
+	LowSupported  bool
+	HighSupported bool
+	// Enabled is the EPM bit, Protected the PRS status bit of the PMEN register
+	Enabled   bool
+	Protected bool
+	// LowBase, LowLimit, HighBase and HighLimit are inclusive. If the alignment
+	// wasn't probed, the limits assume the smallest alignment.
+	LowBase   uint64
+	LowLimit  uint64
+	HighBase  uint64
+	HighLimit uint64
+}
+
+// LowRangeActive returns true if the low memory region protects memory
+func (p *PMRConfig) LowRangeActive() bool {
+	return p.LowSupported && p.Protected && p.LowBase < p.LowLimit
+}
+
+// HighRangeActive returns true if the high memory region protects memory
+func (p *PMRConfig) HighRangeActive() bool {
+	return p.HighSupported && p.Protected && p.HighBase < p.HighLimit
+}
+
+// Covers returns true if [first; end] is within an active protected memory region
+func (p *PMRConfig) Covers(first, end uint64) bool {
+	if p.LowRangeActive() && p.LowBase <= first && end <= p.LowLimit {
+		return true
+	}
+	return p.HighRangeActive() && p.HighBase <= first && end <= p.HighLimit
+}
+
+// PMRSettings are the regions to program. Bases and limits are inclusive
+// and must be aligned to the alignment reported by QueryPMRAlignment.
+// A region whose limit is below its base is left disabled.
+type PMRSettings struct {
+	LowBase   uint64
+	LowLimit  uint64
+	HighBase  uint64
+	HighLimit uint64
+}
+
+// readPMRConfig decodes the protected memory region registers of the unit
+func readPMRConfig(unit *VTdUnit) PMRConfig {
+	regs := &unit.Regs
+	return PMRConfig{
+		Unit:          unit.Base,
+		LowSupported:  regs.Capabilities&(1<<5) != 0,
+		HighSupported: regs.Capabilities&(1<<6) != 0,
+		Enabled:       regs.ProtectedMemoryEnable&vtdPMENEnable != 0,
+		Protected:     regs.ProtectedMemoryEnable&vtdPMENStatus != 0,
+		LowBase:       uint64(regs.ProtectedLowMemoryBase) &^ (vtdPMRMinAlignment - 1),
+		LowLimit:      uint64(regs.ProtectedLowMemoryLimit) | (vtdPMRMinAlignment - 1),
+		HighBase:      regs.ProtectedHighMemoryBase &^ (vtdPMRMinAlignment - 1),
+		HighLimit:     regs.ProtectedHighMemoryLimit | (vtdPMRMinAlignment - 1),
+	}
+}
+
+// ReadPMRs returns the protected memory region configuration of every IOMMU
+func ReadPMRs(h LowLevelHardwareInterfaces) ([]PMRConfig, error) {
+	var ret []PMRConfig
+
+	units, err := ReadVTdUnits(h)
+	if err != nil {
+		return nil, err
+	}
+	for i := range units {
+		ret = append(ret, readPMRConfig(&units[i]))
+	}
+
+	return ret, nil
+}
+
+func writeVTdReg32(h LowLevelHardwareInterfaces, unit *VTdUnit, off uint64, val uint32) error {
+	v := Uint32(val)
+	return h.WritePhys(int64(unit.Base+off), &v)
+}
+
+func writeVTdReg64(h LowLevelHardwareInterfaces, unit *VTdUnit, off uint64, val uint64) error {
+	v := Uint64(val)
+	return h.WritePhys(int64(unit.Base+off), &v)
+}
+
+// QueryPMRAlignment returns the alignment of the low and high protected memory
+// regions by writing all ones to the base registers. Returns 0 for regions
+// not supported. The protected memory regions must be disabled.
+func QueryPMRAlignment(h LowLevelHardwareInterfaces, unit *VTdUnit) (low uint64, high uint64, err error) {
+	cfg := readPMRConfig(unit)
+	if cfg.Enabled || cfg.Protected {
+		return 0, 0, fmt.Errorf("IOMMU at %x: protected memory regions are enabled", unit.Base)
+	}
+
+	if cfg.LowSupported {
+		err = writeVTdReg32(h, unit, vtdRegPLMBASE, 0xffffffff)
+		if err != nil {
+			return 0, 0, err
+		}
+		var v Uint32
+		err = h.ReadPhys(int64(unit.Base+vtdRegPLMBASE), &v)
+		if err != nil {
+			return 0, 0, err
+		}
+		if v == 0 {
+			return 0, 0, fmt.Errorf("IOMMU at %x: PLMBASE is read-only", unit.Base)
+		}
+		low = uint64(v) & -uint64(v)
+		err = writeVTdReg32(h, unit, vtdRegPLMBASE, unit.Regs.ProtectedLowMemoryBase)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if cfg.HighSupported {
+		err = writeVTdReg64(h, unit, vtdRegPHMBASE, ^uint64(0))
+		if err != nil {
+			return 0, 0, err
+		}
+		var v Uint64
+		err = h.ReadPhys(int64(unit.Base+vtdRegPHMBASE), &v)
+		if err != nil {
+			return 0, 0, err
+		}
+		if v == 0 {
+			return 0, 0, fmt.Errorf("IOMMU at %x: PHMBASE is read-only", unit.Base)
+		}
+		high = uint64(v) & -uint64(v)
+		err = writeVTdReg64(h, unit, vtdRegPHMBASE, unit.Regs.ProtectedHighMemoryBase)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return low, high, nil
+}
+
+// waitPMRStatus polls the PRS bit until it matches protected or the timeout expires
+func waitPMRStatus(h LowLevelHardwareInterfaces, unit *VTdUnit, protected bool) error {
+	deadline := time.Now().Add(vtdPMRStatusTimeout)
+	for {
+		var v Uint32
+		err := h.ReadPhys(int64(unit.Base+vtdRegPMEN), &v)
+		if err != nil {
+			return err
+		}
+		if (v&vtdPMENStatus != 0) == protected {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(vtdPMRStatusInterval)
+	}
+	return fmt.Errorf("IOMMU at %x: protected region status didn't change", unit.Base)
+}
+
+// ProgramPMR disables the protected memory regions of the IOMMU, programs the
+// base and limit registers and enables them again if enable is set. On error
+// the regions may be left disabled.
+func ProgramPMR(h LowLevelHardwareInterfaces, unit *VTdUnit, s PMRSettings, enable bool) error {
+	cfg := readPMRConfig(unit)
+	lowUsed := s.LowBase <= s.LowLimit
+	highUsed := s.HighBase <= s.HighLimit
+
+	if lowUsed && !cfg.LowSupported {
+		return fmt.Errorf("IOMMU at %x doesn't support the low protected memory region", unit.Base)
+	}
+	if highUsed && !cfg.HighSupported {
+		return fmt.Errorf("IOMMU at %x doesn't support the high protected memory region", unit.Base)
+	}
+	if lowUsed && s.LowLimit > 0xffffffff {
+		return fmt.Errorf("low protected memory region must be below 4GiB")
+	}
+
+	if cfg.Enabled || cfg.Protected {
+		err := writeVTdReg32(h, unit, vtdRegPMEN, 0)
+		if err != nil {
+			return err
+		}
+		err = waitPMRStatus(h, unit, false)
+		if err != nil {
+			return err
+		}
+		unit.Regs.ProtectedMemoryEnable = 0
+	}
+
+	lowAlign, highAlign, err := QueryPMRAlignment(h, unit)
+	if err != nil {
+		return err
+	}
+	if lowUsed && (s.LowBase&(lowAlign-1) != 0 || (s.LowLimit+1)&(lowAlign-1) != 0) {
+		return fmt.Errorf("low protected memory region isn't aligned to %#x", lowAlign)
+	}
+	if highUsed && (s.HighBase&(highAlign-1) != 0 || (s.HighLimit+1)&(highAlign-1) != 0) {
+		return fmt.Errorf("high protected memory region isn't aligned to %#x", highAlign)
+	}
+
+	// unused regions get a limit below the base, which protects nothing
+	var lowBase, lowLimit, highBase, highLimit uint64
+	if lowUsed {
+		lowBase, lowLimit = s.LowBase, s.LowLimit&^(lowAlign-1)
+	} else if cfg.LowSupported {
+		lowBase, lowLimit = lowAlign, 0
+	}
+	if highUsed {
+		highBase, highLimit = s.HighBase, s.HighLimit&^(highAlign-1)
+	} else if cfg.HighSupported {
+		highBase, highLimit = highAlign, 0
+	}
+
+	if cfg.LowSupported {
+		err = writeVTdReg32(h, unit, vtdRegPLMBASE, uint32(lowBase))
+		if err == nil {
+			err = writeVTdReg32(h, unit, vtdRegPLMLIMIT, uint32(lowLimit))
+		}
+		if err != nil {
+			return err
+		}
+		unit.Regs.ProtectedLowMemoryBase = uint32(lowBase)
+		unit.Regs.ProtectedLowMemoryLimit = uint32(lowLimit)
+	}
+	if cfg.HighSupported {
+		err = writeVTdReg64(h, unit, vtdRegPHMBASE, highBase)
+		if err == nil {
+			err = writeVTdReg64(h, unit, vtdRegPHMLIMIT, highLimit)
+		}
+		if err != nil {
+			return err
+		}
+		unit.Regs.ProtectedHighMemoryBase = highBase
+		unit.Regs.ProtectedHighMemoryLimit = highLimit
+	}
+
+	if !enable {
+		return nil
+	}
+	err = writeVTdReg32(h, unit, vtdRegPMEN, vtdPMENEnable)
+	if err != nil {
+		return err
+	}
+	err = waitPMRStatus(h, unit, true)
+	if err != nil {
+		return err
+	}
+	unit.Regs.ProtectedMemoryEnable = vtdPMENEnable | vtdPMENStatus
+
+	return nil
+}
+
+// ProgramPMRs programs the protected memory regions of every IOMMU
+func ProgramPMRs(h LowLevelHardwareInterfaces, s PMRSettings, enable bool) error {
+	units, err := ReadVTdUnits(h)
+	if err != nil {
+		return err
+	}
+	for i := range units {
+		err = ProgramPMR(h, &units[i], s, enable)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
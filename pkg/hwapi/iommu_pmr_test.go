@@ -0,0 +1,69 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+// pmrTestMem emulates the PMR registers of an IOMMU with 2 MiB alignment
+type pmrTestMem struct {
+	*physMemImage
+	base uint64
+}
+
+func (p *pmrTestMem) WritePhys(addr int64, data UintN) error {
+	switch uint64(addr) - p.base {
+	case vtdRegPLMBASE, vtdRegPLMLIMIT:
+		v := *data.(*Uint32) &^ (vtdPMRMinAlignment - 1)
+		return p.physMemImage.WritePhys(addr, &v)
+	case vtdRegPHMBASE, vtdRegPHMLIMIT:
+		v := *data.(*Uint64) &^ (vtdPMRMinAlignment - 1)
+		return p.physMemImage.WritePhys(addr, &v)
+	case vtdRegPMEN:
+		// the status follows the enable bit immediately
+		v := *data.(*Uint32) & vtdPMENEnable
+		if v != 0 {
+			v |= vtdPMENStatus
+		}
+		return p.physMemImage.WritePhys(addr, &v)
+	}
+	return p.physMemImage.WritePhys(addr, data)
+}
+
+func TestProgramPMR(t *testing.T) {
+	mem := &pmrTestMem{physMemImage: newPhysMemImage(), base: 0xfed90000}
+	unit := VTdUnit{
+		Base: 0xfed90000,
+		Regs: VTdRegisters{Capabilities: 1<<5 | 1<<6},
+	}
+
+	low, high, err := QueryPMRAlignment(mem, &unit)
+	if err != nil {
+		t.Fatalf("QueryPMRAlignment failed: %v", err)
+	}
+	if low != vtdPMRMinAlignment || high != vtdPMRMinAlignment {
+		t.Errorf("Got alignment %x/%x, want 2 MiB", low, high)
+	}
+
+	err = ProgramPMR(mem, &unit, PMRSettings{LowBase: 0, LowLimit: 0x7fffffff, HighBase: 1, HighLimit: 0}, true)
+	if err != nil {
+		t.Fatalf("ProgramPMR failed: %v", err)
+	}
+	var plmlimit Uint32
+	_ = mem.ReadPhys(int64(unit.Base+vtdRegPLMLIMIT), &plmlimit)
+	if plmlimit != 0x7fe00000 {
+		t.Errorf("Got PLMLIMIT %x, want 7fe00000", uint32(plmlimit))
+	}
+
+	cfg := readPMRConfig(&unit)
+	if !cfg.Enabled || !cfg.Protected || !cfg.LowRangeActive() || cfg.HighRangeActive() {
+		t.Errorf("Got PMR config %+v", cfg)
+	}
+	if !cfg.Covers(0x1000, 0x7fffffff) || cfg.Covers(0x1000, 0x80000000) || cfg.Covers(1<<32, 1<<33) {
+		t.Errorf("PMR config %+v covers the wrong ranges", cfg)
+	}
+
+	err = ProgramPMR(mem, &unit, PMRSettings{LowBase: 0x1000, LowLimit: 0x7fffffff, HighBase: 1}, true)
+	if err == nil {
+		t.Errorf("ProgramPMR accepted an unaligned base")
+	}
+}
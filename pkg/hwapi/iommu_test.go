@@ -0,0 +1,231 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// physMemImage is a sparse in-memory physical memory image for testing
+// page table walkers without /dev/mem
+type physMemImage struct {
+	HwAPI
+	mem map[int64]byte
+	// acpi holds the ACPI tables returned by GetACPITable
+	acpi map[string][]byte
+}
+
+func newPhysMemImage() *physMemImage {
+	return &physMemImage{mem: map[int64]byte{}, acpi: map[string][]byte{}}
+}
+
+func (p *physMemImage) write64(addr uint64, v uint64) {
+	for i := int64(0); i < 8; i++ {
+		p.mem[int64(addr)+i] = byte(v >> (8 * i))
+	}
+}
+
+func (p *physMemImage) writeBuf(addr uint64, buf []byte) {
+	for i, b := range buf {
+		p.mem[int64(addr)+int64(i)] = b
+	}
+}
+
+func (p *physMemImage) ReadPhysBuf(addr int64, buf []byte) error {
+	for i := range buf {
+		buf[i] = p.mem[addr+int64(i)]
+	}
+	return nil
+}
+
+func (p *physMemImage) ReadPhys(addr int64, data UintN) error {
+	buf := make([]byte, data.Size())
+	_ = p.ReadPhysBuf(addr, buf)
+	switch v := data.(type) {
+	case *Uint8:
+		*v = Uint8(buf[0])
+	case *Uint16:
+		*v = Uint16(binary.LittleEndian.Uint16(buf))
+	case *Uint32:
+		*v = Uint32(binary.LittleEndian.Uint32(buf))
+	case *Uint64:
+		*v = Uint64(binary.LittleEndian.Uint64(buf))
+	default:
+		return fmt.Errorf("unsupported type %T", data)
+	}
+	return nil
+}
+
+func (p *physMemImage) WritePhys(addr int64, data UintN) error {
+	var v uint64
+	switch d := data.(type) {
+	case *Uint8:
+		v = uint64(*d)
+	case *Uint16:
+		v = uint64(*d)
+	case *Uint32:
+		v = uint64(*d)
+	case *Uint64:
+		v = uint64(*d)
+	default:
+		return fmt.Errorf("unsupported type %T", data)
+	}
+	for i := int64(0); i < data.Size(); i++ {
+		p.mem[addr+i] = byte(v >> (8 * i))
+	}
+	return nil
+}
+
+func (p *physMemImage) GetACPITable(n string, opts ...ACPITableOption) ([]byte, error) {
+	buf, ok := p.acpi[n]
+	if !ok {
+		return nil, fmt.Errorf("ACPI table not found")
+	}
+	return buf, nil
+}
+
+func (p *physMemImage) EnumerateACPITables() ([]ACPITableInfo, error) {
+	var ret []ACPITableInfo
+	for n, buf := range p.acpi {
+		ret = append(ret, ACPITableInfo{Name: n, Signature: n[:4], Length: uint32(len(buf))})
+	}
+	return ret, nil
+}
+
+func (p *physMemImage) LookupIOAddress(addr uint64, regs VTdRegisters) ([]IOTranslation, error) {
+	return lookupIOAddress(p, addr, regs)
+}
+
+func TestLookupIOScalable(t *testing.T) {
+	mem := newPhysMemImage()
+
+	// root table -> context table -> PASID directory -> PASID table
+	mem.write64(0x1000, 0x2000|1)
+	mem.write64(0x2000, 0x3000|1)
+	mem.write64(0x3000, 0x4000|1)
+
+	// PASID 0: second-level, 4-level table, 2 MiB page 0x200000 -> 0x40000000
+	mem.write64(0x4000, 0x10000|vtdPGTTSecondLevel<<6|2<<2|1)
+	mem.write64(0x10000, 0x11000|3)
+	mem.write64(0x11000, 0x12000|3)
+	mem.write64(0x12000+1*8, 0x40000000|1<<7|1)
+
+	// PASID 1: first-level, 4 KiB page 0x201000 -> 0x50001000, read only
+	mem.write64(0x4040, 0x4040|vtdPGTTFirstLevel<<6|1)
+	mem.write64(0x4040+16, 0x20000)
+	mem.write64(0x20000, 0x21000|3)
+	mem.write64(0x21000, 0x22000|3)
+	mem.write64(0x22000+1*8, 0x23000|3)
+	mem.write64(0x23000+1*8, 0x50001000|1)
+
+	// PASID 2: pass-through
+	mem.write64(0x4080, vtdPGTTPassThrough<<6|1)
+
+	regs := VTdRegisters{RootTableAddress: 0x1000 | 1<<10}
+	got, err := mem.LookupIOAddress(0x201234, regs)
+	if err != nil {
+		t.Fatalf("LookupIOAddress failed: %v", err)
+	}
+	want := []IOTranslation{
+		{PASID: 0, HasPASID: true, Address: 0x40001234, PageSize: 1 << 21, Read: true},
+		{PASID: 1, HasPASID: true, Address: 0x50001234, PageSize: 1 << 12, Read: true},
+		{PASID: 2, HasPASID: true, Address: 0x201234, PageSize: 1 << 12, Read: true, Write: true, PassThrough: true},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Got %+v, want %+v", got, want)
+	}
+}
+
+func TestLookupIOScalableNested(t *testing.T) {
+	mem := newPhysMemImage()
+
+	mem.write64(0x1000, 0x2000|1)
+	mem.write64(0x2000, 0x3000|1)
+	mem.write64(0x3000, 0x4000|1)
+
+	// nested: second-level is an identity map of the first 1 GiB shifted by 1 GiB
+	mem.write64(0x4000, 0x10000|vtdPGTTNested<<6|1<<2|1)
+	mem.write64(0x4000+16, 0x20000)
+	mem.write64(0x10000, 0x40000000|1<<7|3)
+
+	// first-level tables live at guest physical addresses, which are shifted by 1 GiB
+	mem.write64(0x40020000, 0x21000|3)
+	mem.write64(0x40021000, 0x22000|3)
+	mem.write64(0x40022000, 0x23000|3)
+	mem.write64(0x40023000+5*8, 0x7000|1)
+
+	regs := VTdRegisters{RootTableAddress: 0x1000 | 1<<10}
+	got, err := mem.LookupIOAddress(0x5010, regs)
+	if err != nil {
+		t.Fatalf("LookupIOAddress failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Address != 0x40007010 || got[0].PageSize != 4096 || got[0].Write {
+		t.Errorf("Got %+v, want a read-only 4 KiB page at 40007010", got)
+	}
+}
+
+func TestLookupIOLegacy(t *testing.T) {
+	mem := newPhysMemImage()
+
+	// root table -> context table of bus 0 and bus 1
+	mem.write64(0x1000, 0x2000|1)
+	mem.write64(0x1000+16, 0x3000|1)
+
+	// 00:00.0: 3-level table, 1 GiB page 0x0 -> 0x80000000
+	mem.write64(0x2000, 0x10000|1)
+	mem.write64(0x2000+8, 1)
+	mem.write64(0x10000, 0x80000000|1<<7|3)
+
+	// 00:01.0: 4-level table, 2 MiB page 0x0 -> 0x40000000 write only
+	mem.write64(0x2000+8*16, 0x20000|1)
+	mem.write64(0x2000+8*16+8, 2)
+	mem.write64(0x20000, 0x21000|3)
+	mem.write64(0x21000, 0x22000|3)
+	mem.write64(0x22000, 0x40000000|1<<7|2)
+
+	// 00:02.0: 4-level table, 4 KiB page 0x12000 -> 0x7777000
+	mem.write64(0x2000+16*16, 0x30000|1)
+	mem.write64(0x2000+16*16+8, 2)
+	mem.write64(0x30000, 0x31000|3)
+	mem.write64(0x31000, 0x32000|3)
+	mem.write64(0x32000, 0x33000|3)
+	mem.write64(0x33000+0x12*8, 0x7777000|3)
+
+	// 01:00.0: pass-through
+	mem.write64(0x3000, 2<<2|1)
+
+	// 3-level and 4-level tables, 48 bit guest address width
+	regs := VTdRegisters{
+		Capabilities:     (47 << 16) | (6 << 8),
+		RootTableAddress: 0x1000,
+	}
+	got, err := mem.LookupIOAddress(0x12345, regs)
+	if err != nil {
+		t.Fatalf("LookupIOAddress failed: %v", err)
+	}
+	want := []IOTranslation{
+		{Bus: 0, Device: 0, Function: 0, Address: 0x80012345, PageSize: 1 << 30, Read: true, Write: true},
+		{Bus: 0, Device: 1, Function: 0, Address: 0x40012345, PageSize: 1 << 21, Write: true},
+		{Bus: 0, Device: 2, Function: 0, Address: 0x7777345, PageSize: 1 << 12, Read: true, Write: true},
+		{Bus: 1, Device: 0, Function: 0, Address: 0x12345, PageSize: 1 << 12, Read: true, Write: true, PassThrough: true},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Got %+v, want %+v", got, want)
+	}
+
+	// beyond the address width of the 3-level table
+	got, err = mem.LookupIOAddress(1<<40|0x12345, regs)
+	if err != nil {
+		t.Fatalf("LookupIOAddress failed: %v", err)
+	}
+	if len(got) != 1 || !got[0].PassThrough {
+		t.Errorf("Got %+v, want only the pass-through device", got)
+	}
+
+	// 4-level tables aren't supported
+	regs.Capabilities = (47 << 16) | (2 << 8)
+	_, err = mem.LookupIOAddress(0x12345, regs)
+	if err == nil {
+		t.Errorf("LookupIOAddress accepted an unsupported address width")
+	}
+}
@@ -0,0 +1,376 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// IVRS IVDB types
+const (
+	IVRSTypeIVHD10 = 0x10
+	IVRSTypeIVHD11 = 0x11
+	IVRSTypeIVHD40 = 0x40
+	IVRSTypeIVMD20 = 0x20
+	IVRSTypeIVMD21 = 0x21
+	IVRSTypeIVMD22 = 0x22
+)
+
+// IVHD device entry types
+const (
+	IVHDEntryPad           = 0x00
+	IVHDEntryAll           = 0x01
+	IVHDEntrySelect        = 0x02
+	IVHDEntryStartRange    = 0x03
+	IVHDEntryEndRange      = 0x04
+	IVHDEntryAliasSelect   = 0x42
+	IVHDEntryAliasRange    = 0x43
+	IVHDEntryExtSelect     = 0x46
+	IVHDEntryExtRange      = 0x47
+	IVHDEntrySpecialDevice = 0x48
+	IVHDEntryACPIHID       = 0xf0
+)
+
+// IVMD flags
+const (
+	IVMDFlagUnity          = 1 << 0
+	IVMDFlagRead           = 1 << 1
+	IVMDFlagWrite          = 1 << 2
+	IVMDFlagExclusionRange = 1 << 3
+)
+
+const (
+	// ivrsInfoDMARemap is set if the platform requests DMA remapping to be enabled
+	ivrsInfoDMARemap = 1 << 1
+	// ivdbHdrSize is the size of the type, flags and length fields
+	ivdbHdrSize = 4
+)
+
+type ivrsHeader struct {
+	ACPIHeader
+	IVInfo   uint32
+	Reserved uint64
+}
+
+type ivhdHeader10 struct {
+	DeviceID         uint16
+	CapabilityOffset uint16
+	BaseAddress      uint64
+	Segment          uint16
+	IOMMUInfo        uint16
+	IOMMUFeatureInfo uint32
+}
+
+type ivhdHeader40 struct {
+	DeviceID          uint16
+	CapabilityOffset  uint16
+	BaseAddress       uint64
+	Segment           uint16
+	IOMMUInfo         uint16
+	IOMMUAttributes   uint32
+	ExtendedFeatures  uint64
+	ExtendedFeatures2 uint64
+}
+
+// IVHDDeviceEntry is a device entry of an IVHD. Range entries are folded into
+// a single entry covering DeviceID to LastDeviceID.
+type IVHDDeviceEntry struct {
+	Type         uint8
+	DeviceID     uint16
+	LastDeviceID uint16
+	DTESetting   uint8
+	// AliasID is the requester ID used by alias entries
+	AliasID uint16
+	// Handle and Variety are set for special devices (IOAPIC = 1, HPET = 2)
+	Handle  uint8
+	Variety uint8
+	// HID, CID and UID are set for ACPI devices
+	HID string
+	CID string
+	UID string
+}
+
+// IVRSIVHD is an I/O virtualization hardware definition
+type IVRSIVHD struct {
+	Type     uint8
+	Flags    uint8
+	DeviceID uint16
+	// CapabilityOffset is the offset of the IOMMU capability block in PCI config space
+	CapabilityOffset uint16
+	BaseAddress      uint64
+	Segment          uint16
+	IOMMUInfo        uint16
+	// FeatureInfo is the IOMMU feature info for type 10h and the IOMMU attributes otherwise
+	FeatureInfo uint32
+	// ExtendedFeatures is the image of the extended feature register, not present for type 10h
+	ExtendedFeatures  uint64
+	ExtendedFeatures2 uint64
+	Devices           []IVHDDeviceEntry
+}
+
+// DeviceIDs returns the requester IDs of all devices behind the IOMMU. Special
+// devices are interrupt sources only and aren't included.
+func (d IVRSIVHD) DeviceIDs() []uint16 {
+	var ret []uint16
+	seen := map[uint16]bool{}
+
+	add := func(id uint16) {
+		if !seen[id] {
+			seen[id] = true
+			ret = append(ret, id)
+		}
+	}
+	for _, dev := range d.Devices {
+		switch dev.Type {
+		case IVHDEntryAll:
+			for id := 0; id <= 0xffff; id++ {
+				add(uint16(id))
+			}
+		case IVHDEntrySelect, IVHDEntryAliasSelect, IVHDEntryExtSelect, IVHDEntryACPIHID:
+			add(dev.DeviceID)
+		case IVHDEntryStartRange, IVHDEntryAliasRange, IVHDEntryExtRange:
+			for id := int(dev.DeviceID); id <= int(dev.LastDeviceID); id++ {
+				add(uint16(id))
+			}
+		}
+	}
+
+	return ret
+}
+
+// IVRSIVMD is an I/O virtualization memory definition
+type IVRSIVMD struct {
+	Type     uint8
+	Flags    uint8
+	DeviceID uint16
+	// AuxData is the last device ID of type 22h ranges
+	AuxData      uint16
+	StartAddress uint64
+	Length       uint64
+}
+
+// IVRS holds the decoded I/O virtualization reporting structure
+type IVRS struct {
+	IVInfo uint32
+	IVHDs  []IVRSIVHD
+	IVMDs  []IVRSIVMD
+}
+
+// DMARemapRequested returns true if the platform requests the OS to enable DMA remapping
+func (i *IVRS) DMARemapRequested() bool {
+	return i.IVInfo&ivrsInfoDMARemap != 0
+}
+
+// PhysicalAddressSize returns the maximum supported physical address size in bits
+func (i *IVRS) PhysicalAddressSize() int {
+	return int(i.IVInfo>>8) & 0x7f
+}
+
+// VirtualAddressSize returns the maximum supported virtual address size in bits
+func (i *IVRS) VirtualAddressSize() int {
+	return int(i.IVInfo>>15) & 0x7f
+}
+
+// PreferredIVHDs returns one IVHD per IOMMU, preferring the newest type
+func (i *IVRS) PreferredIVHDs() []IVRSIVHD {
+	var ret []IVRSIVHD
+	idx := map[uint64]int{}
+
+	for _, ivhd := range i.IVHDs {
+		j, ok := idx[ivhd.BaseAddress]
+		if !ok {
+			idx[ivhd.BaseAddress] = len(ret)
+			ret = append(ret, ivhd)
+		} else if ivhd.Type > ret[j].Type {
+			ret[j] = ivhd
+		}
+	}
+
+	return ret
+}
+
+func parseIVHDDeviceEntries(buf []byte) ([]IVHDDeviceEntry, error) {
+	var ret []IVHDDeviceEntry
+	var start *IVHDDeviceEntry
+
+	for len(buf) > 0 {
+		typ := buf[0]
+		var length int
+		switch {
+		case typ == IVHDEntryACPIHID:
+			if len(buf) < 22 {
+				return nil, fmt.Errorf("IVHD ACPI device entry too short")
+			}
+			length = 22 + int(buf[21])
+		case typ < 0x40:
+			length = 4
+		case typ < 0x80:
+			length = 8
+		default:
+			return nil, fmt.Errorf("unsupported IVHD device entry type %#x", typ)
+		}
+		if length > len(buf) {
+			return nil, fmt.Errorf("IVHD device entry %#x is truncated", typ)
+		}
+
+		ent := IVHDDeviceEntry{
+			Type:       typ,
+			DeviceID:   binary.LittleEndian.Uint16(buf[1:]),
+			DTESetting: buf[3],
+		}
+		ent.LastDeviceID = ent.DeviceID
+		switch typ {
+		case IVHDEntryPad:
+		case IVHDEntryAll, IVHDEntrySelect:
+			ret = append(ret, ent)
+		case IVHDEntryAliasSelect:
+			ent.AliasID = binary.LittleEndian.Uint16(buf[5:])
+			ret = append(ret, ent)
+		case IVHDEntryExtSelect:
+			ret = append(ret, ent)
+		case IVHDEntryStartRange, IVHDEntryExtRange:
+			start = &ent
+		case IVHDEntryAliasRange:
+			ent.AliasID = binary.LittleEndian.Uint16(buf[5:])
+			start = &ent
+		case IVHDEntryEndRange:
+			if start == nil {
+				return nil, fmt.Errorf("IVHD range end %04x without start", ent.DeviceID)
+			}
+			start.LastDeviceID = ent.DeviceID
+			ret = append(ret, *start)
+			start = nil
+		case IVHDEntrySpecialDevice:
+			ent.Handle = buf[4]
+			ent.DeviceID = binary.LittleEndian.Uint16(buf[5:])
+			ent.LastDeviceID = ent.DeviceID
+			ent.Variety = buf[7]
+			ret = append(ret, ent)
+		case IVHDEntryACPIHID:
+			ent.HID = string(bytes.TrimRight(buf[4:12], "\x00"))
+			ent.CID = string(bytes.TrimRight(buf[12:20], "\x00"))
+			ent.UID = string(bytes.TrimRight(buf[22:length], "\x00"))
+			ret = append(ret, ent)
+		}
+		buf = buf[length:]
+	}
+	if start != nil {
+		return nil, fmt.Errorf("IVHD range start %04x without end", start.DeviceID)
+	}
+
+	return ret, nil
+}
+
+func parseIVRSIVHD(typ, flags uint8, buf []byte) (IVRSIVHD, error) {
+	ret := IVRSIVHD{Type: typ, Flags: flags}
+	var hdrSize int
+
+	if typ == IVRSTypeIVHD10 {
+		var hdr ivhdHeader10
+		err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+		if err != nil {
+			return ret, fmt.Errorf("cannot read IVHD: %v", err)
+		}
+		ret.DeviceID = hdr.DeviceID
+		ret.CapabilityOffset = hdr.CapabilityOffset
+		ret.BaseAddress = hdr.BaseAddress
+		ret.Segment = hdr.Segment
+		ret.IOMMUInfo = hdr.IOMMUInfo
+		ret.FeatureInfo = hdr.IOMMUFeatureInfo
+		hdrSize = binary.Size(hdr)
+	} else {
+		var hdr ivhdHeader40
+		err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+		if err != nil {
+			return ret, fmt.Errorf("cannot read IVHD: %v", err)
+		}
+		ret.DeviceID = hdr.DeviceID
+		ret.CapabilityOffset = hdr.CapabilityOffset
+		ret.BaseAddress = hdr.BaseAddress
+		ret.Segment = hdr.Segment
+		ret.IOMMUInfo = hdr.IOMMUInfo
+		ret.FeatureInfo = hdr.IOMMUAttributes
+		ret.ExtendedFeatures = hdr.ExtendedFeatures
+		ret.ExtendedFeatures2 = hdr.ExtendedFeatures2
+		hdrSize = binary.Size(hdr)
+	}
+
+	devices, err := parseIVHDDeviceEntries(buf[hdrSize:])
+	if err != nil {
+		return ret, err
+	}
+	ret.Devices = devices
+
+	return ret, nil
+}
+
+// ParseIVRS decodes the IVRS ACPI table
+func ParseIVRS(buf []byte) (*IVRS, error) {
+	var ret IVRS
+	var hdr ivrsHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read IVRS header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "IVRS" {
+		return nil, fmt.Errorf("IVRS has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("IVRS has invalid length %d", hdr.Length)
+	}
+	ret.IVInfo = hdr.IVInfo
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= ivdbHdrSize {
+		typ := buf[0]
+		flags := buf[1]
+		length := binary.LittleEndian.Uint16(buf[2:])
+		if int(length) < ivdbHdrSize || int(length) > len(buf) {
+			return nil, fmt.Errorf("IVRS block %#x has invalid length %d", typ, length)
+		}
+		data := buf[ivdbHdrSize:length]
+
+		switch typ {
+		case IVRSTypeIVHD10, IVRSTypeIVHD11, IVRSTypeIVHD40:
+			ivhd, err := parseIVRSIVHD(typ, flags, data)
+			if err != nil {
+				return nil, err
+			}
+			ret.IVHDs = append(ret.IVHDs, ivhd)
+		case IVRSTypeIVMD20, IVRSTypeIVMD21, IVRSTypeIVMD22:
+			var ivmd struct {
+				DeviceID     uint16
+				AuxData      uint16
+				Reserved     uint64
+				StartAddress uint64
+				Length       uint64
+			}
+			err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &ivmd)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read IVMD: %v", err)
+			}
+			ret.IVMDs = append(ret.IVMDs, IVRSIVMD{
+				Type:         typ,
+				Flags:        flags,
+				DeviceID:     ivmd.DeviceID,
+				AuxData:      ivmd.AuxData,
+				StartAddress: ivmd.StartAddress,
+				Length:       ivmd.Length,
+			})
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadIVRS reads and decodes the IVRS ACPI table
+func ReadIVRS(h LowLevelHardwareInterfaces) (*IVRS, error) {
+	buf, err := h.GetACPITable("IVRS")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseIVRS(buf)
+}
@@ -0,0 +1,82 @@
+package hwapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseIVRS(t *testing.T) {
+	hid := []byte{IVHDEntryACPIHID, 0xa5, 0x00, 0x40}
+	hid = append(hid, "AMDI0020"...)
+	hid = append(hid, make([]byte, 8)...)
+	hid = append(hid, 2, 4)
+	hid = append(hid, "ID00"...)
+
+	buf := acpiTestTable(t, "IVRS",
+		uint32(48<<8|64<<15|ivrsInfoDMARemap), uint64(0),
+		// IVHD type 10h, superseded by the type 40h below
+		[]byte{IVRSTypeIVHD10, 0xb0}, uint16(28),
+		uint16(0x0002), uint16(0x40), uint64(0xfd200000), uint16(0), uint16(0), uint32(0),
+		[]byte{IVHDEntryAll, 0, 0, 0},
+		// IVHD type 40h
+		[]byte{IVRSTypeIVHD40, 0xb0}, uint16(40+46),
+		uint16(0x0002), uint16(0x40), uint64(0xfd200000), uint16(0), uint16(0), uint32(0),
+		uint64(0x1234), uint64(0),
+		[]byte{IVHDEntrySelect, 0x08, 0x00, 0x00},
+		[]byte{IVHDEntryStartRange, 0x00, 0x01, 0x00},
+		[]byte{IVHDEntryEndRange, 0x03, 0x01, 0x00},
+		[]byte{IVHDEntrySpecialDevice, 0, 0, 0xd7, 0x21, 0xa0, 0x00, 0x01},
+		hid,
+		// IVMD type 21h
+		[]byte{IVRSTypeIVMD21, IVMDFlagUnity | IVMDFlagRead | IVMDFlagWrite}, uint16(32),
+		uint16(0x0008), uint16(0), uint64(0), uint64(0x80000000), uint64(0x100000),
+	)
+
+	ivrs, err := ParseIVRS(buf)
+	if err != nil {
+		t.Fatalf("ParseIVRS failed: %v", err)
+	}
+	if !ivrs.DMARemapRequested() || ivrs.PhysicalAddressSize() != 48 || ivrs.VirtualAddressSize() != 64 {
+		t.Errorf("Got IVInfo %x", ivrs.IVInfo)
+	}
+	if len(ivrs.IVHDs) != 2 {
+		t.Fatalf("Got %d IVHDs, want 2", len(ivrs.IVHDs))
+	}
+
+	ivhds := ivrs.PreferredIVHDs()
+	if len(ivhds) != 1 || ivhds[0].Type != IVRSTypeIVHD40 {
+		t.Fatalf("Got preferred IVHDs %+v, want the type 40h one", ivhds)
+	}
+	ivhd := ivhds[0]
+	if ivhd.BaseAddress != 0xfd200000 || ivhd.ExtendedFeatures != 0x1234 || len(ivhd.Devices) != 4 {
+		t.Errorf("Got IVHD %+v", ivhd)
+	}
+	special := ivhd.Devices[2]
+	if special.Type != IVHDEntrySpecialDevice || special.Handle != 0x21 || special.DeviceID != 0xa0 || special.Variety != 1 {
+		t.Errorf("Got special device %+v", special)
+	}
+	acpi := ivhd.Devices[3]
+	if acpi.HID != "AMDI0020" || acpi.CID != "" || acpi.UID != "ID00" || acpi.DeviceID != 0xa5 {
+		t.Errorf("Got ACPI device %+v", acpi)
+	}
+	if ids := fmt.Sprintf("%x", ivhd.DeviceIDs()); ids != "[8 100 101 102 103 a5]" {
+		t.Errorf("Got device IDs %s", ids)
+	}
+
+	if len(ivrs.IVMDs) != 1 || ivrs.IVMDs[0].StartAddress != 0x80000000 || ivrs.IVMDs[0].Length != 0x100000 ||
+		ivrs.IVMDs[0].Flags&IVMDFlagWrite == 0 {
+		t.Errorf("Got IVMDs %+v", ivrs.IVMDs)
+	}
+}
+
+func TestParseIVRSUnterminatedRange(t *testing.T) {
+	buf := acpiTestTable(t, "IVRS", uint32(0), uint64(0),
+		[]byte{IVRSTypeIVHD10, 0}, uint16(28),
+		uint16(0x0002), uint16(0x40), uint64(0xfd200000), uint16(0), uint16(0), uint32(0),
+		[]byte{IVHDEntryStartRange, 0x00, 0x01, 0x00},
+	)
+	_, err := ParseIVRS(buf)
+	if err == nil {
+		t.Errorf("ParseIVRS accepted a range without end")
+	}
+}
@@ -0,0 +1,415 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MADT interrupt controller structure types
+const (
+	MADTTypeLocalAPIC            = 0x0
+	MADTTypeIOAPIC               = 0x1
+	MADTTypeInterruptOverride    = 0x2
+	MADTTypeNMISource            = 0x3
+	MADTTypeLocalAPICNMI         = 0x4
+	MADTTypeLocalAPICOverride    = 0x5
+	MADTTypeIOSAPIC              = 0x6
+	MADTTypeLocalSAPIC           = 0x7
+	MADTTypePlatformInterrupt    = 0x8
+	MADTTypeLocalX2APIC          = 0x9
+	MADTTypeLocalX2APICNMI       = 0xa
+	MADTTypeGICC                 = 0xb
+	MADTTypeGICD                 = 0xc
+	MADTTypeGICMSIFrame          = 0xd
+	MADTTypeGICR                 = 0xe
+	MADTTypeGICITS               = 0xf
+	MADTTypeMultiprocessorWakeup = 0x10
+)
+
+// MADT flags
+const (
+	MADTFlagPCATCompat = 1 << 0
+)
+
+// MADT processor flags of Local APIC, x2APIC and GICC structures
+const (
+	MADTProcessorEnabled       = 1 << 0
+	MADTProcessorOnlineCapable = 1 << 1
+)
+
+const (
+	madtStructHdrSize = 2
+	presentCPUsPath   = "/sys/devices/system/cpu/present"
+)
+
+type madtHeader struct {
+	ACPIHeader
+	LocalAPICAddress uint32
+	Flags            uint32
+}
+
+// MADTLocalAPIC is a processor local APIC structure
+type MADTLocalAPIC struct {
+	ProcessorUID uint8
+	APICID       uint8
+	Flags        uint32
+}
+
+// MADTIOAPIC is an I/O APIC structure
+type MADTIOAPIC struct {
+	IOAPICID uint8
+	Reserved uint8
+	Address  uint32
+	// GSIBase is the first global system interrupt of the I/O APIC
+	GSIBase uint32
+}
+
+// MADTInterruptOverride is an interrupt source override structure.
+// Flags holds the MPS INTI polarity (bits 1:0) and trigger mode (bits 3:2).
+type MADTInterruptOverride struct {
+	Bus    uint8
+	Source uint8
+	GSI    uint32
+	Flags  uint16
+}
+
+// MADTNMISource is a non-maskable interrupt source structure
+type MADTNMISource struct {
+	Flags uint16
+	GSI   uint32
+}
+
+// MADTLocalAPICNMI is a local APIC or local x2APIC NMI structure.
+// A ProcessorUID of all ones applies to all processors.
+type MADTLocalAPICNMI struct {
+	ProcessorUID uint32
+	Flags        uint16
+	LINT         uint8
+}
+
+// MADTLocalX2APIC is a processor local x2APIC structure
+type MADTLocalX2APIC struct {
+	Reserved     uint16
+	X2APICID     uint32
+	Flags        uint32
+	ProcessorUID uint32
+}
+
+// MADTGICC is a GIC CPU interface structure
+type MADTGICC struct {
+	Reserved                 uint16
+	CPUInterfaceNumber       uint32
+	ProcessorUID             uint32
+	Flags                    uint32
+	ParkingProtocolVersion   uint32
+	PerformanceInterruptGSIV uint32
+	ParkedAddress            uint64
+	PhysicalBaseAddress      uint64
+	GICV                     uint64
+	GICH                     uint64
+	VGICMaintenanceInterrupt uint32
+	GICRBaseAddress          uint64
+	MPIDR                    uint64
+	ProcessorPowerEfficiency uint8
+	Reserved2                uint8
+	SPEOverflowInterrupt     uint16
+	TRBEInterrupt            uint16
+}
+
+// MADTGICD is a GIC distributor structure
+type MADTGICD struct {
+	Reserved            uint16
+	GICID               uint32
+	PhysicalBaseAddress uint64
+	SystemVectorBase    uint32
+	GICVersion          uint8
+	Reserved2           [3]uint8
+}
+
+// MADTGICMSIFrame is a GIC MSI frame structure
+type MADTGICMSIFrame struct {
+	Reserved            uint16
+	MSIFrameID          uint32
+	PhysicalBaseAddress uint64
+	Flags               uint32
+	SPICount            uint16
+	SPIBase             uint16
+}
+
+// MADTGICR is a GIC redistributor structure
+type MADTGICR struct {
+	Reserved             uint16
+	DiscoveryRangeBase   uint64
+	DiscoveryRangeLength uint32
+}
+
+// MADTGICITS is a GIC interrupt translation service structure
+type MADTGICITS struct {
+	Reserved            uint16
+	ITSID               uint32
+	PhysicalBaseAddress uint64
+	Reserved2           uint32
+}
+
+// MADTMultiprocessorWakeup is a multiprocessor wakeup structure used to
+// start application processors through a shared mailbox
+type MADTMultiprocessorWakeup struct {
+	MailboxVersion uint16
+	Reserved       uint32
+	MailboxAddress uint64
+	// ResetVector is only present in version 1 of the structure
+	ResetVector uint64
+}
+
+// MADTStruct is an interrupt controller structure not decoded otherwise
+type MADTStruct struct {
+	Type uint8
+	Data []byte
+}
+
+// MADT is the decoded ACPI Multiple APIC Description Table
+type MADT struct {
+	LocalAPICAddress     uint32
+	Flags                uint32
+	LocalAPICs           []MADTLocalAPIC
+	IOAPICs              []MADTIOAPIC
+	InterruptOverrides   []MADTInterruptOverride
+	NMISources           []MADTNMISource
+	LocalAPICNMIs        []MADTLocalAPICNMI
+	LocalAPICOverride    *uint64
+	LocalX2APICs         []MADTLocalX2APIC
+	LocalX2APICNMIs      []MADTLocalAPICNMI
+	GICCs                []MADTGICC
+	GICDs                []MADTGICD
+	GICMSIFrames         []MADTGICMSIFrame
+	GICRs                []MADTGICR
+	GICITSs              []MADTGICITS
+	MultiprocessorWakeup *MADTMultiprocessorWakeup
+	// Others holds the structures of other types, like the IA-64 SAPIC structures
+	Others []MADTStruct
+}
+
+// PCATCompat returns true if the system has dual 8259 interrupt controllers
+func (m *MADT) PCATCompat() bool {
+	return m.Flags&MADTFlagPCATCompat != 0
+}
+
+// LocalAPICBase returns the physical address of the local APICs, taking the
+// address override structure into account
+func (m *MADT) LocalAPICBase() uint64 {
+	if m.LocalAPICOverride != nil {
+		return *m.LocalAPICOverride
+	}
+	return uint64(m.LocalAPICAddress)
+}
+
+// EnabledProcessors returns the number of enabled processors. Processors
+// listed as Local APIC and x2APIC with the same APIC ID are counted once.
+func (m *MADT) EnabledProcessors() int {
+	apicIDs := map[uint32]bool{}
+	for _, l := range m.LocalAPICs {
+		if l.Flags&MADTProcessorEnabled != 0 {
+			apicIDs[uint32(l.APICID)] = true
+		}
+	}
+	for _, x := range m.LocalX2APICs {
+		if x.Flags&MADTProcessorEnabled != 0 {
+			apicIDs[x.X2APICID] = true
+		}
+	}
+	mpidrs := map[uint64]bool{}
+	for _, g := range m.GICCs {
+		if g.Flags&MADTProcessorEnabled != 0 {
+			mpidrs[g.MPIDR] = true
+		}
+	}
+	return len(apicIDs) + len(mpidrs)
+}
+
+// readMADTStruct decodes data into v, zero-extending structures written by older revisions
+func readMADTStruct(data []byte, v interface{}, name string) error {
+	size := binary.Size(v)
+	if len(data) < size {
+		data = append(append([]byte{}, data...), make([]byte, size-len(data))...)
+	}
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, v)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %v", name, err)
+	}
+	return nil
+}
+
+// ParseMADT decodes the ACPI MADT
+func ParseMADT(buf []byte) (*MADT, error) {
+	var ret MADT
+	var hdr madtHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read MADT header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "APIC" {
+		return nil, fmt.Errorf("MADT has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("MADT has invalid length %d", hdr.Length)
+	}
+	ret.LocalAPICAddress = hdr.LocalAPICAddress
+	ret.Flags = hdr.Flags
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= madtStructHdrSize {
+		typ := buf[0]
+		length := int(buf[1])
+		if length < madtStructHdrSize || length > len(buf) {
+			return nil, fmt.Errorf("MADT structure %d has invalid length %d", typ, length)
+		}
+		data := buf[madtStructHdrSize:length]
+
+		switch typ {
+		case MADTTypeLocalAPIC:
+			var l MADTLocalAPIC
+			err = readMADTStruct(data, &l, "local APIC")
+			ret.LocalAPICs = append(ret.LocalAPICs, l)
+		case MADTTypeIOAPIC:
+			var io MADTIOAPIC
+			err = readMADTStruct(data, &io, "I/O APIC")
+			ret.IOAPICs = append(ret.IOAPICs, io)
+		case MADTTypeInterruptOverride:
+			var o MADTInterruptOverride
+			err = readMADTStruct(data, &o, "interrupt source override")
+			ret.InterruptOverrides = append(ret.InterruptOverrides, o)
+		case MADTTypeNMISource:
+			var n MADTNMISource
+			err = readMADTStruct(data, &n, "NMI source")
+			ret.NMISources = append(ret.NMISources, n)
+		case MADTTypeLocalAPICNMI:
+			var raw struct {
+				ProcessorUID uint8
+				Flags        uint16
+				LINT         uint8
+			}
+			err = readMADTStruct(data, &raw, "local APIC NMI")
+			uid := uint32(raw.ProcessorUID)
+			if uid == 0xff {
+				uid = 0xffffffff
+			}
+			ret.LocalAPICNMIs = append(ret.LocalAPICNMIs, MADTLocalAPICNMI{ProcessorUID: uid, Flags: raw.Flags, LINT: raw.LINT})
+		case MADTTypeLocalAPICOverride:
+			var raw struct {
+				Reserved uint16
+				Address  uint64
+			}
+			err = readMADTStruct(data, &raw, "local APIC address override")
+			ret.LocalAPICOverride = &raw.Address
+		case MADTTypeLocalX2APIC:
+			var x MADTLocalX2APIC
+			err = readMADTStruct(data, &x, "local x2APIC")
+			ret.LocalX2APICs = append(ret.LocalX2APICs, x)
+		case MADTTypeLocalX2APICNMI:
+			var raw struct {
+				Flags        uint16
+				ProcessorUID uint32
+				LINT         uint8
+			}
+			err = readMADTStruct(data, &raw, "local x2APIC NMI")
+			ret.LocalX2APICNMIs = append(ret.LocalX2APICNMIs, MADTLocalAPICNMI{ProcessorUID: raw.ProcessorUID, Flags: raw.Flags, LINT: raw.LINT})
+		case MADTTypeGICC:
+			var g MADTGICC
+			err = readMADTStruct(data, &g, "GICC")
+			ret.GICCs = append(ret.GICCs, g)
+		case MADTTypeGICD:
+			var g MADTGICD
+			err = readMADTStruct(data, &g, "GICD")
+			ret.GICDs = append(ret.GICDs, g)
+		case MADTTypeGICMSIFrame:
+			var g MADTGICMSIFrame
+			err = readMADTStruct(data, &g, "GIC MSI frame")
+			ret.GICMSIFrames = append(ret.GICMSIFrames, g)
+		case MADTTypeGICR:
+			var g MADTGICR
+			err = readMADTStruct(data, &g, "GICR")
+			ret.GICRs = append(ret.GICRs, g)
+		case MADTTypeGICITS:
+			var g MADTGICITS
+			err = readMADTStruct(data, &g, "GIC ITS")
+			ret.GICITSs = append(ret.GICITSs, g)
+		case MADTTypeMultiprocessorWakeup:
+			var w MADTMultiprocessorWakeup
+			err = readMADTStruct(data, &w, "multiprocessor wakeup")
+			ret.MultiprocessorWakeup = &w
+		default:
+			ret.Others = append(ret.Others, MADTStruct{Type: typ, Data: data})
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadMADT reads the ACPI MADT and decodes it
+func ReadMADT(h LowLevelHardwareInterfaces) (*MADT, error) {
+	buf, err := h.GetACPITable("APIC")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMADT(buf)
+}
+
+// parseCPUList returns the number of CPUs in a sysfs CPU list like "0-3,8"
+func parseCPUList(list string) (int, error) {
+	count := 0
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		if r == "" {
+			continue
+		}
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return 0, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		count += last - first + 1
+	}
+	return count, nil
+}
+
+// PresentCPUCount returns the number of CPUs present as seen by the kernel
+func PresentCPUCount() (int, error) {
+	buf, err := os.ReadFile(presentCPUsPath)
+	if err != nil {
+		return 0, fmt.Errorf("cannot access sysfs path %s: %v", presentCPUsPath, err)
+	}
+	return parseCPUList(string(buf))
+}
+
+// CheckMADTProcessorCount returns an error if the number of enabled processors
+// in the MADT doesn't match the number of CPUs present
+func CheckMADTProcessorCount(h LowLevelHardwareInterfaces) error {
+	madt, err := ReadMADT(h)
+	if err != nil {
+		return err
+	}
+	present, err := PresentCPUCount()
+	if err != nil {
+		return err
+	}
+	if enabled := madt.EnabledProcessors(); enabled != present {
+		return fmt.Errorf("MADT lists %d enabled processors, but %d CPUs are present", enabled, present)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseMADT(t *testing.T) {
+	buf := acpiTestTable(t, "APIC", uint32(0xfee00000), uint32(MADTFlagPCATCompat),
+		// Local APICs 0 and 2 enabled, 1 disabled
+		[]byte{MADTTypeLocalAPIC, 8, 0, 0, 1, 0, 0, 0},
+		[]byte{MADTTypeLocalAPIC, 8, 1, 1, 0, 0, 0, 0},
+		[]byte{MADTTypeLocalAPIC, 8, 2, 2, 1, 0, 0, 0},
+		// x2APIC duplicating APIC ID 2 and a new one
+		[]byte{MADTTypeLocalX2APIC, 16, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0},
+		[]byte{MADTTypeLocalX2APIC, 16, 0, 0, 0, 1, 0, 0, 1, 0, 0, 0, 3, 0, 0, 0},
+		[]byte{MADTTypeIOAPIC, 12, 8, 0, 0, 0, 0xc0, 0xfe, 0, 0, 0, 0},
+		[]byte{MADTTypeInterruptOverride, 10, 0, 0, 2, 0, 0, 0, 0, 0},
+		[]byte{MADTTypeLocalAPICNMI, 6, 0xff, 5, 0, 1},
+		[]byte{MADTTypeLocalAPICOverride, 12, 0, 0, 0, 0, 0xe0, 0xfe, 0, 0, 0, 0},
+		[]byte{MADTTypeMultiprocessorWakeup, 16, 0, 0, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 0},
+		[]byte{MADTTypePlatformInterrupt, 4, 0xaa, 0xbb},
+	)
+
+	madt, err := ParseMADT(buf)
+	if err != nil {
+		t.Fatalf("ParseMADT failed: %v", err)
+	}
+	if !madt.PCATCompat() || madt.LocalAPICBase() != 0xfee00000 {
+		t.Errorf("Unexpected MADT flags %x or local APIC base %x", madt.Flags, madt.LocalAPICBase())
+	}
+	if len(madt.LocalAPICs) != 3 || len(madt.LocalX2APICs) != 2 || len(madt.IOAPICs) != 1 ||
+		len(madt.InterruptOverrides) != 1 || len(madt.LocalAPICNMIs) != 1 || len(madt.Others) != 1 {
+		t.Errorf("Unexpected MADT structures %+v", madt)
+	}
+	if madt.IOAPICs[0].IOAPICID != 8 || madt.IOAPICs[0].Address != 0xfec00000 {
+		t.Errorf("Unexpected I/O APIC %+v", madt.IOAPICs[0])
+	}
+	if o := madt.InterruptOverrides[0]; o.Source != 0 || o.GSI != 2 {
+		t.Errorf("Unexpected interrupt override %+v", o)
+	}
+	if n := madt.LocalAPICNMIs[0]; n.ProcessorUID != 0xffffffff || n.Flags != 5 || n.LINT != 1 {
+		t.Errorf("Unexpected local APIC NMI %+v", n)
+	}
+	if madt.MultiprocessorWakeup == nil || madt.MultiprocessorWakeup.MailboxAddress != 0x1000 {
+		t.Errorf("Unexpected multiprocessor wakeup %+v", madt.MultiprocessorWakeup)
+	}
+	if got := madt.EnabledProcessors(); got != 3 {
+		t.Errorf("Got %d enabled processors, want 3", got)
+	}
+
+	// GICC of ACPI 6.0 without the TRBE interrupt
+	gicc := make([]byte, 80)
+	gicc[0], gicc[1] = MADTTypeGICC, 80
+	gicc[12] = MADTProcessorEnabled
+	gicc[68] = 0x01 // MPIDR
+	buf = acpiTestTable(t, "APIC", uint32(0), uint32(0), gicc,
+		[]byte{MADTTypeGICD, 24, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x08, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0})
+	madt, err = ParseMADT(buf)
+	if err != nil {
+		t.Fatalf("ParseMADT failed: %v", err)
+	}
+	if len(madt.GICCs) != 1 || madt.GICCs[0].MPIDR != 1 || madt.EnabledProcessors() != 1 {
+		t.Errorf("Unexpected GICCs %+v", madt.GICCs)
+	}
+	if len(madt.GICDs) != 1 || madt.GICDs[0].PhysicalBaseAddress != 0x8000000 || madt.GICDs[0].GICVersion != 3 {
+		t.Errorf("Unexpected GICDs %+v", madt.GICDs)
+	}
+
+	_, err = ParseMADT(acpiTestTable(t, "APIC", uint32(0), uint32(0), []byte{MADTTypeIOAPIC, 1}))
+	if err == nil {
+		t.Errorf("ParseMADT accepted an invalid structure length")
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	for list, want := range map[string]int{"0\n": 1, "0-7\n": 8, "0-3,8-11,16": 9} {
+		got, err := parseCPUList(list)
+		if err != nil || got != want {
+			t.Errorf("parseCPUList(%q) = %d, %v, want %d", list, got, err, want)
+		}
+	}
+	if _, err := parseCPUList("3-1"); err == nil {
+		t.Errorf("parseCPUList accepted an invalid range")
+	}
+}
@@ -0,0 +1,145 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+type mcfgHeader struct {
+	ACPIHeader
+	Reserved uint64
+}
+
+// MCFGAllocation is a PCI express enhanced configuration space (ECAM) region
+type MCFGAllocation struct {
+	BaseAddress uint64
+	Segment     uint16
+	StartBus    uint8
+	EndBus      uint8
+	Reserved    uint32
+}
+
+// Range returns the first and last address of the region. The base address
+// corresponds to bus 0, even if the region starts at another bus.
+func (a MCFGAllocation) Range() (first, end uint64) {
+	return a.BaseAddress + uint64(a.StartBus)<<20, a.BaseAddress + (uint64(a.EndBus)+1)<<20 - 1
+}
+
+// MCFG is the decoded ACPI PCI express memory mapped configuration table
+type MCFG struct {
+	Allocations []MCFGAllocation
+}
+
+// ParseMCFG decodes the ACPI MCFG table
+func ParseMCFG(buf []byte) (*MCFG, error) {
+	var ret MCFG
+	var hdr mcfgHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read MCFG header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "MCFG" {
+		return nil, fmt.Errorf("MCFG has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) ||
+		(int(hdr.Length)-binary.Size(hdr))%binary.Size(MCFGAllocation{}) != 0 {
+		return nil, fmt.Errorf("MCFG has invalid length %d", hdr.Length)
+	}
+
+	ret.Allocations = make([]MCFGAllocation, (int(hdr.Length)-binary.Size(hdr))/binary.Size(MCFGAllocation{}))
+	err = binary.Read(bytes.NewReader(buf[binary.Size(hdr):hdr.Length]), binary.LittleEndian, ret.Allocations)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read MCFG allocations: %v", err)
+	}
+	for _, a := range ret.Allocations {
+		if a.EndBus < a.StartBus {
+			return nil, fmt.Errorf("MCFG allocation at %x has end bus %d below start bus %d", a.BaseAddress, a.EndBus, a.StartBus)
+		}
+	}
+
+	return &ret, nil
+}
+
+// ReadMCFG reads the ACPI MCFG table and decodes it
+func ReadMCFG(h LowLevelHardwareInterfaces) (*MCFG, error) {
+	buf, err := h.GetACPITable("MCFG")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMCFG(buf)
+}
+
+// ECAMAddress returns the physical address of the configuration space of the
+// device in the given PCI segment
+func (m *MCFG) ECAMAddress(segment uint16, d PCIDevice) (uint64, error) {
+	if d.Device > 31 || d.Function > 7 || d.Bus < 0 || d.Device < 0 || d.Function < 0 {
+		return 0, fmt.Errorf("invalid PCI device %02x:%02x.%x", d.Bus, d.Device, d.Function)
+	}
+	for _, a := range m.Allocations {
+		if a.Segment != segment || d.Bus < int(a.StartBus) || d.Bus > int(a.EndBus) {
+			continue
+		}
+		return a.BaseAddress + uint64(d.Bus)<<20 + uint64(d.Device)<<15 + uint64(d.Function)<<12, nil
+	}
+	return 0, fmt.Errorf("no ECAM region for PCI device %04x:%02x:%02x.%x", segment, d.Bus, d.Device, d.Function)
+}
+
+// mergedE820Ranges returns the e820 ranges of the given type sorted by address,
+// with adjacent and overlapping ranges merged
+func mergedE820Ranges(h LowLevelHardwareInterfaces, typ string) ([][2]uint64, error) {
+	var ranges [][2]uint64
+
+	_, err := h.IterateOverE820Ranges(typ, func(start uint64, end uint64) bool {
+		ranges = append(ranges, [2]uint64{start, end})
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+
+	var ret [][2]uint64
+	for _, r := range ranges {
+		if n := len(ret); n > 0 && (ret[n-1][1] == math.MaxUint64 || r[0] <= ret[n-1][1]+1) {
+			if r[1] > ret[n-1][1] {
+				ret[n-1][1] = r[1]
+			}
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+// FindMCFGRegionsNotReserved returns all ECAM regions that aren't marked
+// reserved in the e820 table. A region may span several adjacent reserved entries.
+func FindMCFGRegionsNotReserved(h LowLevelHardwareInterfaces, mcfg *MCFG) ([]MCFGAllocation, error) {
+	var ret []MCFGAllocation
+
+	reserved, err := mergedE820Ranges(h, "reserved")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range mcfg.Allocations {
+		first, end := a.Range()
+		covered := false
+		for _, r := range reserved {
+			if r[0] <= first && r[1] >= end {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			ret = append(ret, a)
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,77 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+// e820TestAPI reports fixed e820 ranges
+type e820TestAPI struct {
+	HwAPI
+	ranges map[string][][2]uint64
+}
+
+func (e e820TestAPI) IterateOverE820Ranges(target string, callback func(start uint64, end uint64) bool) (bool, error) {
+	for _, r := range e.ranges[target] {
+		if callback(r[0], r[1]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestParseMCFG(t *testing.T) {
+	buf := acpiTestTable(t, "MCFG", uint64(0),
+		MCFGAllocation{BaseAddress: 0xe0000000, Segment: 0, StartBus: 0, EndBus: 0xff},
+		MCFGAllocation{BaseAddress: 0xd0000000, Segment: 1, StartBus: 0x80, EndBus: 0x8f})
+
+	mcfg, err := ParseMCFG(buf)
+	if err != nil {
+		t.Fatalf("ParseMCFG failed: %v", err)
+	}
+	if len(mcfg.Allocations) != 2 {
+		t.Fatalf("Got %d allocations, want 2", len(mcfg.Allocations))
+	}
+
+	for _, tc := range []struct {
+		segment uint16
+		dev     PCIDevice
+		want    uint64
+	}{
+		{0, PCIDevice{Bus: 0, Device: 0, Function: 0}, 0xe0000000},
+		{0, PCIDevice{Bus: 1, Device: 0x1f, Function: 7}, 0xe01ff000},
+		{1, PCIDevice{Bus: 0x81, Device: 2, Function: 0}, 0xd8110000},
+	} {
+		got, err := mcfg.ECAMAddress(tc.segment, tc.dev)
+		if err != nil || got != tc.want {
+			t.Errorf("ECAMAddress(%d, %+v) = %x, %v, want %x", tc.segment, tc.dev, got, err, tc.want)
+		}
+	}
+	if _, err := mcfg.ECAMAddress(1, PCIDevice{Bus: 0x7f}); err == nil {
+		t.Errorf("ECAMAddress returned an address for a bus outside the regions")
+	}
+
+	h := e820TestAPI{ranges: map[string][][2]uint64{"reserved": {{0xe0000000, 0xefffffff}}}}
+	missing, err := FindMCFGRegionsNotReserved(h, mcfg)
+	if err != nil {
+		t.Fatalf("FindMCFGRegionsNotReserved failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0].Segment != 1 {
+		t.Errorf("Unexpected regions not reserved %+v", missing)
+	}
+
+	// firmware splitting the window across adjacent reserved entries
+	h = e820TestAPI{ranges: map[string][][2]uint64{"reserved": {
+		{0xe8000000, 0xefffffff}, {0xe0000000, 0xe7ffffff}, {0xd0000000, 0xd0ffffff},
+	}}}
+	missing, err = FindMCFGRegionsNotReserved(h, mcfg)
+	if err != nil {
+		t.Fatalf("FindMCFGRegionsNotReserved failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0].Segment != 1 {
+		t.Errorf("Unexpected regions not reserved %+v", missing)
+	}
+
+	if _, err := ParseMCFG(acpiTestTable(t, "MCFG", uint64(0), uint32(0))); err == nil {
+		t.Errorf("ParseMCFG accepted an invalid length")
+	}
+}
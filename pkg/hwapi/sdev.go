@@ -0,0 +1,183 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// SDEV secure device structure types
+const (
+	SDEVTypeACPINamespaceDevice = 0
+	SDEVTypePCIeEndpoint        = 1
+)
+
+// SDEV secure device structure flags
+const (
+	SDEVFlagAllowHandoff                  = 1 << 0
+	SDEVFlagSecureAccessComponentsPresent = 1 << 1
+)
+
+const (
+	sdevStructHdrSize = 4
+)
+
+// SDEVDevice is a secure device structure of the SDEV table
+type SDEVDevice struct {
+	Type  uint8
+	Flags uint8
+	// DeviceIdentifier is the ACPI namespace path of ACPI namespace devices
+	DeviceIdentifier string
+	// Segment, StartBus and Path locate PCIe endpoints
+	Segment  uint16
+	StartBus uint16
+	Path     []DMARDeviceScopePath
+	// VendorInfo holds the vendor specific information
+	VendorInfo []byte
+	// SecureAccessComponents holds the raw secure access components of ACPI
+	// namespace devices, if present
+	SecureAccessComponents []byte
+}
+
+// AllowHandoff returns true if the device may be handed off to the OS if no
+// secure OS is present
+func (d SDEVDevice) AllowHandoff() bool {
+	return d.Flags&SDEVFlagAllowHandoff != 0
+}
+
+// SDEV is the decoded ACPI Secure Devices table
+type SDEV struct {
+	Devices []SDEVDevice
+}
+
+// sdevField returns the part of the structure at the given offset and length
+func sdevField(buf []byte, off, length uint16, name string) ([]byte, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	if int(off)+int(length) > len(buf) || int(off) < sdevStructHdrSize {
+		return nil, fmt.Errorf("SDEV %s at offset %d with length %d is out of bounds", name, off, length)
+	}
+	return buf[off : off+length], nil
+}
+
+func parseSDEVNamespaceDevice(dev *SDEVDevice, buf []byte) error {
+	var raw struct {
+		DeviceIDOffset   uint16
+		DeviceIDLength   uint16
+		VendorInfoOffset uint16
+		VendorInfoLength uint16
+	}
+
+	err := binary.Read(bytes.NewReader(buf[sdevStructHdrSize:]), binary.LittleEndian, &raw)
+	if err != nil {
+		return fmt.Errorf("cannot read SDEV ACPI namespace device: %v", err)
+	}
+	id, err := sdevField(buf, raw.DeviceIDOffset, raw.DeviceIDLength, "device identifier")
+	if err != nil {
+		return err
+	}
+	if i := bytes.IndexByte(id, 0); i >= 0 {
+		id = id[:i]
+	}
+	dev.DeviceIdentifier = string(id)
+	dev.VendorInfo, err = sdevField(buf, raw.VendorInfoOffset, raw.VendorInfoLength, "vendor information")
+	if err != nil {
+		return err
+	}
+
+	if dev.Flags&SDEVFlagSecureAccessComponentsPresent != 0 {
+		off := sdevStructHdrSize + binary.Size(raw)
+		if len(buf) < off+4 {
+			return fmt.Errorf("SDEV ACPI namespace device lacks secure access components")
+		}
+		dev.SecureAccessComponents, err = sdevField(buf, binary.LittleEndian.Uint16(buf[off:]),
+			binary.LittleEndian.Uint16(buf[off+2:]), "secure access components")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseSDEVPCIeEndpoint(dev *SDEVDevice, buf []byte) error {
+	var raw struct {
+		Segment          uint16
+		StartBus         uint16
+		PathOffset       uint16
+		PathLength       uint16
+		VendorInfoOffset uint16
+		VendorInfoLength uint16
+	}
+
+	err := binary.Read(bytes.NewReader(buf[sdevStructHdrSize:]), binary.LittleEndian, &raw)
+	if err != nil {
+		return fmt.Errorf("cannot read SDEV PCIe endpoint: %v", err)
+	}
+	dev.Segment = raw.Segment
+	dev.StartBus = raw.StartBus
+	path, err := sdevField(buf, raw.PathOffset, raw.PathLength, "device path")
+	if err != nil {
+		return err
+	}
+	if len(path)%2 != 0 {
+		return fmt.Errorf("SDEV PCIe endpoint has invalid path length %d", len(path))
+	}
+	for i := 0; i < len(path); i += 2 {
+		dev.Path = append(dev.Path, DMARDeviceScopePath{Device: path[i], Function: path[i+1]})
+	}
+	dev.VendorInfo, err = sdevField(buf, raw.VendorInfoOffset, raw.VendorInfoLength, "vendor information")
+	return err
+}
+
+// ParseSDEV decodes the ACPI SDEV table
+func ParseSDEV(buf []byte) (*SDEV, error) {
+	var ret SDEV
+
+	hdr, err := ParseACPIHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	if string(hdr.Signature[:]) != "SDEV" {
+		return nil, fmt.Errorf("SDEV has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) {
+		return nil, fmt.Errorf("SDEV has invalid length %d", hdr.Length)
+	}
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= sdevStructHdrSize {
+		typ := buf[0]
+		length := binary.LittleEndian.Uint16(buf[2:])
+		if int(length) < sdevStructHdrSize || int(length) > len(buf) {
+			return nil, fmt.Errorf("SDEV structure %d has invalid length %d", typ, length)
+		}
+		dev := SDEVDevice{Type: typ, Flags: buf[1]}
+
+		switch typ {
+		case SDEVTypeACPINamespaceDevice:
+			err = parseSDEVNamespaceDevice(&dev, buf[:length])
+		case SDEVTypePCIeEndpoint:
+			err = parseSDEVPCIeEndpoint(&dev, buf[:length])
+		default:
+			err = fmt.Errorf("SDEV has unknown structure type %d", typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret.Devices = append(ret.Devices, dev)
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadSDEV reads the ACPI SDEV table and decodes it
+func ReadSDEV(h LowLevelHardwareInterfaces) (*SDEV, error) {
+	buf, err := h.GetACPITable("SDEV")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSDEV(buf)
+}
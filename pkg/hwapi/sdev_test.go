@@ -0,0 +1,39 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseSDEV(t *testing.T) {
+	ns := []byte{
+		SDEVTypeACPINamespaceDevice, SDEVFlagAllowHandoff, 20, 0,
+		12, 0, 6, 0, // device identifier
+		18, 0, 2, 0, // vendor information
+		'\\', '_', 'S', 'B', '.', 'X', 0xaa, 0xbb,
+	}
+	pcie := []byte{
+		SDEVTypePCIeEndpoint, 0, 20, 0,
+		0, 0, 1, 0, // segment 0, start bus 1
+		16, 0, 4, 0, // path
+		0, 0, 0, 0, // no vendor information
+		0x1c, 0, 0, 2,
+	}
+	sdev, err := ParseSDEV(acpiTestTable(t, "SDEV", ns, pcie))
+	if err != nil {
+		t.Fatalf("ParseSDEV failed: %v", err)
+	}
+	if len(sdev.Devices) != 2 {
+		t.Fatalf("Got %d devices, want 2", len(sdev.Devices))
+	}
+	if d := sdev.Devices[0]; !d.AllowHandoff() || d.DeviceIdentifier != "\\_SB.X" || len(d.VendorInfo) != 2 {
+		t.Errorf("Unexpected ACPI namespace device %+v", d)
+	}
+	if d := sdev.Devices[1]; d.AllowHandoff() || d.StartBus != 1 || len(d.Path) != 2 || d.Path[1].Function != 2 {
+		t.Errorf("Unexpected PCIe endpoint %+v", d)
+	}
+
+	pcie[10] = 8
+	if _, err := ParseSDEV(acpiTestTable(t, "SDEV", pcie)); err == nil {
+		t.Errorf("ParseSDEV accepted a path out of bounds")
+	}
+}
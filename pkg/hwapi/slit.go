@@ -0,0 +1,74 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// SLITUnreachable is the distance of localities that can't reach each other
+	SLITUnreachable = 0xff
+	// SLITLocalDistance is the distance of a locality to itself
+	SLITLocalDistance = 10
+)
+
+type slitHeader struct {
+	ACPIHeader
+	Localities uint64
+}
+
+// SLIT is the decoded ACPI System Locality Information Table
+type SLIT struct {
+	Localities int
+	// Matrix holds the relative distance from locality i to j at i*Localities+j
+	Matrix []uint8
+}
+
+// Distance returns the relative distance from locality i to locality j
+func (s *SLIT) Distance(i, j int) (uint8, error) {
+	if i < 0 || j < 0 || i >= s.Localities || j >= s.Localities {
+		return 0, fmt.Errorf("locality out of range")
+	}
+	return s.Matrix[i*s.Localities+j], nil
+}
+
+// ParseSLIT decodes the ACPI SLIT table
+func ParseSLIT(buf []byte) (*SLIT, error) {
+	var hdr slitHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SLIT header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "SLIT" {
+		return nil, fmt.Errorf("SLIT has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("SLIT has invalid length %d", hdr.Length)
+	}
+	matrix := buf[binary.Size(hdr):hdr.Length]
+	if hdr.Localities > 0xffff || uint64(len(matrix)) < hdr.Localities*hdr.Localities {
+		return nil, fmt.Errorf("SLIT is too short for %d localities", hdr.Localities)
+	}
+	n := int(hdr.Localities)
+
+	ret := SLIT{Localities: n, Matrix: append([]uint8{}, matrix[:n*n]...)}
+	for i := 0; i < n; i++ {
+		if d := ret.Matrix[i*n+i]; d != SLITLocalDistance {
+			return nil, fmt.Errorf("SLIT has invalid local distance %d for locality %d", d, i)
+		}
+	}
+
+	return &ret, nil
+}
+
+// ReadSLIT reads the ACPI SLIT table and decodes it
+func ReadSLIT(h LowLevelHardwareInterfaces) (*SLIT, error) {
+	buf, err := h.GetACPITable("SLIT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSLIT(buf)
+}
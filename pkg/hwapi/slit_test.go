@@ -0,0 +1,25 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseSLIT(t *testing.T) {
+	slit, err := ParseSLIT(acpiTestTable(t, "SLIT", uint64(2), []byte{10, 21, 21, 10}))
+	if err != nil {
+		t.Fatalf("ParseSLIT failed: %v", err)
+	}
+	if d, err := slit.Distance(0, 1); err != nil || d != 21 {
+		t.Errorf("Distance(0, 1) = %d, %v, want 21", d, err)
+	}
+	if _, err := slit.Distance(2, 0); err == nil {
+		t.Errorf("Distance accepted a locality out of range")
+	}
+
+	if _, err := ParseSLIT(acpiTestTable(t, "SLIT", uint64(3), []byte{10, 21, 21, 10})); err == nil {
+		t.Errorf("ParseSLIT accepted a short matrix")
+	}
+	if _, err := ParseSLIT(acpiTestTable(t, "SLIT", uint64(1), []byte{0})); err == nil {
+		t.Errorf("ParseSLIT accepted an invalid local distance")
+	}
+}
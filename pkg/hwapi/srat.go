@@ -0,0 +1,291 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// SRAT static resource affinity structure types
+const (
+	SRATTypeProcessorAffinity        = 0
+	SRATTypeMemoryAffinity           = 1
+	SRATTypeX2APICAffinity           = 2
+	SRATTypeGICCAffinity             = 3
+	SRATTypeGICITSAffinity           = 4
+	SRATTypeGenericInitiatorAffinity = 5
+	SRATTypeGenericPortAffinity      = 6
+)
+
+// SRAT affinity flags
+const (
+	SRATFlagEnabled = 1 << 0
+	// SRATFlagHotPluggable is only valid for memory affinity structures
+	SRATFlagHotPluggable = 1 << 1
+	// SRATFlagNonVolatile is only valid for memory affinity structures
+	SRATFlagNonVolatile = 1 << 2
+)
+
+const (
+	sratStructHdrSize = 2
+)
+
+type sratHeader struct {
+	ACPIHeader
+	Reserved1 uint32
+	Reserved2 uint64
+}
+
+// SRATProcessorAffinity associates a processor with a proximity domain. APICID
+// holds the x2APIC ID for x2APIC affinity and the ACPI processor UID for GICC
+// affinity structures.
+type SRATProcessorAffinity struct {
+	Type            uint8
+	ProximityDomain uint32
+	APICID          uint32
+	SAPICEID        uint8
+	Flags           uint32
+	ClockDomain     uint32
+}
+
+// SRATMemoryAffinity associates a memory range with a proximity domain
+type SRATMemoryAffinity struct {
+	ProximityDomain uint32
+	Base            uint64
+	Length          uint64
+	Flags           uint32
+}
+
+// Enabled returns true if the structure is in use
+func (m SRATMemoryAffinity) Enabled() bool {
+	return m.Flags&SRATFlagEnabled != 0
+}
+
+// HotPluggable returns true if the memory range may be hot added or removed
+func (m SRATMemoryAffinity) HotPluggable() bool {
+	return m.Flags&SRATFlagHotPluggable != 0
+}
+
+// NonVolatile returns true if the memory range is non-volatile
+func (m SRATMemoryAffinity) NonVolatile() bool {
+	return m.Flags&SRATFlagNonVolatile != 0
+}
+
+// SRATGICITSAffinity associates a GIC ITS with a proximity domain
+type SRATGICITSAffinity struct {
+	ProximityDomain uint32
+	Reserved        uint16
+	ITSID           uint32
+}
+
+// SRATGenericAffinity associates a generic initiator or generic port with a
+// proximity domain. DeviceHandle holds an ACPI (HID, UID) or PCI
+// (segment, BDF) device handle depending on DeviceHandleType.
+type SRATGenericAffinity struct {
+	Type             uint8
+	DeviceHandleType uint8
+	ProximityDomain  uint32
+	DeviceHandle     [16]byte
+	Flags            uint32
+}
+
+// SRAT is the decoded ACPI System Resource Affinity Table
+type SRAT struct {
+	Processors        []SRATProcessorAffinity
+	Memory            []SRATMemoryAffinity
+	GICITSs           []SRATGICITSAffinity
+	GenericInitiators []SRATGenericAffinity
+}
+
+// ParseSRAT decodes the ACPI SRAT table
+func ParseSRAT(buf []byte) (*SRAT, error) {
+	var ret SRAT
+	var hdr sratHeader
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read SRAT header: %v", err)
+	}
+	if string(hdr.Signature[:]) != "SRAT" {
+		return nil, fmt.Errorf("SRAT has invalid signature")
+	}
+	if int(hdr.Length) > len(buf) || int(hdr.Length) < binary.Size(hdr) {
+		return nil, fmt.Errorf("SRAT has invalid length %d", hdr.Length)
+	}
+
+	buf = buf[binary.Size(hdr):hdr.Length]
+	for len(buf) >= sratStructHdrSize {
+		typ := buf[0]
+		length := int(buf[1])
+		if length < sratStructHdrSize || length > len(buf) {
+			return nil, fmt.Errorf("SRAT structure %d has invalid length %d", typ, length)
+		}
+		reader := bytes.NewReader(buf[sratStructHdrSize:length])
+
+		switch typ {
+		case SRATTypeProcessorAffinity:
+			var raw struct {
+				ProximityDomainLo uint8
+				APICID            uint8
+				Flags             uint32
+				SAPICEID          uint8
+				ProximityDomainHi [3]uint8
+				ClockDomain       uint32
+			}
+			err = binary.Read(reader, binary.LittleEndian, &raw)
+			ret.Processors = append(ret.Processors, SRATProcessorAffinity{
+				Type: typ,
+				ProximityDomain: uint32(raw.ProximityDomainLo) | uint32(raw.ProximityDomainHi[0])<<8 |
+					uint32(raw.ProximityDomainHi[1])<<16 | uint32(raw.ProximityDomainHi[2])<<24,
+				APICID:      uint32(raw.APICID),
+				SAPICEID:    raw.SAPICEID,
+				Flags:       raw.Flags,
+				ClockDomain: raw.ClockDomain,
+			})
+		case SRATTypeMemoryAffinity:
+			var raw struct {
+				ProximityDomain uint32
+				Reserved1       uint16
+				Base            uint64
+				Length          uint64
+				Reserved2       uint32
+				Flags           uint32
+			}
+			err = binary.Read(reader, binary.LittleEndian, &raw)
+			ret.Memory = append(ret.Memory, SRATMemoryAffinity{
+				ProximityDomain: raw.ProximityDomain,
+				Base:            raw.Base,
+				Length:          raw.Length,
+				Flags:           raw.Flags,
+			})
+		case SRATTypeX2APICAffinity:
+			var raw struct {
+				Reserved1       uint16
+				ProximityDomain uint32
+				X2APICID        uint32
+				Flags           uint32
+				ClockDomain     uint32
+			}
+			err = binary.Read(reader, binary.LittleEndian, &raw)
+			ret.Processors = append(ret.Processors, SRATProcessorAffinity{
+				Type:            typ,
+				ProximityDomain: raw.ProximityDomain,
+				APICID:          raw.X2APICID,
+				Flags:           raw.Flags,
+				ClockDomain:     raw.ClockDomain,
+			})
+		case SRATTypeGICCAffinity:
+			var raw struct {
+				ProximityDomain uint32
+				ProcessorUID    uint32
+				Flags           uint32
+				ClockDomain     uint32
+			}
+			err = binary.Read(reader, binary.LittleEndian, &raw)
+			ret.Processors = append(ret.Processors, SRATProcessorAffinity{
+				Type:            typ,
+				ProximityDomain: raw.ProximityDomain,
+				APICID:          raw.ProcessorUID,
+				Flags:           raw.Flags,
+				ClockDomain:     raw.ClockDomain,
+			})
+		case SRATTypeGICITSAffinity:
+			var its SRATGICITSAffinity
+			err = binary.Read(reader, binary.LittleEndian, &its)
+			ret.GICITSs = append(ret.GICITSs, its)
+		case SRATTypeGenericInitiatorAffinity, SRATTypeGenericPortAffinity:
+			var raw struct {
+				Reserved         uint8
+				DeviceHandleType uint8
+				ProximityDomain  uint32
+				DeviceHandle     [16]byte
+				Flags            uint32
+			}
+			err = binary.Read(reader, binary.LittleEndian, &raw)
+			ret.GenericInitiators = append(ret.GenericInitiators, SRATGenericAffinity{
+				Type:             typ,
+				DeviceHandleType: raw.DeviceHandleType,
+				ProximityDomain:  raw.ProximityDomain,
+				DeviceHandle:     raw.DeviceHandle,
+				Flags:            raw.Flags,
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read SRAT structure %d: %v", typ, err)
+		}
+		buf = buf[length:]
+	}
+
+	return &ret, nil
+}
+
+// ReadSRAT reads the ACPI SRAT table and decodes it
+func ReadSRAT(h LowLevelHardwareInterfaces) (*SRAT, error) {
+	buf, err := h.GetACPITable("SRAT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSRAT(buf)
+}
+
+// NUMAMemoryRange is a memory range of a proximity domain present in the e820 table
+type NUMAMemoryRange struct {
+	ProximityDomain uint32
+	// Start and End are inclusive
+	Start uint64
+	End   uint64
+	// E820Type is the e820 type matched, like "system ram"
+	E820Type     string
+	HotPluggable bool
+	NonVolatile  bool
+}
+
+// numaE820Types are the e820 types joined with the SRAT memory ranges
+var numaE820Types = []string{"system ram", "persistent memory", "soft reserved"}
+
+// NUMAMemoryMap joins the enabled SRAT memory ranges with the e820 table and
+// returns the memory of each proximity domain sorted by address. Memory not in
+// the e820 table, like empty hot pluggable ranges, isn't returned.
+func NUMAMemoryMap(h LowLevelHardwareInterfaces, srat *SRAT) ([]NUMAMemoryRange, error) {
+	var ret []NUMAMemoryRange
+
+	for _, typ := range numaE820Types {
+		_, err := h.IterateOverE820Ranges(typ, func(start uint64, end uint64) bool {
+			for _, m := range srat.Memory {
+				if !m.Enabled() || m.Length == 0 {
+					continue
+				}
+				mEnd := m.Base + m.Length - 1
+				if m.Base > end || mEnd < start {
+					continue
+				}
+				r := NUMAMemoryRange{
+					ProximityDomain: m.ProximityDomain,
+					Start:           start,
+					End:             end,
+					E820Type:        typ,
+					HotPluggable:    m.HotPluggable(),
+					NonVolatile:     m.NonVolatile(),
+				}
+				if m.Base > r.Start {
+					r.Start = m.Base
+				}
+				if mEnd < r.End {
+					r.End = mEnd
+				}
+				ret = append(ret, r)
+			}
+			return false
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Start < ret[j].Start
+	})
+
+	return ret, nil
+}
@@ -0,0 +1,55 @@
+package hwapi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func sratTestMemory(domain uint32, base, length uint64, flags uint32) []interface{} {
+	return []interface{}{uint8(SRATTypeMemoryAffinity), uint8(40), domain, uint16(0),
+		base, length, uint32(0), flags, uint64(0)}
+}
+
+func TestParseSRAT(t *testing.T) {
+	fields := []interface{}{uint32(1), uint64(0),
+		// APIC ID 2 in domain 0x100
+		[]byte{SRATTypeProcessorAffinity, 16, 0x00, 2, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0},
+		uint8(SRATTypeX2APICAffinity), uint8(24), uint16(0), uint32(1), uint32(0x300), uint32(SRATFlagEnabled), uint32(0), uint32(0),
+	}
+	fields = append(fields, sratTestMemory(0, 0, 0x80000000, SRATFlagEnabled)...)
+	fields = append(fields, sratTestMemory(1, 0x100000000, 0x100000000, SRATFlagEnabled|SRATFlagNonVolatile)...)
+	fields = append(fields, sratTestMemory(1, 0x200000000, 0x100000000, SRATFlagEnabled|SRATFlagHotPluggable)...)
+	fields = append(fields, sratTestMemory(2, 0x300000000, 0x100000000, 0)...)
+	srat, err := ParseSRAT(acpiTestTable(t, "SRAT", fields...))
+	if err != nil {
+		t.Fatalf("ParseSRAT failed: %v", err)
+	}
+	if len(srat.Processors) != 2 || srat.Processors[0].ProximityDomain != 0x100 || srat.Processors[0].APICID != 2 ||
+		srat.Processors[1].ProximityDomain != 1 || srat.Processors[1].APICID != 0x300 {
+		t.Errorf("Unexpected SRAT processors %+v", srat.Processors)
+	}
+	if len(srat.Memory) != 4 || !srat.Memory[1].NonVolatile() || !srat.Memory[2].HotPluggable() || srat.Memory[3].Enabled() {
+		t.Errorf("Unexpected SRAT memory %+v", srat.Memory)
+	}
+
+	h := e820TestAPI{ranges: map[string][][2]uint64{
+		"system ram":        {{0x1000, 0x7fffffff}, {0x100000000, 0x17fffffff}},
+		"persistent memory": {{0x180000000, 0x1ffffffff}},
+	}}
+	ranges, err := NUMAMemoryMap(h, srat)
+	if err != nil {
+		t.Fatalf("NUMAMemoryMap failed: %v", err)
+	}
+	var got []string
+	for _, r := range ranges {
+		got = append(got, fmt.Sprintf("%d:%x-%x:%s:%v", r.ProximityDomain, r.Start, r.End, r.E820Type, r.NonVolatile))
+	}
+	want := []string{
+		"0:1000-7fffffff:system ram:false",
+		"1:100000000-17fffffff:system ram:true",
+		"1:180000000-1ffffffff:persistent memory:true",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
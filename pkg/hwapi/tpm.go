@@ -18,6 +18,7 @@ func (h HwAPI) NewTPM() (*TPM, error) {
 	if err != nil {
 		return nil, err
 	}
+	tpm.hw = h
 	return tpm, nil
 }
 
@@ -106,6 +106,9 @@ type TPM struct {
 
 	SysPath string
 	RWC     io.ReadWriteCloser
+
+	// hw is used to read the event log from the ACPI tables, set by HwAPI.NewTPM
+	hw LowLevelHardwareInterfaces
 }
 
 // probedTPM identifies a TPM device on the system, which
@@ -233,9 +236,22 @@ func newTPM(pTPM probedTPM) (*TPM, error) {
 }
 
 // MeasurementLog reads the TCPA eventlog in binary format
-// from the Linux kernel
+// from the Linux kernel. If securityfs isn't mounted, the log
+// is read from the log area described by the ACPI tables if the
+// TPM was opened by HwAPI.NewTPM.
 func (t *TPM) MeasurementLog() ([]byte, error) {
-	return os.ReadFile("/sys/kernel/security/tpm0/binary_bios_measurements")
+	buf, err := os.ReadFile("/sys/kernel/security/tpm0/binary_bios_measurements")
+	if err != nil {
+		if t.hw == nil {
+			return nil, err
+		}
+		log, acpiErr := ReadTPMEventLog(t.hw)
+		if acpiErr != nil {
+			return nil, err
+		}
+		return log, nil
+	}
+	return buf, nil
 }
 
 func nvRead12(rwc io.ReadWriteCloser, index, offset, len uint32, auth string) ([]byte, error) {
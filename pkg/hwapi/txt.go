@@ -0,0 +1,244 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// TXTPublicSpace is the physical address of the TXT public configuration space
+	TXTPublicSpace = 0xfed30000
+	// TXTPrivateSpace is the physical address of the TXT private configuration space
+	TXTPrivateSpace = 0xfed20000
+	// TXTConfigSpaceSize is the size of each TXT configuration space
+	TXTConfigSpaceSize = 0x10000
+
+	// Register offsets within the TXT configuration space
+	txtRegSTS       = 0x000
+	txtRegESTS      = 0x008
+	txtRegERRORCODE = 0x030
+	txtRegVERFSBIF  = 0x100
+	txtRegDIDVID    = 0x110
+	txtRegVERQPIIF  = 0x200
+	txtRegSINITBase = 0x270
+	txtRegSINITSize = 0x278
+	txtRegMLEJoin   = 0x290
+	txtRegHeapBase  = 0x300
+	txtRegHeapSize  = 0x308
+	txtRegDPR       = 0x330
+	txtRegPublicKey = 0x400
+	txtRegE2STS     = 0x8f0
+
+	// txtRegisterSpan covers all registers decoded by ReadTXTRegisters
+	txtRegisterSpan = txtRegE2STS + 8
+)
+
+// TXTStatus encodes the TXT.STS register
+type TXTStatus struct {
+	SENTERDone      bool
+	SEXITDone       bool
+	MemConfigLocked bool
+	PrivateOpen     bool
+	Locality1Open   bool
+	Locality2Open   bool
+}
+
+// TXTErrorStatus encodes the TXT.ESTS register
+type TXTErrorStatus struct {
+	// TXTReset is set if a TXT reset occurred
+	TXTReset bool
+	// WakeError is set if the platform went to sleep or was reset while secrets were in memory
+	WakeError bool
+}
+
+// TXTExtendedErrorStatus encodes the TXT.E2STS register
+type TXTExtendedErrorStatus struct {
+	SleepEntryError bool
+	// Secrets is set if secrets may be in memory
+	Secrets     bool
+	BlockMemory bool
+	Reset       bool
+}
+
+// TXTErrorCode encodes the TXT.ERRORCODE register
+type TXTErrorCode struct {
+	Raw uint32
+	// Valid is set if the error code is valid
+	Valid bool
+	// External is set if the error was reported by an ACM or software, cleared if reported by the processor
+	External bool
+	// Type is the external-specific error code, bits 29:0
+	Type uint32
+}
+
+// ACMModuleType is the module type found in an ACM error code
+type ACMModuleType uint8
+
+// ACM module types as reported in TXT.ERRORCODE
+const (
+	ACMModuleTypeBIOS  ACMModuleType = 0
+	ACMModuleTypeSINIT ACMModuleType = 1
+)
+
+func (t ACMModuleType) String() string {
+	switch t {
+	case ACMModuleTypeBIOS:
+		return "BIOS ACM"
+	case ACMModuleTypeSINIT:
+		return "SINIT"
+	}
+	return fmt.Sprintf("ACM type %d", uint8(t))
+}
+
+// ACMError is the breakdown of an error code reported by an ACM
+type ACMError struct {
+	ModuleType ACMModuleType
+	// Class is called progress code by older ACMs
+	Class uint8
+	Major uint8
+	Minor uint16
+}
+
+// IsACMError returns true if the error was reported by an ACM
+func (e TXTErrorCode) IsACMError() bool {
+	return e.External && e.Type&(1<<15) == 0
+}
+
+// ACMError returns the class, major and minor error code if the error was reported by an ACM
+func (e TXTErrorCode) ACMError() (ACMError, error) {
+	if !e.IsACMError() {
+		return ACMError{}, fmt.Errorf("TXT error code %08x wasn't reported by an ACM", e.Raw)
+	}
+
+	return ACMError{
+		ModuleType: ACMModuleType(e.Type & 0xf),     // 3:0
+		Class:      uint8((e.Type >> 4) & 0x3f),     // 9:4
+		Major:      uint8((e.Type >> 10) & 0x1f),    // 14:10
+		Minor:      uint16((e.Type >> 16) & 0x3fff), // 29:16
+	}, nil
+}
+
+func (e TXTErrorCode) String() string {
+	if !e.Valid {
+		return fmt.Sprintf("no valid error (%08x)", e.Raw)
+	}
+	if !e.External {
+		return fmt.Sprintf("processor error %x", e.Type)
+	}
+	acmErr, err := e.ACMError()
+	if err != nil {
+		return fmt.Sprintf("software error %x", e.Type)
+	}
+	return fmt.Sprintf("%s error: class %#x, major %#x, minor %#x",
+		acmErr.ModuleType, acmErr.Class, acmErr.Major, acmErr.Minor)
+}
+
+// TXTDeviceID encodes the TXT.DIDVID register
+type TXTDeviceID struct {
+	VendorID   uint16
+	DeviceID   uint16
+	RevisionID uint16
+	ExtendedID uint16
+}
+
+// TXTRegisters holds the decoded TXT public configuration space
+type TXTRegisters struct {
+	Status      TXTStatus
+	ErrorStatus TXTErrorStatus
+	ErrorCode   TXTErrorCode
+	DIDVID      TXTDeviceID
+	FSBIF       uint32
+	QPIIF       uint32
+	SINITBase   uint32
+	SINITSize   uint32
+	MLEJoin     uint32
+	HeapBase    uint32
+	HeapSize    uint32
+	DPR         DMAProtectedRange
+	// PublicKey is the hash of the chipset ACM signing key
+	PublicKey [32]byte
+	E2Status  TXTExtendedErrorStatus
+}
+
+// ProductionFused returns true if the chipset is production fused, false if debug fused
+func (r *TXTRegisters) ProductionFused() bool {
+	ver := r.FSBIF
+	if ver == 0 || ver == 0xffffffff {
+		ver = r.QPIIF
+	}
+	return ver&(1<<31) != 0
+}
+
+// parseTXTRegisters decodes the TXT configuration space starting at offset 0
+func parseTXTRegisters(buf []byte) (*TXTRegisters, error) {
+	var ret TXTRegisters
+
+	if len(buf) < txtRegisterSpan {
+		return nil, fmt.Errorf("TXT configuration space too short: %d bytes", len(buf))
+	}
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(buf[off:]) }
+	u64 := func(off int) uint64 { return binary.LittleEndian.Uint64(buf[off:]) }
+
+	sts := u64(txtRegSTS)
+	ret.Status = TXTStatus{
+		SENTERDone:      sts&(1<<0) != 0,
+		SEXITDone:       sts&(1<<1) != 0,
+		MemConfigLocked: sts&(1<<6) != 0,
+		PrivateOpen:     sts&(1<<7) != 0,
+		Locality1Open:   sts&(1<<15) != 0,
+		Locality2Open:   sts&(1<<16) != 0,
+	}
+
+	ests := u64(txtRegESTS)
+	ret.ErrorStatus = TXTErrorStatus{
+		TXTReset:  ests&(1<<0) != 0,
+		WakeError: ests&(1<<6) != 0,
+	}
+
+	errorcode := u32(txtRegERRORCODE)
+	ret.ErrorCode = TXTErrorCode{
+		Raw:      errorcode,
+		Valid:    errorcode&(1<<31) != 0,
+		External: errorcode&(1<<30) != 0,
+		Type:     errorcode & 0x3fffffff,
+	}
+
+	didvid := u64(txtRegDIDVID)
+	ret.DIDVID = TXTDeviceID{
+		VendorID:   uint16(didvid),
+		DeviceID:   uint16(didvid >> 16),
+		RevisionID: uint16(didvid >> 32),
+		ExtendedID: uint16(didvid >> 48),
+	}
+
+	ret.FSBIF = u32(txtRegVERFSBIF)
+	ret.QPIIF = u32(txtRegVERQPIIF)
+	ret.SINITBase = u32(txtRegSINITBase)
+	ret.SINITSize = u32(txtRegSINITSize)
+	ret.MLEJoin = u32(txtRegMLEJoin)
+	ret.HeapBase = u32(txtRegHeapBase)
+	ret.HeapSize = u32(txtRegHeapSize)
+	ret.DPR = decodeDPR(u32(txtRegDPR))
+	copy(ret.PublicKey[:], buf[txtRegPublicKey:txtRegPublicKey+32])
+
+	e2sts := u64(txtRegE2STS)
+	ret.E2Status = TXTExtendedErrorStatus{
+		SleepEntryError: e2sts&(1<<0) != 0,
+		Secrets:         e2sts&(1<<1) != 0,
+		BlockMemory:     e2sts&(1<<2) != 0,
+		Reset:           e2sts&(1<<3) != 0,
+	}
+
+	return &ret, nil
+}
+
+// ReadTXTRegisters reads and decodes the TXT public configuration space
+func ReadTXTRegisters(h LowLevelHardwareInterfaces) (*TXTRegisters, error) {
+	buf := make([]byte, txtRegisterSpan)
+	err := h.ReadPhysBuf(TXTPublicSpace, buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TXT public space: %v", err)
+	}
+
+	return parseTXTRegisters(buf)
+}
@@ -0,0 +1,455 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TXT heap extended data element types
+const (
+	TXTHeapElementEnd              = 0
+	TXTHeapElementBIOSSpecVer      = 1
+	TXTHeapElementACM              = 2
+	TXTHeapElementSTM              = 3
+	TXTHeapElementCustom           = 4
+	TXTHeapElementEventLogPointer  = 5
+	TXTHeapElementMADT             = 6
+	TXTHeapElementEventLogPointer2 = 7
+	TXTHeapElementEventLogPointer3 = 8
+	TXTHeapElementMCFG             = 9
+	TXTHeapElementPTT              = 10
+	TXTHeapElementCNBT             = 11
+)
+
+// MDR memory types as reported by SINIT
+const (
+	TXTMDRTypeGood              = 0
+	TXTMDRTypeSMRAMOverlay      = 1
+	TXTMDRTypeSMRAMNonOverlay   = 2
+	TXTMDRTypePCIeConfigSpace   = 3
+	TXTMDRTypeProtectedPCIeBars = 4
+)
+
+// TXTHeapElement is an extended data element of a TXT heap table
+type TXTHeapElement struct {
+	Type uint32
+	// Data without the element header
+	Data []byte
+}
+
+// TXTBIOSSpecVersion is the BIOS spec version element
+type TXTBIOSSpecVersion struct {
+	Major    uint16
+	Minor    uint16
+	Revision uint16
+}
+
+// TXTEventLogDescriptor describes a TPM event log container as found in the
+// event log pointer elements of the BiosData table
+type TXTEventLogDescriptor struct {
+	// HashAlg is only set for the TPM 2.0 log pointer element (type 7)
+	HashAlg                     uint16
+	Reserved                    uint16
+	PhysicalAddress             uint64
+	AllocatedEventContainerSize uint32
+	FirstRecordOffset           uint32
+	NextRecordOffset            uint32
+}
+
+type txtEventLogPointer3 struct {
+	PhysicalAddress             uint64
+	AllocatedEventContainerSize uint32
+	FirstRecordOffset           uint32
+	NextRecordOffset            uint32
+}
+
+type txtBiosDataRaw struct {
+	Version         uint32
+	BiosSinitSize   uint32
+	LcpPdBase       uint64
+	LcpPdSize       uint64
+	NumLogicalProcs uint32
+}
+
+// TXTBiosData is the BiosData table of the TXT heap
+type TXTBiosData struct {
+	Version         uint32
+	BiosSinitSize   uint32
+	LcpPdBase       uint64
+	LcpPdSize       uint64
+	NumLogicalProcs uint32
+	// Flags is only present since version 3
+	Flags uint32
+
+	// Elements are only present since version 4
+	Elements    []TXTHeapElement
+	SpecVersion *TXTBIOSSpecVersion
+	// ACMs holds the physical addresses of the ACMs listed in the ACM element
+	ACMs []uint64
+	// EventLogPointer is the TPM 1.2 event log address
+	EventLogPointer uint64
+	// EventLogs are the TPM 2.0 event log containers
+	EventLogs []TXTEventLogDescriptor
+	// PTT and CNBT hold the raw element data if present
+	PTT  []byte
+	CNBT []byte
+}
+
+type txtOsSinitDataRaw struct {
+	Version       uint32
+	Flags         uint32
+	MLEPtabBase   uint64
+	MLEPtabSize   uint64
+	MLEHeaderBase uint64
+	VtdPmrLoBase  uint64
+	VtdPmrLoSize  uint64
+	VtdPmrHiBase  uint64
+	VtdPmrHiSize  uint64
+	LcpPoBase     uint64
+	LcpPoSize     uint64
+	Capabilities  uint32
+}
+
+// TXTOsSinitData is the OsSinitData table of the TXT heap
+type TXTOsSinitData struct {
+	Version       uint32
+	Flags         uint32
+	MLEPtabBase   uint64
+	MLEPtabSize   uint64
+	MLEHeaderBase uint64
+	VtdPmrLoBase  uint64
+	VtdPmrLoSize  uint64
+	VtdPmrHiBase  uint64
+	VtdPmrHiSize  uint64
+	LcpPoBase     uint64
+	LcpPoSize     uint64
+	Capabilities  uint32
+	// EfiRsdtPtr is only present since version 5
+	EfiRsdtPtr uint64
+	// Elements are only present since version 6
+	Elements []TXTHeapElement
+}
+
+type txtSinitMleDataRaw struct {
+	Version                 uint32
+	BiosAcmID               [20]byte
+	EdxSenterFlags          uint32
+	MsegValid               uint64
+	SinitHash               [20]byte
+	MleHash                 [20]byte
+	StmHash                 [20]byte
+	LcpPolicyHash           [20]byte
+	LcpPolicyControl        uint32
+	RlpWakeupAddr           uint32
+	Reserved                uint32
+	NumberOfSinitMdrs       uint32
+	SinitMdrTableOffset     uint32
+	SinitVtdDmarTableSize   uint32
+	SinitVtdDmarTableOffset uint32
+}
+
+// TXTMemoryDescriptor is a SINIT memory descriptor record
+type TXTMemoryDescriptor struct {
+	Base     uint64
+	Length   uint64
+	Type     uint8
+	Reserved [7]byte
+}
+
+// TXTSinitMleData is the SinitMleData table of the TXT heap
+type TXTSinitMleData struct {
+	Version          uint32
+	BiosAcmID        [20]byte
+	EdxSenterFlags   uint32
+	MsegValid        uint64
+	SinitHash        [20]byte
+	MleHash          [20]byte
+	StmHash          [20]byte
+	LcpPolicyHash    [20]byte
+	LcpPolicyControl uint32
+	RlpWakeupAddr    uint32
+	// ProcessorSCRTMStatus is only present since version 8
+	ProcessorSCRTMStatus uint32
+	// MDRs is the memory descriptor list created by SINIT
+	MDRs []TXTMemoryDescriptor
+	// DMAR is the copy of the ACPI DMAR table created by SINIT
+	DMAR []byte
+}
+
+// TXTHeap holds the decoded tables of the TXT heap
+type TXTHeap struct {
+	BiosData TXTBiosData
+	// OsMleData is defined by the MLE and not decoded
+	OsMleData    []byte
+	OsSinitData  TXTOsSinitData
+	SinitMleData TXTSinitMleData
+}
+
+// splitTXTHeap returns the four heap tables. The tables are returned with their
+// size field as SINIT computes offsets relative to it.
+func splitTXTHeap(buf []byte) ([4][]byte, error) {
+	var ret [4][]byte
+
+	off := uint64(0)
+	for i := range ret {
+		if off+8 > uint64(len(buf)) {
+			return ret, fmt.Errorf("TXT heap table %d is out of bounds", i)
+		}
+		size := binary.LittleEndian.Uint64(buf[off:])
+		if size < 8 || off+size > uint64(len(buf)) {
+			return ret, fmt.Errorf("TXT heap table %d has invalid size %x", i, size)
+		}
+		ret[i] = buf[off : off+size]
+		off += size
+	}
+
+	return ret, nil
+}
+
+func parseTXTHeapElements(buf []byte) ([]TXTHeapElement, error) {
+	var ret []TXTHeapElement
+
+	for len(buf) >= 8 {
+		typ := binary.LittleEndian.Uint32(buf)
+		size := binary.LittleEndian.Uint32(buf[4:])
+		if typ == TXTHeapElementEnd {
+			return ret, nil
+		}
+		if size < 8 || uint64(size) > uint64(len(buf)) {
+			return ret, fmt.Errorf("TXT heap element %d has invalid size %x", typ, size)
+		}
+		ret = append(ret, TXTHeapElement{Type: typ, Data: buf[8:size]})
+		buf = buf[size:]
+	}
+
+	return ret, fmt.Errorf("TXT heap elements are not terminated")
+}
+
+func parseTXTEventLogPointer2(data []byte) ([]TXTEventLogDescriptor, error) {
+	var count uint32
+
+	reader := bytes.NewReader(data)
+	err := binary.Read(reader, binary.LittleEndian, &count)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(count)*uint64(binary.Size(TXTEventLogDescriptor{})) > uint64(reader.Len()) {
+		return nil, fmt.Errorf("TXT event log pointer element has invalid count %d", count)
+	}
+	ret := make([]TXTEventLogDescriptor, count)
+	err = binary.Read(reader, binary.LittleEndian, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func parseTXTBiosData(buf []byte) (TXTBiosData, error) {
+	var ret TXTBiosData
+	var raw txtBiosDataRaw
+
+	reader := bytes.NewReader(buf[8:])
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read BiosData: %v", err)
+	}
+	ret.Version = raw.Version
+	ret.BiosSinitSize = raw.BiosSinitSize
+	ret.LcpPdBase = raw.LcpPdBase
+	ret.LcpPdSize = raw.LcpPdSize
+	ret.NumLogicalProcs = raw.NumLogicalProcs
+	if ret.Version < 3 {
+		return ret, nil
+	}
+	err = binary.Read(reader, binary.LittleEndian, &ret.Flags)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read BiosData flags: %v", err)
+	}
+	if ret.Version < 4 {
+		return ret, nil
+	}
+
+	ret.Elements, err = parseTXTHeapElements(buf[len(buf)-reader.Len():])
+	if err != nil {
+		return ret, fmt.Errorf("cannot read BiosData elements: %v", err)
+	}
+	for _, e := range ret.Elements {
+		reader := bytes.NewReader(e.Data)
+		switch e.Type {
+		case TXTHeapElementBIOSSpecVer:
+			var ver TXTBIOSSpecVersion
+			err = binary.Read(reader, binary.LittleEndian, &ver)
+			ret.SpecVersion = &ver
+		case TXTHeapElementACM:
+			var numACMs uint32
+			err = binary.Read(reader, binary.LittleEndian, &numACMs)
+			if err == nil && uint64(numACMs)*8 > uint64(reader.Len()) {
+				err = fmt.Errorf("invalid number of ACMs %d", numACMs)
+			}
+			if err == nil {
+				ret.ACMs = make([]uint64, numACMs)
+				err = binary.Read(reader, binary.LittleEndian, &ret.ACMs)
+			}
+		case TXTHeapElementEventLogPointer:
+			err = binary.Read(reader, binary.LittleEndian, &ret.EventLogPointer)
+		case TXTHeapElementEventLogPointer2:
+			ret.EventLogs, err = parseTXTEventLogPointer2(e.Data)
+		case TXTHeapElementEventLogPointer3:
+			var ptr txtEventLogPointer3
+			err = binary.Read(reader, binary.LittleEndian, &ptr)
+			ret.EventLogs = append(ret.EventLogs, TXTEventLogDescriptor{
+				PhysicalAddress:             ptr.PhysicalAddress,
+				AllocatedEventContainerSize: ptr.AllocatedEventContainerSize,
+				FirstRecordOffset:           ptr.FirstRecordOffset,
+				NextRecordOffset:            ptr.NextRecordOffset,
+			})
+		case TXTHeapElementPTT:
+			ret.PTT = e.Data
+		case TXTHeapElementCNBT:
+			ret.CNBT = e.Data
+		}
+		if err != nil {
+			return ret, fmt.Errorf("cannot decode BiosData element %d: %v", e.Type, err)
+		}
+	}
+
+	return ret, nil
+}
+
+func parseTXTOsSinitData(buf []byte) (TXTOsSinitData, error) {
+	var ret TXTOsSinitData
+	var raw txtOsSinitDataRaw
+
+	reader := bytes.NewReader(buf[8:])
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read OsSinitData: %v", err)
+	}
+	ret = TXTOsSinitData{
+		Version:       raw.Version,
+		Flags:         raw.Flags,
+		MLEPtabBase:   raw.MLEPtabBase,
+		MLEPtabSize:   raw.MLEPtabSize,
+		MLEHeaderBase: raw.MLEHeaderBase,
+		VtdPmrLoBase:  raw.VtdPmrLoBase,
+		VtdPmrLoSize:  raw.VtdPmrLoSize,
+		VtdPmrHiBase:  raw.VtdPmrHiBase,
+		VtdPmrHiSize:  raw.VtdPmrHiSize,
+		LcpPoBase:     raw.LcpPoBase,
+		LcpPoSize:     raw.LcpPoSize,
+		Capabilities:  raw.Capabilities,
+	}
+	if ret.Version < 5 {
+		return ret, nil
+	}
+	err = binary.Read(reader, binary.LittleEndian, &ret.EfiRsdtPtr)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read OsSinitData RSDT pointer: %v", err)
+	}
+	if ret.Version < 6 {
+		return ret, nil
+	}
+	ret.Elements, err = parseTXTHeapElements(buf[len(buf)-reader.Len():])
+	if err != nil {
+		return ret, fmt.Errorf("cannot read OsSinitData elements: %v", err)
+	}
+
+	return ret, nil
+}
+
+func parseTXTSinitMleData(buf []byte) (TXTSinitMleData, error) {
+	var ret TXTSinitMleData
+	var raw txtSinitMleDataRaw
+
+	reader := bytes.NewReader(buf[8:])
+	err := binary.Read(reader, binary.LittleEndian, &raw)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read SinitMleData: %v", err)
+	}
+	ret = TXTSinitMleData{
+		Version:          raw.Version,
+		BiosAcmID:        raw.BiosAcmID,
+		EdxSenterFlags:   raw.EdxSenterFlags,
+		MsegValid:        raw.MsegValid,
+		SinitHash:        raw.SinitHash,
+		MleHash:          raw.MleHash,
+		StmHash:          raw.StmHash,
+		LcpPolicyHash:    raw.LcpPolicyHash,
+		LcpPolicyControl: raw.LcpPolicyControl,
+		RlpWakeupAddr:    raw.RlpWakeupAddr,
+	}
+	if ret.Version >= 8 {
+		err = binary.Read(reader, binary.LittleEndian, &ret.ProcessorSCRTMStatus)
+		if err != nil {
+			return ret, fmt.Errorf("cannot read SinitMleData SCRTM status: %v", err)
+		}
+	}
+
+	// MDR and DMAR offsets are relative to the size field of the table
+	mdrSize := uint64(binary.Size(TXTMemoryDescriptor{}))
+	mdrOff := uint64(raw.SinitMdrTableOffset)
+	if mdrOff+uint64(raw.NumberOfSinitMdrs)*mdrSize > uint64(len(buf)) {
+		return ret, fmt.Errorf("SinitMleData MDR table is out of bounds")
+	}
+	ret.MDRs = make([]TXTMemoryDescriptor, raw.NumberOfSinitMdrs)
+	err = binary.Read(bytes.NewReader(buf[mdrOff:]), binary.LittleEndian, &ret.MDRs)
+	if err != nil {
+		return ret, fmt.Errorf("cannot read SinitMleData MDRs: %v", err)
+	}
+
+	dmarOff := uint64(raw.SinitVtdDmarTableOffset)
+	dmarEnd := dmarOff + uint64(raw.SinitVtdDmarTableSize)
+	if dmarEnd > uint64(len(buf)) {
+		return ret, fmt.Errorf("SinitMleData DMAR table is out of bounds")
+	}
+	ret.DMAR = buf[dmarOff:dmarEnd]
+
+	return ret, nil
+}
+
+// ParseTXTHeap decodes the TXT heap
+func ParseTXTHeap(buf []byte) (*TXTHeap, error) {
+	var ret TXTHeap
+
+	tables, err := splitTXTHeap(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.BiosData, err = parseTXTBiosData(tables[0])
+	if err != nil {
+		return nil, err
+	}
+	ret.OsMleData = tables[1][8:]
+	ret.OsSinitData, err = parseTXTOsSinitData(tables[2])
+	if err != nil {
+		return nil, err
+	}
+	ret.SinitMleData, err = parseTXTSinitMleData(tables[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// ReadTXTHeap reads the TXT heap located by TXT.HEAP.BASE and TXT.HEAP.SIZE and decodes it
+func ReadTXTHeap(h LowLevelHardwareInterfaces) (*TXTHeap, error) {
+	regs, err := ReadTXTRegisters(h)
+	if err != nil {
+		return nil, err
+	}
+	if regs.HeapBase == 0 || regs.HeapSize == 0 || regs.HeapBase == 0xffffffff {
+		return nil, fmt.Errorf("TXT heap isn't configured")
+	}
+
+	buf := make([]byte, regs.HeapSize)
+	err = h.ReadPhysBuf(int64(regs.HeapBase), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read TXT heap: %v", err)
+	}
+
+	return ParseTXTHeap(buf)
+}
@@ -0,0 +1,91 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func txtHeapTable(t *testing.T, fields ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, f := range fields {
+		if err := binary.Write(&body, binary.LittleEndian, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ret bytes.Buffer
+	_ = binary.Write(&ret, binary.LittleEndian, uint64(body.Len()+8))
+	ret.Write(body.Bytes())
+	return ret.Bytes()
+}
+
+func TestParseTXTHeap(t *testing.T) {
+	biosData := txtHeapTable(t,
+		txtBiosDataRaw{Version: 5, BiosSinitSize: 0, NumLogicalProcs: 8},
+		uint32(0),
+		// BIOS spec version element
+		uint32(TXTHeapElementBIOSSpecVer), uint32(8+6), TXTBIOSSpecVersion{Major: 2, Minor: 1},
+		// ACM element with two ACMs
+		uint32(TXTHeapElementACM), uint32(8+4+16), uint32(2), uint64(0xfeb00000), uint64(0xfeb40000),
+		// TPM 2.0 event log element
+		uint32(TXTHeapElementEventLogPointer3), uint32(8+20), txtEventLogPointer3{PhysicalAddress: 0x77e00000, AllocatedEventContainerSize: 0x10000},
+		// end element
+		uint32(TXTHeapElementEnd), uint32(8),
+	)
+	osMleData := txtHeapTable(t, uint32(0xdeadbeef))
+	osSinitData := txtHeapTable(t,
+		txtOsSinitDataRaw{Version: 5, VtdPmrLoBase: 0, VtdPmrLoSize: 0x10000000},
+		uint64(0xf0000),
+	)
+	sinitMleRaw := txtSinitMleDataRaw{
+		Version:                 8,
+		NumberOfSinitMdrs:       1,
+		SinitMdrTableOffset:     uint32(8 + binary.Size(txtSinitMleDataRaw{}) + 4),
+		SinitVtdDmarTableSize:   4,
+		SinitVtdDmarTableOffset: uint32(8 + binary.Size(txtSinitMleDataRaw{}) + 4 + binary.Size(TXTMemoryDescriptor{})),
+	}
+	sinitMleData := txtHeapTable(t,
+		sinitMleRaw,
+		uint32(1),
+		TXTMemoryDescriptor{Base: 0x100000, Length: 0x7a000000, Type: TXTMDRTypeGood},
+		[]byte("DMAR"),
+	)
+
+	heap := append(append(append(biosData, osMleData...), osSinitData...), sinitMleData...)
+	parsed, err := ParseTXTHeap(heap)
+	if err != nil {
+		t.Fatalf("ParseTXTHeap failed: %v", err)
+	}
+
+	if parsed.BiosData.NumLogicalProcs != 8 || len(parsed.BiosData.Elements) != 3 {
+		t.Errorf("Unexpected BiosData: %+v", parsed.BiosData)
+	}
+	if parsed.BiosData.SpecVersion == nil || parsed.BiosData.SpecVersion.Major != 2 {
+		t.Errorf("Unexpected BIOS spec version: %+v", parsed.BiosData.SpecVersion)
+	}
+	if len(parsed.BiosData.ACMs) != 2 || parsed.BiosData.ACMs[0] != 0xfeb00000 || parsed.BiosData.ACMs[1] != 0xfeb40000 {
+		t.Errorf("Unexpected ACMs: %x", parsed.BiosData.ACMs)
+	}
+	if len(parsed.BiosData.EventLogs) != 1 || parsed.BiosData.EventLogs[0].PhysicalAddress != 0x77e00000 {
+		t.Errorf("Unexpected event logs: %+v", parsed.BiosData.EventLogs)
+	}
+	if !bytes.Equal(parsed.OsMleData, []byte{0xef, 0xbe, 0xad, 0xde}) {
+		t.Errorf("Unexpected OsMleData: %x", parsed.OsMleData)
+	}
+	if parsed.OsSinitData.VtdPmrLoSize != 0x10000000 || parsed.OsSinitData.EfiRsdtPtr != 0xf0000 {
+		t.Errorf("Unexpected OsSinitData: %+v", parsed.OsSinitData)
+	}
+	if parsed.SinitMleData.ProcessorSCRTMStatus != 1 {
+		t.Errorf("Unexpected SinitMleData: %+v", parsed.SinitMleData)
+	}
+	if len(parsed.SinitMleData.MDRs) != 1 || parsed.SinitMleData.MDRs[0].Length != 0x7a000000 {
+		t.Errorf("Unexpected MDRs: %+v", parsed.SinitMleData.MDRs)
+	}
+	if string(parsed.SinitMleData.DMAR) != "DMAR" {
+		t.Errorf("Unexpected DMAR copy: %x", parsed.SinitMleData.DMAR)
+	}
+
+	if _, err := ParseTXTHeap(heap[:len(heap)-1]); err == nil {
+		t.Errorf("Truncated heap wasn't detected")
+	}
+}
@@ -0,0 +1,67 @@
+package hwapi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseTXTRegisters(t *testing.T) {
+	buf := make([]byte, txtRegisterSpan)
+
+	binary.LittleEndian.PutUint64(buf[txtRegSTS:], 0x18041)
+	binary.LittleEndian.PutUint64(buf[txtRegESTS:], 0x1)
+	// SINIT error: class 0x1c, major 0x4, minor 0x2
+	binary.LittleEndian.PutUint32(buf[txtRegERRORCODE:], 0xc00211c1)
+	binary.LittleEndian.PutUint64(buf[txtRegDIDVID:], 0x0001_0002_b002_8086)
+	binary.LittleEndian.PutUint32(buf[txtRegVERFSBIF:], 0xffffffff)
+	binary.LittleEndian.PutUint32(buf[txtRegVERQPIIF:], 0x80000000)
+	binary.LittleEndian.PutUint32(buf[txtRegSINITBase:], 0x77ec0000)
+	binary.LittleEndian.PutUint32(buf[txtRegSINITSize:], 0x40000)
+	binary.LittleEndian.PutUint32(buf[txtRegHeapBase:], 0x77f00000)
+	binary.LittleEndian.PutUint32(buf[txtRegHeapSize:], 0x100000)
+	binary.LittleEndian.PutUint32(buf[txtRegDPR:], 0x7b000031)
+	buf[txtRegPublicKey] = 0x2d
+	binary.LittleEndian.PutUint64(buf[txtRegE2STS:], 0x2)
+
+	regs, err := parseTXTRegisters(buf)
+	if err != nil {
+		t.Fatalf("parseTXTRegisters failed: %v", err)
+	}
+
+	if !regs.Status.SENTERDone || !regs.Status.MemConfigLocked || !regs.Status.Locality1Open ||
+		!regs.Status.Locality2Open || regs.Status.SEXITDone || regs.Status.PrivateOpen {
+		t.Errorf("Unexpected TXT.STS: %+v", regs.Status)
+	}
+	if !regs.ErrorStatus.TXTReset {
+		t.Errorf("Unexpected TXT.ESTS: %+v", regs.ErrorStatus)
+	}
+	if !regs.E2Status.Secrets {
+		t.Errorf("Unexpected TXT.E2STS: %+v", regs.E2Status)
+	}
+	if regs.DIDVID.VendorID != 0x8086 || regs.DIDVID.DeviceID != 0xb002 || regs.DIDVID.RevisionID != 2 || regs.DIDVID.ExtendedID != 1 {
+		t.Errorf("Unexpected TXT.DIDVID: %+v", regs.DIDVID)
+	}
+	if !regs.ProductionFused() {
+		t.Errorf("Chipset should be production fused")
+	}
+	if regs.HeapBase != 0x77f00000 || regs.HeapSize != 0x100000 || regs.SINITBase != 0x77ec0000 {
+		t.Errorf("Unexpected heap or SINIT region: %+v", regs)
+	}
+	if regs.DPR.Limit() != 0x7b000000 {
+		t.Errorf("Unexpected TXT.DPR: %+v", regs.DPR)
+	}
+	if regs.PublicKey[0] != 0x2d {
+		t.Errorf("Unexpected TXT.PUBLIC.KEY: %x", regs.PublicKey)
+	}
+
+	acmErr, err := regs.ErrorCode.ACMError()
+	if err != nil {
+		t.Fatalf("ACMError failed: %v", err)
+	}
+	if acmErr.ModuleType != ACMModuleTypeSINIT || acmErr.Class != 0x1c || acmErr.Major != 0x4 || acmErr.Minor != 0x2 {
+		t.Errorf("Unexpected ACM error: %+v", acmErr)
+	}
+	if s := regs.ErrorCode.String(); s != "SINIT error: class 0x1c, major 0x4, minor 0x2" {
+		t.Errorf("Unexpected error string: %s", s)
+	}
+}
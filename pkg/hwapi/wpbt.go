@@ -0,0 +1,123 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// WPBT content layouts and types
+const (
+	WPBTLayoutPEImage     = 1
+	WPBTTypeNativeUserApp = 1
+)
+
+const (
+	// wpbtMaxBinarySize limits the platform binary read from physical memory
+	wpbtMaxBinarySize = 64 << 20
+)
+
+type wpbtRaw struct {
+	ACPIHeader
+	HandoffMemorySize     uint32
+	HandoffMemoryLocation uint64
+	ContentLayout         uint8
+	ContentType           uint8
+	CommandLineLength     uint16
+}
+
+// WPBT is the decoded Windows Platform Binary Table. It hands a firmware
+// provided binary to the OS, which executes it during boot.
+type WPBT struct {
+	// HandoffMemoryLocation and HandoffMemorySize describe the binary in physical memory
+	HandoffMemoryLocation uint64
+	HandoffMemorySize     uint32
+	ContentLayout         uint8
+	ContentType           uint8
+	CommandLine           string
+}
+
+// ParseWPBT decodes the ACPI WPBT table
+func ParseWPBT(buf []byte) (*WPBT, error) {
+	var raw wpbtRaw
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read WPBT: %v", err)
+	}
+	if string(raw.Signature[:]) != "WPBT" {
+		return nil, fmt.Errorf("WPBT has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw)+int(raw.CommandLineLength) ||
+		raw.CommandLineLength%2 != 0 {
+		return nil, fmt.Errorf("WPBT has invalid length %d", raw.Length)
+	}
+
+	// The command line is a UTF-16 string, usually NUL terminated
+	args := buf[binary.Size(raw) : binary.Size(raw)+int(raw.CommandLineLength)]
+	cmdline := make([]uint16, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		c := binary.LittleEndian.Uint16(args[i:])
+		if c == 0 {
+			break
+		}
+		cmdline = append(cmdline, c)
+	}
+
+	return &WPBT{
+		HandoffMemoryLocation: raw.HandoffMemoryLocation,
+		HandoffMemorySize:     raw.HandoffMemorySize,
+		ContentLayout:         raw.ContentLayout,
+		ContentType:           raw.ContentType,
+		CommandLine:           string(utf16.Decode(cmdline)),
+	}, nil
+}
+
+// ReadWPBT reads the ACPI WPBT table and decodes it
+func ReadWPBT(h LowLevelHardwareInterfaces) (*WPBT, error) {
+	buf, err := h.GetACPITable("WPBT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseWPBT(buf)
+}
+
+// ReadWPBTBinary reads the platform binary from the handoff memory
+func ReadWPBTBinary(h LowLevelHardwareInterfaces, wpbt *WPBT) ([]byte, error) {
+	if wpbt.HandoffMemoryLocation == 0 || wpbt.HandoffMemorySize == 0 {
+		return nil, fmt.Errorf("WPBT has no platform binary")
+	}
+	if wpbt.HandoffMemorySize > wpbtMaxBinarySize {
+		return nil, fmt.Errorf("WPBT platform binary of %d bytes is too big", wpbt.HandoffMemorySize)
+	}
+
+	buf := make([]byte, wpbt.HandoffMemorySize)
+	err := h.ReadPhysBuf(int64(wpbt.HandoffMemoryLocation), buf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read WPBT platform binary at %x: %v", wpbt.HandoffMemoryLocation, err)
+	}
+	return buf, nil
+}
+
+// CheckWPBT returns an error if the firmware provides a WPBT, as the OS
+// executes the platform binary it describes
+func CheckWPBT(h LowLevelHardwareInterfaces) error {
+	tables, err := h.EnumerateACPITables()
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if t.Signature != "WPBT" {
+			continue
+		}
+		wpbt, err := ReadWPBT(h)
+		if err != nil {
+			return fmt.Errorf("WPBT is present: %v", err)
+		}
+		return fmt.Errorf("WPBT is present: %d byte platform binary at %x, command line %q",
+			wpbt.HandoffMemorySize, wpbt.HandoffMemoryLocation, wpbt.CommandLine)
+	}
+	return nil
+}
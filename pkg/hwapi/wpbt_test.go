@@ -0,0 +1,38 @@
+package hwapi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWPBT(t *testing.T) {
+	mem := newPhysMemImage()
+	if err := CheckWPBT(mem); err != nil {
+		t.Errorf("CheckWPBT failed without WPBT: %v", err)
+	}
+
+	// "-x" as NUL terminated UTF-16
+	cmdline := []byte{'-', 0, 'x', 0, 0, 0}
+	buf := acpiTestTable(t, "WPBT", uint32(4), uint64(0x7a000000), uint8(WPBTLayoutPEImage),
+		uint8(WPBTTypeNativeUserApp), uint16(len(cmdline)), cmdline)
+	wpbt, err := ParseWPBT(buf)
+	if err != nil {
+		t.Fatalf("ParseWPBT failed: %v", err)
+	}
+	if wpbt.HandoffMemoryLocation != 0x7a000000 || wpbt.HandoffMemorySize != 4 || wpbt.CommandLine != "-x" {
+		t.Errorf("Unexpected WPBT %+v", wpbt)
+	}
+
+	mem.writeBuf(0x7a000000, []byte("MZ\x90\x00"))
+	bin, err := ReadWPBTBinary(mem, wpbt)
+	if err != nil || !bytes.Equal(bin, []byte("MZ\x90\x00")) {
+		t.Errorf("ReadWPBTBinary = %q, %v", bin, err)
+	}
+
+	mem.acpi["WPBT"] = buf
+	err = CheckWPBT(mem)
+	if err == nil || !strings.Contains(err.Error(), "WPBT is present") {
+		t.Errorf("CheckWPBT didn't flag the WPBT: %v", err)
+	}
+}
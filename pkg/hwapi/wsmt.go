@@ -0,0 +1,75 @@
+package hwapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// WSMT protection flags
+const (
+	WSMTFixedCommBuffers              = 1 << 0
+	WSMTCommBufferNestedPtrProtection = 1 << 1
+	WSMTSystemResourceProtection      = 1 << 2
+	wsmtAllProtections                = WSMTFixedCommBuffers | WSMTCommBufferNestedPtrProtection | WSMTSystemResourceProtection
+)
+
+type wsmtRaw struct {
+	ACPIHeader
+	ProtectionFlags uint32
+}
+
+// WSMT is the decoded Windows SMM Security Mitigations Table
+type WSMT struct {
+	ProtectionFlags uint32
+}
+
+// FixedCommBuffers returns true if SMM only uses fixed communication buffers
+func (w *WSMT) FixedCommBuffers() bool {
+	return w.ProtectionFlags&WSMTFixedCommBuffers != 0
+}
+
+// CommBufferNestedPtrProtection returns true if SMM validates pointers
+// nested in the communication buffers
+func (w *WSMT) CommBufferNestedPtrProtection() bool {
+	return w.ProtectionFlags&WSMTCommBufferNestedPtrProtection != 0
+}
+
+// SystemResourceProtection returns true if SMM protects system resources
+// like I/O and MSRs from modification by the OS
+func (w *WSMT) SystemResourceProtection() bool {
+	return w.ProtectionFlags&WSMTSystemResourceProtection != 0
+}
+
+// FullyProtected returns true if all SMM mitigations are reported
+func (w *WSMT) FullyProtected() bool {
+	return w.ProtectionFlags&wsmtAllProtections == wsmtAllProtections
+}
+
+// ParseWSMT decodes the ACPI WSMT table
+func ParseWSMT(buf []byte) (*WSMT, error) {
+	var raw wsmtRaw
+
+	err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read WSMT: %v", err)
+	}
+	if string(raw.Signature[:]) != "WSMT" {
+		return nil, fmt.Errorf("WSMT has invalid signature")
+	}
+	if int(raw.Length) > len(buf) || int(raw.Length) < binary.Size(raw) {
+		return nil, fmt.Errorf("WSMT has invalid length %d", raw.Length)
+	}
+
+	return &WSMT{ProtectionFlags: raw.ProtectionFlags}, nil
+}
+
+// ReadWSMT reads the ACPI WSMT table and decodes it
+func ReadWSMT(h LowLevelHardwareInterfaces) (*WSMT, error) {
+	buf, err := h.GetACPITable("WSMT")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseWSMT(buf)
+}
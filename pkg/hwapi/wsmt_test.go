@@ -0,0 +1,19 @@
+package hwapi
+
+import (
+	"testing"
+)
+
+func TestParseWSMT(t *testing.T) {
+	wsmt, err := ParseWSMT(acpiTestTable(t, "WSMT", uint32(WSMTFixedCommBuffers|WSMTCommBufferNestedPtrProtection)))
+	if err != nil {
+		t.Fatalf("ParseWSMT failed: %v", err)
+	}
+	if !wsmt.FixedCommBuffers() || !wsmt.CommBufferNestedPtrProtection() || wsmt.SystemResourceProtection() || wsmt.FullyProtected() {
+		t.Errorf("Unexpected WSMT protection flags %x", wsmt.ProtectionFlags)
+	}
+
+	if _, err := ParseWSMT(acpiTestTable(t, "WSMT", uint16(0))); err == nil {
+		t.Errorf("ParseWSMT accepted a short table")
+	}
+}